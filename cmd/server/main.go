@@ -4,16 +4,73 @@ import (
 	"log"
 	"payslip-generator/internal/config"
 	"payslip-generator/internal/database"
+	"payslip-generator/internal/mail"
 	"payslip-generator/internal/router"
+	"payslip-generator/internal/services"
+	"payslip-generator/internal/storage"
+	"time"
 )
 
 func main() {
 	// Load environment variables
 	config.LoadConfig()
 
+	// Read the rounding rules used by payroll calculations
+	if err := services.LoadRoundingRules(); err != nil {
+		log.Fatal("Invalid rounding rules:", err)
+	}
+
+	// Read the employer shown on payslip documents
+	services.LoadCompanyProfile()
+
+	// Employees' document passwords are sealed with a key of their own
+	if err := services.CheckDocumentPasswordKey(); err != nil {
+		log.Fatal("Invalid document password key:", err)
+	}
+
+	// Set up the storage for uploaded receipts
+	if err := storage.SetupStorage(); err != nil {
+		log.Fatal("Failed to set up file storage:", err)
+	}
+
+	// Set up the SMTP server payslips are emailed through, if configured
+	if err := mail.SetupMail(); err != nil {
+		log.Fatal("Invalid email settings:", err)
+	}
+
 	// Initialize database
 	database.SetupDatabase()
 
+	// Make sure the built-in roles exist and the administrator role holds every permission
+	if err := services.EnsureDefaultRoles(); err != nil {
+		log.Fatal("Failed to set up default roles:", err)
+	}
+
+	// Admins from before roles existed get the administrator role, so nobody is locked out
+	if err := services.GrantAdministratorToExistingAdmins(); err != nil {
+		log.Fatal("Failed to grant the administrator role to existing admins:", err)
+	}
+
+	// After a key rotation, move the document passwords over to the new key
+	if n, err := services.ResealDocumentPasswords(); err != nil {
+		log.Fatal("Failed to re-seal document passwords:", err)
+	} else if n > 0 {
+		log.Printf("Re-sealed %d document passwords with the new DOCUMENT_PASSWORD_KEY", n)
+	}
+
+	// Make sure the built-in leave types exist
+	if err := services.EnsureDefaultLeaveTypes(); err != nil {
+		log.Fatal("Failed to set up default leave types:", err)
+	}
+
+	// Fail payroll runs that were cut short by a previous shutdown so they can be retried
+	services.RecoverInterruptedPayrollRuns()
+
+	// Send queued payslip emails and retry failed ones
+	if mail.Outgoing != nil {
+		go services.RunPayslipMailer(time.Minute)
+	}
+
 	// Setup and run the router
 	r := router.SetupRouter()
 
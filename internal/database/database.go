@@ -43,16 +43,114 @@ func SetupDatabase() {
 
 	log.Println("Database connection successful.")
 
+	// Claims used to be approved on submission; remember whether they still are, so the
+	// ones already paid can be marked approved once the approval status column exists.
+	backfillClaimStatus := db.Migrator().HasTable(&models.Overtime{}) && !db.Migrator().HasColumn(&models.Overtime{}, "status")
+
+	// Claims paid before payroll runs were tracked hold the ID of their period in
+	// payroll_run_id; remember whether they still do, so they can be moved to a run.
+	migrateLegacyRuns := db.Migrator().HasTable(&models.PayrollPeriod{}) && !db.Migrator().HasTable(&models.PayrollRun{})
+
+	// The category limit per payroll period used to be a monthly, then a calendar-month limit.
+	for _, old := range []string{"monthly_limit", "calendar_month_limit"} {
+		if db.Migrator().HasColumn(&models.ReimbursementCategory{}, old) {
+			if err := db.Migrator().RenameColumn(&models.ReimbursementCategory{}, old, "period_limit"); err != nil {
+				log.Fatal("Failed to rename the reimbursement category limit:", err)
+			}
+		}
+	}
+
 	// Auto-migrate the schema
 	err = db.AutoMigrate(
 		&models.Employee{}, &models.Admin{}, &models.Attendance{},
 		&models.Overtime{}, &models.Reimbursement{}, &models.PayrollPeriod{},
 		&models.Payslip{}, &models.AuditLog{}, // Added AuditLog model
+		&models.PayslipLineItem{},
+		&models.Role{}, &models.RolePermission{}, &models.SeededPermission{}, &models.SetupStep{},
+		&models.PayrollRun{}, &models.PayrollRunError{},
+		&models.TaxTable{}, &models.TaxBracket{},
+		&models.RecurringPayComponent{}, &models.ContributionRule{}, &models.ExchangeRate{},
+		&models.HolidayCalendar{}, &models.Holiday{}, &models.WorkSchedule{},
+		&models.LeaveType{}, &models.LeaveRequest{}, &models.LeaveLedgerEntry{},
+		&models.ClaimApproval{}, &models.ApprovalRule{}, &models.ReimbursementReceipt{}, &models.ReimbursementCategory{},
+		&models.OvertimePolicy{}, &models.OvertimeTier{}, &models.PayslipTemplate{}, &models.PayslipTemplateVersion{},
+		&models.PayslipProtection{}, &models.PayslipDelivery{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database schema:", err)
 	}
 
+	if backfillClaimStatus {
+		for _, table := range []string{"overtimes", "reimbursements"} {
+			if err := db.Table(table).Where("payroll_run_id IS NOT NULL").Update("status", models.ClaimApproved).Error; err != nil {
+				log.Fatal("Failed to backfill claim approval status:", err)
+			}
+		}
+	}
+
+	if migrateLegacyRuns {
+		if err := migrateLegacyPayrollRuns(db); err != nil {
+			log.Fatal("Failed to migrate legacy payroll runs:", err)
+		}
+	}
+
 	log.Println("Database migration successful.")
 	DB = db
 }
+
+// migrateLegacyPayrollRuns creates a succeeded legacy run for every period paid
+// before runs were tracked, and points the period's payslips and the claims
+// stamped with the period's ID at it.
+func migrateLegacyPayrollRuns(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var periodIDs []uint
+		if err := tx.Model(&models.PayrollPeriod{}).Where("is_run = ?", true).Pluck("id", &periodIDs).Error; err != nil {
+			return err
+		}
+
+		// Read every stamp before rewriting any, since a new run ID may equal another period's ID.
+		claims := map[string]map[uint][]uint{}
+		for _, table := range []string{"overtimes", "reimbursements"} {
+			var rows []struct{ ID, PayrollRunID uint }
+			if err := tx.Table(table).Select("id", "payroll_run_id").Where("payroll_run_id IS NOT NULL").Scan(&rows).Error; err != nil {
+				return err
+			}
+			claims[table] = map[uint][]uint{}
+			for _, r := range rows {
+				if !containsID(periodIDs, r.PayrollRunID) {
+					periodIDs = append(periodIDs, r.PayrollRunID)
+				}
+				claims[table][r.PayrollRunID] = append(claims[table][r.PayrollRunID], r.ID)
+			}
+		}
+
+		for _, periodID := range periodIDs {
+			run := models.PayrollRun{PayrollPeriodID: periodID, Status: models.PayrollRunSucceeded, Legacy: true}
+			if err := tx.Create(&run).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Payslip{}).Where("payroll_period_id = ? AND (payroll_run_id IS NULL OR payroll_run_id = 0)", periodID).
+				Update("payroll_run_id", run.ID).Error; err != nil {
+				return err
+			}
+			for table, byPeriod := range claims {
+				if ids := byPeriod[periodID]; len(ids) > 0 {
+					if err := tx.Table(table).Where("id IN ?", ids).Update("payroll_run_id", run.ID).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// containsID reports whether id is in ids.
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
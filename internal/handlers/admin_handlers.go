@@ -1,22 +1,24 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"payslip-generator/internal/database"
 	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
 	"payslip-generator/internal/services"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func CreatePayrollPeriod(c *gin.Context) {
 	var input struct {
 		StartDate string `json:"startDate" binding:"required"` // "YYYY-MM-DD"
 		EndDate   string `json:"endDate" binding:"required"`
-		AdminID   uint   `json:"adminId" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -31,12 +33,13 @@ func CreatePayrollPeriod(c *gin.Context) {
 		return
 	}
 
+	adminID := c.GetUint("user_id")
 	period := models.PayrollPeriod{
 		StartDate: startDate,
 		EndDate:   endDate,
 		BaseModel: models.BaseModel{
-			CreatedByID: input.AdminID,
-			UpdatedByID: input.AdminID,
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
 			RequestIP:   c.GetString("request_ip"),
 		},
 	}
@@ -48,7 +51,7 @@ func CreatePayrollPeriod(c *gin.Context) {
 
 	// Add an audit log entry
 	details := fmt.Sprintf("Created new payroll period ID %d from %s to %s.", period.ID, input.StartDate, input.EndDate)
-	go services.CreateAuditLog(input.AdminID, "admin", "CREATED_PERIOD", details, c.GetString("request_ip"))
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_PERIOD", details, c.GetString("request_ip"))
 
 	c.JSON(http.StatusCreated, period)
 }
@@ -56,17 +59,106 @@ func CreatePayrollPeriod(c *gin.Context) {
 func RunPayroll(c *gin.Context) {
 	var input struct {
 		PayrollPeriodID uint `json:"payrollPeriodId" binding:"required"`
-		AdminID         uint `json:"adminId" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	run, err := services.QueuePayrollRun(input.PayrollPeriodID, c.GetUint("user_id"), c.GetString("request_ip"))
+	switch {
+	case errors.Is(err, services.ErrPeriodNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll period not found."})
+		return
+	case errors.Is(err, services.ErrPayrollAlreadyRun), errors.Is(err, services.ErrPayrollRunInFlight):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue payroll run."})
+		return
+	}
+
 	// Run the service in a goroutine for responsiveness
-	go services.RunPayrollService(input.PayrollPeriodID, input.AdminID, c.GetString("request_ip"))
+	go services.RunPayrollService(run.ID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Payroll run has been initiated. Poll /admin/payroll-runs/:id for its progress.",
+		"payrollRun": run,
+	})
+}
+
+// PreviewPayroll computes the payslips a run would produce for a period without saving anything.
+func PreviewPayroll(c *gin.Context) {
+	periodID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payroll period id"})
+		return
+	}
 
-	c.JSON(http.StatusAccepted, gin.H{"message": "Payroll run has been initiated. This may take a few moments."})
+	preview, err := services.PreviewPayroll(uint(periodID))
+	switch {
+	case errors.Is(err, services.ErrPeriodNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll period not found."})
+		return
+	case errors.Is(err, services.ErrPayrollAlreadyRun):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to preview payroll."})
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+// ReversePayroll voids a period's payslips and reopens it so payroll can be run again.
+func ReversePayroll(c *gin.Context) {
+	periodID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payroll period id"})
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = services.ReversePayroll(uint(periodID), c.GetUint("user_id"), input.Reason, c.GetString("request_ip"))
+	switch {
+	case errors.Is(err, services.ErrPeriodNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll period not found."})
+		return
+	case errors.Is(err, services.ErrPayrollNotRun):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reverse payroll."})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Payroll has been reversed. The period can be run again."})
+}
+
+// GetPayrollRun returns the status, progress and per-employee errors of a payroll run.
+func GetPayrollRun(c *gin.Context) {
+	runID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payroll run id"})
+		return
+	}
+
+	var run models.PayrollRun
+	err = database.DB.Preload("Errors").First(&run, runID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll run not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payroll run."})
+		return
+	}
+	c.JSON(http.StatusOK, run)
 }
 
 func GetPayslipSummary(c *gin.Context) {
@@ -82,7 +174,7 @@ func GetPayslipSummary(c *gin.Context) {
 	}
 
 	var payslips []models.Payslip
-	if err := database.DB.Where("payroll_period_id = ?", periodID).Find(&payslips).Error; err != nil {
+	if err := database.DB.Where("payroll_period_id = ? AND voided_at IS NULL", periodID).Find(&payslips).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch payslips"})
 		return
 	}
@@ -93,24 +185,26 @@ func GetPayslipSummary(c *gin.Context) {
 	}
 
 	type EmployeeSummary struct {
-		EmployeeID  uint    `json:"employeeId"`
-		TakeHomePay float64 `json:"takeHomePay"`
+		EmployeeID            uint         `json:"employeeId"`
+		Currency              string       `json:"currency"`
+		TakeHomePay           money.Amount `json:"takeHomePay"`
+		EmployerContributions money.Amount `json:"employerContributions"`
 	}
 
 	var summaryList []EmployeeSummary
-	totalPayout := 0.0
-
 	for _, p := range payslips {
 		summaryList = append(summaryList, EmployeeSummary{
-			EmployeeID:  p.EmployeeID,
-			TakeHomePay: p.TakeHomePay,
+			EmployeeID:            p.EmployeeID,
+			Currency:              p.Currency,
+			TakeHomePay:           p.TakeHomePay,
+			EmployerContributions: p.EmployerContributions,
 		})
-		totalPayout += p.TakeHomePay
 	}
 
+	// Employees paid in different currencies are totalled separately.
 	c.JSON(http.StatusOK, gin.H{
 		"payrollPeriodId":  periodID,
-		"totalPayout":      totalPayout,
+		"totalsByCurrency": services.TotalsByCurrency(payslips),
 		"employeePayslips": summaryList,
 	})
 }
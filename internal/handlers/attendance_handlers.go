@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListEmployeeAttendance returns an employee's check-ins and check-outs,
+// optionally between the "from" and "to" dates, with the minutes each one was
+// late or left early, along with their scheduled hours and the totals.
+func ListEmployeeAttendance(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+	schedule, err := services.WorkScheduleForEmployee(employee)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve work schedule."})
+		return
+	}
+
+	query := database.DB.Where("employee_id = ?", employee.ID).Order("check_in")
+	if from := c.Query("from"); from != "" {
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+			return
+		}
+		query = query.Where("check_in >= ?", date)
+	}
+	if to := c.Query("to"); to != "" {
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+			return
+		}
+		query = query.Where("check_in < ?", date.AddDate(0, 0, 1))
+	}
+
+	var attendances []models.Attendance
+	if err := query.Find(&attendances).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve attendance"})
+		return
+	}
+	lateArrivals, earlyLeaves := 0, 0
+	for _, a := range attendances {
+		if a.LateMinutes > 0 {
+			lateArrivals++
+		}
+		if a.EarlyLeaveMinutes > 0 {
+			earlyLeaves++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"employeeId":       employee.ID,
+		"workSchedule":     schedule.Name,
+		"scheduledMinutes": int(schedule.HoursPerDay * 60),
+		"startTime":        schedule.StartTime,
+		"endTime":          schedule.EndTime,
+		"lateArrivals":     lateArrivals,
+		"earlyLeaves":      earlyLeaves,
+		"attendance":       attendances,
+	})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"payslip-generator/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Login verifies admin or employee credentials and issues an access token.
+func Login(c *gin.Context) {
+	var input struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		UserType string `json:"userType" binding:"required,oneof=admin employee"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := services.Authenticate(input.UserType, input.Username, input.Password)
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate."})
+		return
+	}
+
+	token, expiresAt, err := services.GenerateToken(userID, input.UserType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token."})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":     token,
+		"expiresAt": expiresAt,
+		"userId":    userID,
+		"userType":  input.UserType,
+	})
+}
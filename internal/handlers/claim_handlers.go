@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"payslip-generator/internal/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListPendingClaims returns the overtime and reimbursement claims awaiting a
+// decision, oldest first, with the approvals they have collected so far and
+// the receipts attached to reimbursements.
+// The optional "type" query parameter limits the result to one kind of claim.
+func ListPendingClaims(c *gin.Context) {
+	claimType := c.Query("type")
+	if claimType != "" && claimType != models.ClaimTypeOvertime && claimType != models.ClaimTypeReimbursement {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be overtime or reimbursement"})
+		return
+	}
+
+	overtimes := []models.Overtime{}
+	reimbursements := []models.Reimbursement{}
+	if claimType != models.ClaimTypeReimbursement {
+		if err := database.DB.Preload("Approvals").Where("status = ?", models.ClaimPending).
+			Order("created_at").Find(&overtimes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve pending overtime"})
+			return
+		}
+	}
+	if claimType != models.ClaimTypeOvertime {
+		if err := database.DB.Preload("Approvals").Preload("Receipts").Preload("Category").Where("status = ?", models.ClaimPending).
+			Order("created_at").Find(&reimbursements).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve pending reimbursements"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"overtime": overtimes, "reimbursements": reimbursements})
+}
+
+// decideClaim approves or rejects the claim in the path. A reason is required to reject.
+func decideClaim(c *gin.Context, claimType string, approve bool) {
+	claimID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + claimType + " id"})
+		return
+	}
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional when approving.
+	if err := c.ShouldBindJSON(&input); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !approve && input.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to reject a claim."})
+		return
+	}
+
+	decision, err := services.DecideClaim(claimType, uint(claimID), c.GetUint("user_id"), approve, input.Reason, c.GetString("request_ip"))
+	switch {
+	case errors.Is(err, services.ErrClaimNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrClaimNotPending), errors.Is(err, services.ErrClaimAlreadyDecidedByAdmin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record the decision."})
+	default:
+		c.JSON(http.StatusOK, decision)
+	}
+}
+
+// ApproveOvertime adds an approval to a pending overtime claim.
+func ApproveOvertime(c *gin.Context) {
+	decideClaim(c, models.ClaimTypeOvertime, true)
+}
+
+// RejectOvertime rejects a pending overtime claim.
+func RejectOvertime(c *gin.Context) {
+	decideClaim(c, models.ClaimTypeOvertime, false)
+}
+
+// ApproveReimbursement adds an approval to a pending reimbursement claim.
+func ApproveReimbursement(c *gin.Context) {
+	decideClaim(c, models.ClaimTypeReimbursement, true)
+}
+
+// RejectReimbursement rejects a pending reimbursement claim.
+func RejectReimbursement(c *gin.Context) {
+	decideClaim(c, models.ClaimTypeReimbursement, false)
+}
+
+// ListApprovalRules returns the multi-level approval rules.
+func ListApprovalRules(c *gin.Context) {
+	var rules []models.ApprovalRule
+	if err := database.DB.Order("claim_type, currency, min_hours, min_amount").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve approval rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateApprovalRule adds a threshold above which claims need more than one approval.
+func CreateApprovalRule(c *gin.Context) {
+	var input struct {
+		ClaimType string       `json:"claimType" binding:"required"`
+		MinHours  float64      `json:"minHours"`
+		MinAmount money.Amount `json:"minAmount"`
+		Currency  string       `json:"currency"`
+		Levels    int          `json:"levels" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	rule := models.ApprovalRule{
+		ClaimType: input.ClaimType,
+		MinHours:  input.MinHours,
+		MinAmount: input.MinAmount,
+		Currency:  strings.ToUpper(input.Currency),
+		Levels:    input.Levels,
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	if err := services.ValidateApprovalRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create approval rule."})
+		return
+	}
+
+	details := fmt.Sprintf("Created approval rule ID %d: %s claims need %d approvals.", rule.ID, rule.ClaimType, rule.Levels)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_APPROVAL_RULE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// DeleteApprovalRule removes a rule. Claims already submitted keep the number of approvals they were given.
+func DeleteApprovalRule(c *gin.Context) {
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid approval rule id"})
+		return
+	}
+
+	result := database.DB.Delete(&models.ApprovalRule{}, ruleID)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete approval rule."})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Approval rule not found."})
+		return
+	}
+
+	details := fmt.Sprintf("Deleted approval rule ID %d.", ruleID)
+	go services.CreateAuditLog(c.GetUint("user_id"), services.UserTypeAdmin, "DELETED_APPROVAL_RULE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Approval rule deleted."})
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"payslip-generator/internal/services"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListContributionRules returns every contribution rule version, grouped by code, newest first.
+func ListContributionRules(c *gin.Context) {
+	var rules []models.ContributionRule
+	if err := database.DB.Order("code, effective_from desc").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve contribution rules"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateContributionRule adds a new version of a contribution effective from the given date.
+// To retire a contribution, add a version with both rates set to zero.
+func CreateContributionRule(c *gin.Context) {
+	var input struct {
+		Code          string       `json:"code" binding:"required"`
+		Description   string       `json:"description" binding:"required"`
+		EffectiveFrom string       `json:"effectiveFrom" binding:"required"` // "YYYY-MM-DD"
+		Base          string       `json:"base" binding:"required"`
+		Currency      string       `json:"currency"` // Defaults to models.DefaultCurrency
+		BaseCap       money.Amount `json:"baseCap"`
+		EmployeeRate  float64      `json:"employeeRate"`
+		EmployerRate  float64      `json:"employerRate"`
+		TaxDeductible bool         `json:"taxDeductible"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	effectiveFrom, err := time.Parse("2006-01-02", input.EffectiveFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	rule := models.ContributionRule{
+		Code:          input.Code,
+		Description:   input.Description,
+		EffectiveFrom: effectiveFrom,
+		Base:          input.Base,
+		Currency:      strings.ToUpper(input.Currency),
+		BaseCap:       input.BaseCap,
+		EmployeeRate:  input.EmployeeRate,
+		EmployerRate:  input.EmployerRate,
+		TaxDeductible: input.TaxDeductible,
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	if rule.Currency == "" {
+		rule.Currency = models.DefaultCurrency
+	}
+
+	if err := services.ValidateContributionRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create contribution rule. A version of this code may already take effect on this date."})
+		return
+	}
+
+	details := fmt.Sprintf("Created contribution rule %s (ID %d) effective from %s.", rule.Code, rule.ID, input.EffectiveFrom)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_CONTRIBUTION_RULE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, rule)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"payslip-generator/internal/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListExchangeRates returns the loaded exchange rates, optionally for one currency pair.
+func ListExchangeRates(c *gin.Context) {
+	query := database.DB.Order("from_currency, to_currency, effective_from desc")
+	if from := c.Query("from"); from != "" {
+		query = query.Where("from_currency = ?", strings.ToUpper(from))
+	}
+	if to := c.Query("to"); to != "" {
+		query = query.Where("to_currency = ?", strings.ToUpper(to))
+	}
+
+	var rates []models.ExchangeRate
+	if err := query.Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve exchange rates"})
+		return
+	}
+	c.JSON(http.StatusOK, rates)
+}
+
+// CreateExchangeRate adds a rate for a currency pair effective from the given date.
+func CreateExchangeRate(c *gin.Context) {
+	var input struct {
+		FromCurrency  string  `json:"fromCurrency" binding:"required"`
+		ToCurrency    string  `json:"toCurrency" binding:"required"`
+		Rate          float64 `json:"rate" binding:"required"`
+		EffectiveFrom string  `json:"effectiveFrom" binding:"required"` // "YYYY-MM-DD"
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	effectiveFrom, err := time.Parse("2006-01-02", input.EffectiveFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	rate := models.ExchangeRate{
+		FromCurrency:  strings.ToUpper(input.FromCurrency),
+		ToCurrency:    strings.ToUpper(input.ToCurrency),
+		Rate:          input.Rate,
+		EffectiveFrom: effectiveFrom,
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	if err := services.ValidateExchangeRate(rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&rate).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create exchange rate. A rate for this pair may already take effect on this date."})
+		return
+	}
+
+	details := fmt.Sprintf("Set exchange rate %s/%s to %v effective from %s.", rate.FromCurrency, rate.ToCurrency, rate.Rate, input.EffectiveFrom)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_EXCHANGE_RATE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, rate)
+}
+
+// ImportExchangeRates loads rates from an uploaded CSV file (form field "file").
+// Either every line is imported or none is.
+func ImportExchangeRates(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing CSV file in form field \"file\""})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	adminID := c.GetUint("user_id")
+	rates, err := services.ImportExchangeRates(file, adminID, c.GetString("request_ip"))
+	if errors.Is(err, services.ErrInvalidExchangeRate) || errors.Is(err, services.ErrInvalidCurrency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to import exchange rates. A rate in the file may already exist."})
+		return
+	}
+
+	details := fmt.Sprintf("Imported %d exchange rates from %s.", len(rates), fileHeader.Filename)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "IMPORTED_EXCHANGE_RATES", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, gin.H{"imported": len(rates), "exchangeRates": rates})
+}
+
+// UpdateEmployeeSalary sets an employee's salary and the currency it is paid in.
+func UpdateEmployeeSalary(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+
+	var input struct {
+		Salary   money.Amount `json:"salary" binding:"required,gt=0"`
+		Currency string       `json:"currency" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Currency = strings.ToUpper(input.Currency)
+	if err := services.ValidateCurrency(input.Currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	updates := map[string]interface{}{
+		"salary":        input.Salary,
+		"currency":      input.Currency,
+		"updated_by_id": adminID,
+	}
+	if err := database.DB.Model(&employee).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update salary."})
+		return
+	}
+
+	details := fmt.Sprintf("Set salary of employee ID %d to %s %s.", employee.ID, input.Currency, input.Salary)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_SALARY", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, employee)
+}
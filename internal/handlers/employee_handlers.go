@@ -5,7 +5,10 @@ import (
 	"net/http"
 	"payslip-generator/internal/database"
 	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"payslip-generator/internal/services"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,35 +16,57 @@ import (
 )
 
 func SubmitAttendance(c *gin.Context) {
-	var input struct {
-		EmployeeID uint `json:"employeeId" binding:"required"`
+	employeeID := c.GetUint("user_id")
+
+	now := time.Now()
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
 	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	schedule, err := services.WorkScheduleForEmployee(employee)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve work schedule."})
 		return
 	}
-
-	now := time.Now()
-	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Attendance submission is not allowed on weekends."})
+	// A check-in after midnight for a night shift belongs to the day the shift started.
+	shiftDay := services.ShiftDay(schedule, now)
+	if !services.IsScheduledWorkDay(schedule, shiftDay) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Attendance submission is not allowed on days off in your work schedule."})
+		return
+	}
+	calendar, holiday, err := services.HolidayOn(employee, shiftDay)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check the holiday calendar."})
+		return
+	}
+	if holiday != nil && calendar.BlockAttendance {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Attendance submission is not allowed on public holidays (" + holiday.Name + ")."})
 		return
 	}
 
-	var existingAttendance models.Attendance
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	endOfDay := startOfDay.Add(24 * time.Hour)
-	err := database.DB.Where("employee_id = ? AND check_in >= ? AND check_in < ?", input.EmployeeID, startOfDay, endOfDay).First(&existingAttendance).Error
-	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusConflict, gin.H{"error": "Attendance for today has already been submitted."})
+	// Attendance checked in from the shift day to the end of the next day may belong to the same shift.
+	var recent []models.Attendance
+	if err := database.DB.Where("employee_id = ? AND check_in >= ? AND check_in < ?", employeeID, shiftDay, shiftDay.AddDate(0, 0, 2)).Find(&recent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendance."})
 		return
 	}
+	for _, existing := range recent {
+		if services.ShiftDay(schedule, existing.CheckIn.In(now.Location())).Equal(shiftDay) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Attendance for today has already been submitted."})
+			return
+		}
+	}
 
 	attendance := models.Attendance{
-		EmployeeID: input.EmployeeID,
-		CheckIn:    now,
+		EmployeeID:  employeeID,
+		CheckIn:     now,
+		LateMinutes: services.LateMinutes(schedule, now),
+		OnHoliday:   holiday != nil,
 		BaseModel: models.BaseModel{
-			CreatedByID: input.EmployeeID,
-			UpdatedByID: input.EmployeeID,
+			CreatedByID: employeeID,
+			UpdatedByID: employeeID,
 			RequestIP:   c.GetString("request_ip"),
 		},
 	}
@@ -53,21 +78,58 @@ func SubmitAttendance(c *gin.Context) {
 	c.JSON(http.StatusCreated, attendance)
 }
 
+// SubmitCheckout records the check-out time of the employee's latest attendance
+// still open, the minutes worked since check-in and how early the employee left
+// the scheduled hours of the shift they checked in for. The attendance may have
+// been checked in the day before, as on a night shift.
+func SubmitCheckout(c *gin.Context) {
+	employeeID := c.GetUint("user_id")
+
+	now := time.Now()
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+	schedule, err := services.WorkScheduleForEmployee(employee)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve work schedule."})
+		return
+	}
+
+	var attendance models.Attendance
+	err = database.DB.Where("employee_id = ? AND check_out IS NULL", employeeID).Order("check_in desc").First(&attendance).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No attendance is waiting for a check-out."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve attendance."})
+		return
+	}
+
+	attendance.CheckOut = &now
+	attendance.WorkedMinutes = int(now.Sub(attendance.CheckIn).Minutes())
+	attendance.EarlyLeaveMinutes = services.EarlyLeaveMinutes(schedule, attendance.CheckIn.In(now.Location()), now)
+	attendance.UpdatedByID = employeeID
+	attendance.RequestIP = c.GetString("request_ip")
+	if err := database.DB.Save(&attendance).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit check-out."})
+		return
+	}
+	c.JSON(http.StatusOK, attendance)
+}
+
 func SubmitOvertime(c *gin.Context) {
 	var input struct {
-		EmployeeID uint    `json:"employeeId" binding:"required"`
-		Hours      float64 `json:"hours" binding:"required,gt=0,lte=3"`
-		Date       string  `json:"date" binding:"required"`
+		Hours float64 `json:"hours" binding:"required,gt=0,lte=24"`
+		Date  string  `json:"date" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	if time.Now().Hour() < 17 {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Overtime can only be proposed after 5 PM."})
-		return
-	}
+	employeeID := c.GetUint("user_id")
 
 	overtimeDate, err := time.Parse("2006-01-02", input.Date)
 	if err != nil {
@@ -75,47 +137,136 @@ func SubmitOvertime(c *gin.Context) {
 		return
 	}
 
-	overtime := models.Overtime{
-		EmployeeID: input.EmployeeID,
-		Hours:      input.Hours,
-		Date:       overtimeDate,
-		BaseModel: models.BaseModel{
-			CreatedByID: input.EmployeeID,
-			UpdatedByID: input.EmployeeID,
-			RequestIP:   c.GetString("request_ip"),
-		},
-	}
-
-	if err := database.DB.Create(&overtime).Error; err != nil {
+	overtime, err := services.SubmitOvertime(employeeID, overtimeDate, input.Hours, time.Now(), c.GetString("request_ip"))
+	switch {
+	case errors.Is(err, services.ErrOvertimeNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOvertimeDailyLimit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit overtime."})
-		return
+	default:
+		c.JSON(http.StatusCreated, overtime)
 	}
-	c.JSON(http.StatusCreated, overtime)
 }
 
+// SubmitReimbursement accepts a JSON claim, or a multipart form with the same
+// fields and up to MaxReceiptsPerClaim files in "receipts".
 func SubmitReimbursement(c *gin.Context) {
 	var input struct {
-		EmployeeID  uint    `json:"employeeId" binding:"required"`
-		Amount      float64 `json:"amount" binding:"required,gt=0"`
-		Description string  `json:"description" binding:"required"`
+		Amount      money.Amount `json:"amount" binding:"required,gt=0"`
+		Currency    string       `json:"currency"` // Optional, defaults to the employee's pay currency
+		Description string       `json:"description" binding:"required"`
+		CategoryID  *uint        `json:"categoryId"`  // Optional, applies the category's limits and required fields
+		ExpenseDate string       `json:"expenseDate"` // Optional, "YYYY-MM-DD"
+		Merchant    string       `json:"merchant"`
 	}
-	if err := c.ShouldBindJSON(&input); err != nil {
+	var receipts []services.ReceiptUpload
+	if c.ContentType() == "multipart/form-data" {
+		uploads, closeUploads, ok := receiptUploads(c)
+		if !ok {
+			return
+		}
+		defer closeUploads()
+		receipts = uploads
+
+		amount, err := money.Parse(c.PostForm("amount"))
+		if err != nil || amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be a positive amount with at most two decimal places"})
+			return
+		}
+		input.Amount = amount
+		input.Currency = c.PostForm("currency")
+		input.Description = c.PostForm("description")
+		if input.Description == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "description is required"})
+			return
+		}
+		if categoryID := c.PostForm("categoryId"); categoryID != "" {
+			id, err := strconv.ParseUint(categoryID, 10, 0)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid categoryId"})
+				return
+			}
+			input.CategoryID = new(uint)
+			*input.CategoryID = uint(id)
+		}
+		input.ExpenseDate = c.PostForm("expenseDate")
+		input.Merchant = c.PostForm("merchant")
+	} else if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	employeeID := c.GetUint("user_id")
+
+	currency := strings.ToUpper(input.Currency)
+	if currency == "" {
+		var employee models.Employee
+		if err := database.DB.Select("currency").First(&employee, employeeID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+			return
+		}
+		currency = employee.Currency
+	}
+	if err := services.ValidateCurrency(currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var expenseDate *time.Time
+	if input.ExpenseDate != "" {
+		date, err := time.Parse("2006-01-02", input.ExpenseDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+			return
+		}
+		if date.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expenseDate cannot be in the future."})
+			return
+		}
+		expenseDate = &date
+	}
+
+	var category *models.ReimbursementCategory
+	if input.CategoryID != nil {
+		category = &models.ReimbursementCategory{}
+		if err := database.DB.First(category, *input.CategoryID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown reimbursement category."})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reimbursement category."})
+			return
+		}
+	}
+
+	requiredApprovals, err := services.RequiredApprovalsForReimbursement(input.Amount, currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check approval rules."})
+		return
+	}
 
 	reimbursement := models.Reimbursement{
-		EmployeeID:  input.EmployeeID,
-		Amount:      input.Amount,
-		Description: input.Description,
+		EmployeeID:        employeeID,
+		Amount:            input.Amount,
+		Currency:          currency,
+		Description:       input.Description,
+		CategoryID:        input.CategoryID,
+		ExpenseDate:       expenseDate,
+		Merchant:          input.Merchant,
+		Status:            models.ClaimPending,
+		RequiredApprovals: requiredApprovals,
 		BaseModel: models.BaseModel{
-			CreatedByID: input.EmployeeID,
-			UpdatedByID: input.EmployeeID,
+			CreatedByID: employeeID,
+			UpdatedByID: employeeID,
 			RequestIP:   c.GetString("request_ip"),
 		},
 	}
 
-	if err := database.DB.Create(&reimbursement).Error; err != nil {
+	err = services.SubmitReimbursement(c.Request.Context(), &reimbursement, category, receipts)
+	if errors.Is(err, services.ErrReimbursementPolicy) || errors.Is(err, services.ErrNoExchangeRate) || errors.Is(err, services.ErrInvalidReceipt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit reimbursement."})
 		return
 	}
@@ -123,23 +274,30 @@ func SubmitReimbursement(c *gin.Context) {
 }
 
 func GeneratePayslip(c *gin.Context) {
-	employeeIDStr := c.Query("employee_id")
+	employeeID := c.GetUint("user_id")
 	periodIDStr := c.Query("period_id")
 
-	if employeeIDStr == "" || periodIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing employee_id or period_id query parameter"})
+	if periodIDStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing period_id query parameter"})
+		return
+	}
+
+	periodID, err := strconv.Atoi(periodIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period_id"})
 		return
 	}
 
-	employeeID, err1 := strconv.Atoi(employeeIDStr)
-	periodID, err2 := strconv.Atoi(periodIDStr)
-	if err1 != nil || err2 != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee_id or period_id"})
+	// Browsers asking for HTML get the payslip rendered with their group's template.
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		servePayslipHTML(c, employeeID, uint(periodID))
 		return
 	}
 
 	var payslip models.Payslip
-	err := database.DB.Where("employee_id = ? AND payroll_period_id = ?", employeeID, periodID).First(&payslip).Error
+	err = database.DB.Preload("LineItems").
+		Where("employee_id = ? AND payroll_period_id = ? AND voided_at IS NULL", employeeID, periodID).
+		First(&payslip).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"message": "Payslip for this period not found."})
 		return
@@ -2,12 +2,24 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"payslip-generator/internal/database"
 	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"payslip-generator/internal/storage"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/sqlite"
@@ -22,21 +34,44 @@ func setupTestEnvironment() *gin.Engine {
 		panic("Failed to connect to test database")
 	}
 	database.DB = db
-	db.AutoMigrate(&models.Attendance{})
+	db.AutoMigrate(&models.Employee{}, &models.Attendance{}, &models.HolidayCalendar{}, &models.Holiday{}, &models.WorkSchedule{})
+	db.Exec("DELETE FROM employees")
+	db.Create(&models.Employee{BaseModel: models.BaseModel{ID: 1}, Username: "employee1", Location: "Jakarta"})
 
 	r := gin.Default()
 	return r
 }
 
+// asUser stands in for the auth middleware by placing a principal in the context.
+func asUser(userID uint, userType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("user_type", userType)
+		c.Next()
+	}
+}
+
 func TestSubmitAttendance(t *testing.T) {
 	r := setupTestEnvironment()
-	r.POST("/employee/attendance", SubmitAttendance)
+	r.POST("/employee/attendance", asUser(1, "employee"), SubmitAttendance)
+
+	t.Run("should fail if attendance is submitted on a day off", func(t *testing.T) {
+		// A rotation starting tomorrow with one working day and one day off
+		// makes today a day off, whatever day it is.
+		tomorrow := time.Now().AddDate(0, 0, 1)
+		schedule := models.WorkSchedule{Name: "Alternate days", RotationPattern: "WO", RotationStart: &tomorrow, HoursPerDay: 8}
+		database.DB.Exec("DELETE FROM work_schedules")
+		database.DB.Create(&schedule)
+		database.DB.Model(&models.Employee{}).Where("id = ?", 1).Update("work_schedule_id", schedule.ID)
+		defer database.DB.Model(&models.Employee{}).Where("id = ?", 1).Update("work_schedule_id", nil)
+
+		req, _ := http.NewRequest(http.MethodPost, "/employee/attendance", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
 
-	t.Run("should fail if attendance is submitted on a weekend", func(t *testing.T) {
-		// This test is hard to make deterministic without mocking time.
-		// For this example, we'll assume today is not a weekend.
-		// In a real-world scenario, you would use a library to mock `time.Now()`.
-		t.Skip("Skipping weekend test as it depends on the current day.")
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected submission on a day off to fail with status 403, but got %d", w.Code)
+		}
 	})
 
 	t.Run("should fail if attendance is submitted twice on the same day", func(t *testing.T) {
@@ -44,8 +79,7 @@ func TestSubmitAttendance(t *testing.T) {
 		database.DB.Exec("DELETE FROM attendances")
 
 		// First submission (should succeed)
-		payload := []byte(`{"employeeId": 1}`)
-		req, _ := http.NewRequest(http.MethodPost, "/employee/attendance", bytes.NewBuffer(payload))
+		req, _ := http.NewRequest(http.MethodPost, "/employee/attendance", nil)
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
@@ -55,7 +89,7 @@ func TestSubmitAttendance(t *testing.T) {
 		}
 
 		// Second submission (should fail)
-		req2, _ := http.NewRequest(http.MethodPost, "/employee/attendance", bytes.NewBuffer(payload))
+		req2, _ := http.NewRequest(http.MethodPost, "/employee/attendance", nil)
 		req2.Header.Set("Content-Type", "application/json")
 		w2 := httptest.NewRecorder()
 		r.ServeHTTP(w2, req2)
@@ -72,3 +106,253 @@ func TestSubmitAttendance(t *testing.T) {
 		}
 	})
 }
+
+func TestSubmitAttendanceOnHoliday(t *testing.T) {
+	r := setupTestEnvironment()
+	r.POST("/employee/attendance", asUser(1, "employee"), SubmitAttendance)
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Work every day, so that the holiday is the only reason to refuse attendance.
+	database.DB.Exec("DELETE FROM work_schedules")
+	schedule := models.WorkSchedule{Name: "Every day", Weekdays: "mon,tue,wed,thu,fri,sat,sun", HoursPerDay: 8}
+	database.DB.Create(&schedule)
+	database.DB.Model(&models.Employee{}).Where("id = ?", 1).Update("work_schedule_id", schedule.ID)
+
+	database.DB.Exec("DELETE FROM attendances")
+	database.DB.Exec("DELETE FROM holidays")
+	database.DB.Exec("DELETE FROM holiday_calendars")
+	calendar := models.HolidayCalendar{Name: "Jakarta", Location: "Jakarta", BlockAttendance: true}
+	database.DB.Create(&calendar)
+	database.DB.Create(&models.Holiday{HolidayCalendarID: calendar.ID, Date: today, Name: "Test Day"})
+
+	req, _ := http.NewRequest(http.MethodPost, "/employee/attendance", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Expected attendance on a blocking holiday to fail with status 403, but got %d", w.Code)
+	}
+
+	database.DB.Model(&calendar).Update("block_attendance", false)
+	req, _ = http.NewRequest(http.MethodPost, "/employee/attendance", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected attendance on a flagging holiday to succeed with status 201, but got %d", w.Code)
+	}
+	var attendance models.Attendance
+	json.Unmarshal(w.Body.Bytes(), &attendance)
+	if !attendance.OnHoliday {
+		t.Error("Expected the attendance to be flagged as on a holiday")
+	}
+}
+
+func TestSubmitCheckout(t *testing.T) {
+	r := setupTestEnvironment()
+	r.POST("/employee/attendance/checkout", asUser(1, "employee"), SubmitCheckout)
+	database.DB.Exec("DELETE FROM attendances")
+
+	req, _ := http.NewRequest(http.MethodPost, "/employee/attendance/checkout", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected check-out without a check-in to fail with status 404, but got %d", w.Code)
+	}
+
+	// Check in 90 minutes ago, or at midnight if that was yesterday.
+	now := time.Now()
+	checkIn := now.Add(-90 * time.Minute)
+	if startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()); checkIn.Before(startOfDay) {
+		checkIn = startOfDay
+	}
+	database.DB.Create(&models.Attendance{EmployeeID: 1, CheckIn: checkIn})
+
+	req, _ = http.NewRequest(http.MethodPost, "/employee/attendance/checkout", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected check-out to succeed with status 200, but got %d", w.Code)
+	}
+	var attendance models.Attendance
+	json.Unmarshal(w.Body.Bytes(), &attendance)
+	if attendance.CheckOut == nil || attendance.WorkedMinutes != int(attendance.CheckOut.Sub(checkIn).Minutes()) {
+		t.Errorf("Expected the check-out time and worked minutes to be recorded, got %+v", attendance)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "/employee/attendance/checkout", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected a second check-out to fail with status 404, but got %d", w.Code)
+	}
+}
+
+func TestSubmitCheckoutAfterMidnight(t *testing.T) {
+	r := setupTestEnvironment()
+	r.POST("/employee/attendance/checkout", asUser(1, "employee"), SubmitCheckout)
+	database.DB.Exec("DELETE FROM attendances")
+
+	night := models.WorkSchedule{Name: "Night", Weekdays: "sun,mon,tue,wed,thu,fri,sat", HoursPerDay: 8, StartTime: "22:00", EndTime: "06:00"}
+	database.DB.Exec("DELETE FROM work_schedules")
+	database.DB.Create(&night)
+	database.DB.Model(&models.Employee{}).Where("id = ?", 1).Update("work_schedule_id", night.ID)
+	defer database.DB.Model(&models.Employee{}).Where("id = ?", 1).Update("work_schedule_id", nil)
+
+	// Checked in at 23:00 yesterday for the shift ending at 06:00 today.
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	checkIn := startOfDay.Add(-time.Hour)
+	database.DB.Create(&models.Attendance{EmployeeID: 1, CheckIn: checkIn})
+
+	req, _ := http.NewRequest(http.MethodPost, "/employee/attendance/checkout", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected check-out of yesterday's night shift to succeed with status 200, but got %d", w.Code)
+	}
+	var attendance models.Attendance
+	json.Unmarshal(w.Body.Bytes(), &attendance)
+	if attendance.CheckOut == nil {
+		t.Fatal("Expected the check-out time to be recorded")
+	}
+	expected := 0
+	if end := startOfDay.Add(6 * time.Hour); attendance.CheckOut.Before(end) {
+		expected = int(end.Sub(*attendance.CheckOut).Minutes())
+	}
+	if attendance.EarlyLeaveMinutes != expected {
+		t.Errorf("Expected %d early leave minutes against the shift ending at 06:00 today, but got %d", expected, attendance.EarlyLeaveMinutes)
+	}
+}
+
+// failingStore stores the first puts objects and fails the ones after.
+type failingStore struct {
+	storage.Store
+	puts int
+}
+
+func (s *failingStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if s.puts == 0 {
+		return errors.New("store unavailable")
+	}
+	s.puts--
+	return s.Store.Put(ctx, key, r, size, contentType)
+}
+
+func TestSubmitReimbursementWithFailingStorage(t *testing.T) {
+	r := setupTestEnvironment()
+	database.DB.AutoMigrate(&models.Reimbursement{}, &models.ReimbursementReceipt{}, &models.ApprovalRule{})
+	database.DB.Exec("DELETE FROM reimbursement_receipts")
+	database.DB.Exec("DELETE FROM reimbursements")
+	dir := t.TempDir()
+	local, err := storage.NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.Files = &failingStore{Store: local, puts: 1}
+	r.POST("/employee/reimbursements", asUser(1, "employee"), SubmitReimbursement)
+
+	pdf := []byte("%PDF-1.4\n1 0 obj << >> endobj\ntrailer << >>\n%%EOF\n")
+	fields := map[string]string{"amount": "125000.50", "currency": "IDR", "description": "Hotel"}
+	body, contentType := receiptForm(t, fields, map[string][]byte{"hotel.pdf": pdf, "taxi.pdf": pdf})
+	req, _ := http.NewRequest(http.MethodPost, "/employee/reimbursements", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected the claim to fail with status 500, but got %d", w.Code)
+	}
+
+	var claims, receipts int64
+	database.DB.Model(&models.Reimbursement{}).Count(&claims)
+	database.DB.Model(&models.ReimbursementReceipt{}).Count(&receipts)
+	if claims != 0 || receipts != 0 {
+		t.Errorf("Expected no claim or receipt to be recorded, but found %d and %d", claims, receipts)
+	}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			t.Errorf("Expected the stored receipt to be deleted, but found %s", path)
+		}
+		return nil
+	})
+}
+
+// receiptForm builds a multipart reimbursement form with the given files in "receipts".
+func receiptForm(t *testing.T, fields map[string]string, files map[string][]byte) (*bytes.Buffer, string) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for name, value := range fields {
+		w.WriteField(name, value)
+	}
+	for name, content := range files {
+		part, _ := w.CreateFormFile("receipts", name)
+		part.Write(content)
+	}
+	w.Close()
+	return body, w.FormDataContentType()
+}
+
+func TestSubmitReimbursementWithReceipts(t *testing.T) {
+	r := setupTestEnvironment()
+	database.DB.AutoMigrate(&models.Reimbursement{}, &models.ReimbursementReceipt{}, &models.ApprovalRule{})
+	database.DB.Exec("DELETE FROM reimbursement_receipts")
+	database.DB.Exec("DELETE FROM reimbursements")
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.Files = store
+	r.POST("/employee/reimbursements", asUser(1, "employee"), SubmitReimbursement)
+	r.GET("/employee/reimbursements/:id/receipts/:receiptId", asUser(1, "employee"), DownloadMyReceipt)
+	r.GET("/other/reimbursements/:id/receipts/:receiptId", asUser(2, "employee"), DownloadMyReceipt)
+
+	pdf := []byte("%PDF-1.4\n1 0 obj << >> endobj\ntrailer << >>\n%%EOF\n")
+	fields := map[string]string{"amount": "125000.50", "currency": "IDR", "description": "Hotel"}
+
+	body, contentType := receiptForm(t, fields, map[string][]byte{"notes.txt": []byte("just text")})
+	req, _ := http.NewRequest(http.MethodPost, "/employee/reimbursements", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected a text file to be rejected with status 400, but got %d", w.Code)
+	}
+
+	body, contentType = receiptForm(t, fields, map[string][]byte{"hotel.pdf": pdf})
+	req, _ = http.NewRequest(http.MethodPost, "/employee/reimbursements", body)
+	req.Header.Set("Content-Type", contentType)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected the claim to be created with status 201, but got %d: %s", w.Code, w.Body.String())
+	}
+	var reimbursement models.Reimbursement
+	json.Unmarshal(w.Body.Bytes(), &reimbursement)
+	if reimbursement.Amount != money.MustParse("125000.50") || len(reimbursement.Receipts) != 1 {
+		t.Fatalf("Expected the amount and one receipt, got %+v", reimbursement)
+	}
+	receipt := reimbursement.Receipts[0]
+	checksum := sha256.Sum256(pdf)
+	if receipt.ContentType != "application/pdf" || receipt.SHA256 != hex.EncodeToString(checksum[:]) {
+		t.Errorf("Expected a PDF with its checksum, got %+v", receipt)
+	}
+
+	path := fmt.Sprintf("/reimbursements/%d/receipts/%d", reimbursement.ID, receipt.ID)
+	req, _ = http.NewRequest(http.MethodGet, "/employee"+path, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), pdf) {
+		t.Fatalf("Expected the owner to download the receipt, but got status %d", w.Code)
+	}
+	if w.Header().Get("X-Checksum-SHA256") != receipt.SHA256 {
+		t.Errorf("Expected the checksum header, got %q", w.Header().Get("X-Checksum-SHA256"))
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/other"+path, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected another employee to get status 404, but got %d", w.Code)
+	}
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// findHolidayCalendar loads the calendar in the path, answering 400 or 404 itself.
+func findHolidayCalendar(c *gin.Context) (models.HolidayCalendar, bool) {
+	var calendar models.HolidayCalendar
+	calendarID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid holiday calendar id"})
+		return calendar, false
+	}
+	err = database.DB.First(&calendar, calendarID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Holiday calendar not found."})
+		return calendar, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve holiday calendar."})
+		return calendar, false
+	}
+	return calendar, true
+}
+
+// ListHolidayCalendars returns every holiday calendar without its holidays.
+func ListHolidayCalendars(c *gin.Context) {
+	var calendars []models.HolidayCalendar
+	if err := database.DB.Order("name").Find(&calendars).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve holiday calendars"})
+		return
+	}
+	c.JSON(http.StatusOK, calendars)
+}
+
+// CreateHolidayCalendar creates an empty calendar; holidays are added or imported separately.
+func CreateHolidayCalendar(c *gin.Context) {
+	var input struct {
+		Name               string  `json:"name" binding:"required"`
+		Location           string  `json:"location"`
+		BlockAttendance    bool    `json:"blockAttendance"`
+		OvertimeMultiplier float64 `json:"overtimeMultiplier" binding:"omitempty,gte=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	calendar := models.HolidayCalendar{
+		Name:               input.Name,
+		Location:           input.Location,
+		BlockAttendance:    input.BlockAttendance,
+		OvertimeMultiplier: input.OvertimeMultiplier,
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	if err := database.DB.Create(&calendar).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create holiday calendar. The name may already be taken."})
+		return
+	}
+
+	details := fmt.Sprintf("Created holiday calendar ID %d %q for location %q.", calendar.ID, calendar.Name, calendar.Location)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_HOLIDAY_CALENDAR", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, calendar)
+}
+
+// GetHolidayCalendar returns a calendar with its holidays, optionally only those of one year.
+func GetHolidayCalendar(c *gin.Context) {
+	calendar, ok := findHolidayCalendar(c)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Where("holiday_calendar_id = ?", calendar.ID).Order("date")
+	if yearStr := c.Query("year"); yearStr != "" {
+		year, err := strconv.Atoi(yearStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+			return
+		}
+		query = query.Where("date BETWEEN ? AND ?", time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC))
+	}
+	if err := query.Find(&calendar.Holidays).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve holidays"})
+		return
+	}
+	c.JSON(http.StatusOK, calendar)
+}
+
+// AddHoliday adds a single holiday to a calendar.
+func AddHoliday(c *gin.Context) {
+	calendar, ok := findHolidayCalendar(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Date string `json:"date" binding:"required"` // "YYYY-MM-DD"
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	date, err := time.Parse("2006-01-02", input.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+		return
+	}
+
+	holiday := models.Holiday{HolidayCalendarID: calendar.ID, Date: date, Name: input.Name}
+	if err := database.DB.Create(&holiday).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to add holiday. The calendar may already have a holiday on this date."})
+		return
+	}
+
+	details := fmt.Sprintf("Added holiday %q on %s to calendar ID %d.", holiday.Name, input.Date, calendar.ID)
+	go services.CreateAuditLog(c.GetUint("user_id"), services.UserTypeAdmin, "ADDED_HOLIDAY", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, holiday)
+}
+
+// DeleteHoliday removes a holiday from a calendar.
+func DeleteHoliday(c *gin.Context) {
+	calendar, ok := findHolidayCalendar(c)
+	if !ok {
+		return
+	}
+	holidayID, err := strconv.Atoi(c.Param("holidayId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid holiday id"})
+		return
+	}
+
+	result := database.DB.Where("holiday_calendar_id = ?", calendar.ID).Delete(&models.Holiday{}, holidayID)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete holiday."})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Holiday not found."})
+		return
+	}
+
+	details := fmt.Sprintf("Deleted holiday ID %d from calendar ID %d.", holidayID, calendar.ID)
+	go services.CreateAuditLog(c.GetUint("user_id"), services.UserTypeAdmin, "DELETED_HOLIDAY", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Holiday deleted."})
+}
+
+// ImportHolidays loads holidays into a calendar from an uploaded .ics or .csv
+// file (form field "file"). The format is taken from the file extension.
+func ImportHolidays(c *gin.Context) {
+	calendar, ok := findHolidayCalendar(c)
+	if !ok {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing calendar file in form field \"file\""})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+	imported, err := services.ImportHolidays(calendar.ID, file, format)
+	if errors.Is(err, services.ErrInvalidHolidayFile) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import holidays."})
+		return
+	}
+
+	details := fmt.Sprintf("Imported %d holidays into calendar ID %d from %s.", imported, calendar.ID, fileHeader.Filename)
+	go services.CreateAuditLog(c.GetUint("user_id"), services.UserTypeAdmin, "IMPORTED_HOLIDAYS", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, gin.H{"imported": imported})
+}
+
+// UpdateEmployeeHolidayCalendar sets an employee's location and, optionally,
+// a calendar that overrides the one for that location.
+func UpdateEmployeeHolidayCalendar(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+
+	var input struct {
+		Location          string `json:"location"`
+		HolidayCalendarID *uint  `json:"holidayCalendarId"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+	if input.HolidayCalendarID != nil {
+		if err := database.DB.First(&models.HolidayCalendar{}, *input.HolidayCalendarID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Holiday calendar not found."})
+			return
+		}
+	}
+
+	adminID := c.GetUint("user_id")
+	updates := map[string]interface{}{
+		"location":            input.Location,
+		"holiday_calendar_id": input.HolidayCalendarID,
+		"updated_by_id":       adminID,
+	}
+	if err := database.DB.Model(&employee).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update holiday calendar."})
+		return
+	}
+
+	details := fmt.Sprintf("Set location of employee ID %d to %q.", employee.ID, input.Location)
+	if input.HolidayCalendarID != nil {
+		details = fmt.Sprintf("Set location of employee ID %d to %q with holiday calendar ID %d.", employee.ID, input.Location, *input.HolidayCalendarID)
+	}
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_EMPLOYEE_HOLIDAY_CALENDAR", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, employee)
+}
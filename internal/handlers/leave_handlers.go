@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// leaveTypeInput is the request body for creating or replacing a leave type.
+type leaveTypeInput struct {
+	Code                string  `json:"code" binding:"required"`
+	Name                string  `json:"name" binding:"required"`
+	Paid                bool    `json:"paid"`
+	TracksBalance       bool    `json:"tracksBalance"`
+	AccrualDaysPerMonth float64 `json:"accrualDaysPerMonth"`
+	MaxBalance          float64 `json:"maxBalance"`
+}
+
+// apply copies the input onto a leave type.
+func (in leaveTypeInput) apply(t *models.LeaveType) {
+	t.Code = strings.ToUpper(in.Code)
+	t.Name = in.Name
+	t.Paid = in.Paid
+	t.TracksBalance = in.TracksBalance
+	t.AccrualDaysPerMonth = in.AccrualDaysPerMonth
+	t.MaxBalance = in.MaxBalance
+}
+
+// respondLeaveError answers with the status matching an error of the leave
+// service, hiding unexpected errors behind the fallback message.
+func respondLeaveError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrLeaveRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLeaveOverlap), errors.Is(err, services.ErrLeaveRequestNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidLeaveRequest), errors.Is(err, services.ErrInvalidLeaveType),
+		errors.Is(err, services.ErrInsufficientLeaveBalance):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// ListLeaveTypes returns every leave type.
+func ListLeaveTypes(c *gin.Context) {
+	var types []models.LeaveType
+	if err := database.DB.Order("code").Find(&types).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve leave types"})
+		return
+	}
+	c.JSON(http.StatusOK, types)
+}
+
+// CreateLeaveType adds a leave type with its accrual rule.
+func CreateLeaveType(c *gin.Context) {
+	var input leaveTypeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	leaveType := models.LeaveType{
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	input.apply(&leaveType)
+	if err := services.ValidateLeaveType(leaveType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&leaveType).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create leave type. The code may already be taken."})
+		return
+	}
+
+	details := fmt.Sprintf("Created leave type ID %d %s.", leaveType.ID, leaveType.Code)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_LEAVE_TYPE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, leaveType)
+}
+
+// UpdateLeaveType replaces a leave type's settings. Balances already accrued are kept.
+func UpdateLeaveType(c *gin.Context) {
+	leaveTypeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid leave type id"})
+		return
+	}
+	var input leaveTypeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var leaveType models.LeaveType
+	if err := database.DB.First(&leaveType, leaveTypeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Leave type not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve leave type."})
+		return
+	}
+	input.apply(&leaveType)
+	if err := services.ValidateLeaveType(leaveType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	leaveType.UpdatedByID = adminID
+	leaveType.RequestIP = c.GetString("request_ip")
+	if err := database.DB.Save(&leaveType).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to update leave type. The code may already be taken."})
+		return
+	}
+
+	details := fmt.Sprintf("Updated leave type ID %d %s.", leaveType.ID, leaveType.Code)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_LEAVE_TYPE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, leaveType)
+}
+
+// AccrueLeave credits every employee with one month of leave for the given month.
+func AccrueLeave(c *gin.Context) {
+	var input struct {
+		Month string `json:"month" binding:"required"` // "YYYY-MM"
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	month, err := time.Parse("2006-01", input.Month)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month format. Please use YYYY-MM."})
+		return
+	}
+
+	accrued, err := services.AccrueLeave(month, c.GetUint("user_id"), c.GetString("request_ip"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accrue leave."})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"month": input.Month, "accrued": accrued})
+}
+
+// AdjustLeaveBalance credits or debits an employee's balance of a leave type.
+func AdjustLeaveBalance(c *gin.Context) {
+	var input struct {
+		EmployeeID  uint    `json:"employeeId" binding:"required"`
+		LeaveTypeID uint    `json:"leaveTypeId" binding:"required"`
+		Days        float64 `json:"days" binding:"required"`
+		Note        string  `json:"note" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.First(&models.Employee{}, input.EmployeeID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Employee not found."})
+		return
+	}
+
+	entry, err := services.AdjustLeaveBalance(input.EmployeeID, input.LeaveTypeID, input.Days, input.Note, c.GetUint("user_id"), c.GetString("request_ip"))
+	if err != nil {
+		respondLeaveError(c, err, "Failed to adjust leave balance.")
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// GetEmployeeLeaveBalances returns an employee's balance of every leave type.
+func GetEmployeeLeaveBalances(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+	balances, err := services.LeaveBalances(uint(employeeID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve leave balances"})
+		return
+	}
+	c.JSON(http.StatusOK, balances)
+}
+
+// ListLeaveRequests returns leave requests, optionally of one status, oldest first.
+func ListLeaveRequests(c *gin.Context) {
+	query := database.DB.Preload("LeaveType").Order("created_at")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if employeeID := c.Query("employee_id"); employeeID != "" {
+		query = query.Where("employee_id = ?", employeeID)
+	}
+
+	var requests []models.LeaveRequest
+	if err := query.Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve leave requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// decideLeaveRequest approves or rejects the leave request in the path.
+func decideLeaveRequest(c *gin.Context, approve bool) {
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid leave request id"})
+		return
+	}
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional when approving.
+	if err := c.ShouldBindJSON(&input); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !approve && input.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to reject a leave request."})
+		return
+	}
+
+	request, err := services.DecideLeaveRequest(uint(requestID), c.GetUint("user_id"), approve, input.Reason, c.GetString("request_ip"))
+	if err != nil {
+		respondLeaveError(c, err, "Failed to decide leave request.")
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// ApproveLeaveRequest approves a pending leave request.
+func ApproveLeaveRequest(c *gin.Context) {
+	decideLeaveRequest(c, true)
+}
+
+// RejectLeaveRequest rejects a pending leave request with a reason.
+func RejectLeaveRequest(c *gin.Context) {
+	decideLeaveRequest(c, false)
+}
+
+// GetMyLeaveBalances returns the authenticated employee's balance of every leave type.
+func GetMyLeaveBalances(c *gin.Context) {
+	balances, err := services.LeaveBalances(c.GetUint("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve leave balances"})
+		return
+	}
+	c.JSON(http.StatusOK, balances)
+}
+
+// ListMyLeaveRequests returns the authenticated employee's leave requests, newest first.
+func ListMyLeaveRequests(c *gin.Context) {
+	var requests []models.LeaveRequest
+	if err := database.DB.Preload("LeaveType").Where("employee_id = ?", c.GetUint("user_id")).
+		Order("start_date desc").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve leave requests"})
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// SubmitLeaveRequest requests leave of a type over a range of dates, both inclusive.
+func SubmitLeaveRequest(c *gin.Context) {
+	var input struct {
+		LeaveTypeID uint   `json:"leaveTypeId" binding:"required"`
+		StartDate   string `json:"startDate" binding:"required"` // "YYYY-MM-DD"
+		EndDate     string `json:"endDate" binding:"required"`   // "YYYY-MM-DD"
+		Reason      string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	startDate, err := time.Parse("2006-01-02", input.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+		return
+	}
+	endDate, err := time.Parse("2006-01-02", input.EndDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+		return
+	}
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, c.GetUint("user_id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+
+	request, err := services.RequestLeave(employee, input.LeaveTypeID, startDate, endDate, input.Reason, c.GetString("request_ip"))
+	if err != nil {
+		respondLeaveError(c, err, "Failed to submit leave request.")
+		return
+	}
+	c.JSON(http.StatusCreated, request)
+}
+
+// CancelLeaveRequest withdraws one of the authenticated employee's pending requests.
+func CancelLeaveRequest(c *gin.Context) {
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid leave request id"})
+		return
+	}
+
+	request, err := services.CancelLeaveRequest(c.GetUint("user_id"), uint(requestID))
+	if err != nil {
+		respondLeaveError(c, err, "Failed to cancel leave request.")
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
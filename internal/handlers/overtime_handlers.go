@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListOvertimePolicies returns every overtime policy version, newest first.
+func ListOvertimePolicies(c *gin.Context) {
+	var policies []models.OvertimePolicy
+	err := database.DB.
+		Preload("Tiers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("effective_from desc").
+		Find(&policies).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve overtime policies"})
+		return
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+// CreateOvertimePolicy adds a new overtime policy version effective from the given date.
+// Existing versions are never modified so historical periods recompute identically.
+func CreateOvertimePolicy(c *gin.Context) {
+	var input struct {
+		Name               string  `json:"name" binding:"required"`
+		EffectiveFrom      string  `json:"effectiveFrom" binding:"required"` // "YYYY-MM-DD"
+		DailyLimitHours    float64 `json:"dailyLimitHours" binding:"required"`
+		MonthlyCapHours    float64 `json:"monthlyCapHours"`
+		EarliestSubmission string  `json:"earliestSubmission"`
+		Tiers              []struct {
+			DayType    string  `json:"dayType"`
+			UpToHours  float64 `json:"upToHours"`
+			Multiplier float64 `json:"multiplier"`
+		} `json:"tiers" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	effectiveFrom, err := time.Parse("2006-01-02", input.EffectiveFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	policy := models.OvertimePolicy{
+		Name:               input.Name,
+		EffectiveFrom:      effectiveFrom,
+		DailyLimitHours:    input.DailyLimitHours,
+		MonthlyCapHours:    input.MonthlyCapHours,
+		EarliestSubmission: input.EarliestSubmission,
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	for _, t := range input.Tiers {
+		policy.Tiers = append(policy.Tiers, models.OvertimeTier{DayType: t.DayType, UpToHours: t.UpToHours, Multiplier: t.Multiplier})
+	}
+
+	if err := services.ValidateOvertimePolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&policy).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create overtime policy. A version may already take effect on this date."})
+		return
+	}
+
+	details := fmt.Sprintf("Created overtime policy ID %d %q effective from %s.", policy.ID, policy.Name, input.EffectiveFrom)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_OVERTIME_POLICY", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+// GetOvertimePolicy returns a single overtime policy version with its tiers.
+func GetOvertimePolicy(c *gin.Context) {
+	policyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid overtime policy id"})
+		return
+	}
+
+	var policy models.OvertimePolicy
+	err = database.DB.
+		Preload("Tiers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&policy, policyID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Overtime policy not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve overtime policy."})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"payslip-generator/internal/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// payComponentInput is the request body for creating or updating a recurring pay component.
+type payComponentInput struct {
+	Code        string       `json:"code" binding:"required"`
+	Description string       `json:"description"`
+	Type        string       `json:"type" binding:"required"`
+	Calculation string       `json:"calculation" binding:"required"`
+	Amount      money.Amount `json:"amount"`
+	Percentage  float64      `json:"percentage"`
+	Taxable     bool         `json:"taxable"`
+	StartDate   string       `json:"startDate" binding:"required"` // "YYYY-MM-DD"
+	EndDate     string       `json:"endDate"`                      // Optional, "YYYY-MM-DD"
+}
+
+// apply copies the input onto the component, parsing the dates.
+func (input payComponentInput) apply(component *models.RecurringPayComponent) error {
+	startDate, err := time.Parse("2006-01-02", input.StartDate)
+	if err != nil {
+		return err
+	}
+	var endDate *time.Time
+	if input.EndDate != "" {
+		parsed, err := time.Parse("2006-01-02", input.EndDate)
+		if err != nil {
+			return err
+		}
+		endDate = &parsed
+	}
+
+	component.Code = input.Code
+	component.Description = input.Description
+	component.Type = input.Type
+	component.Calculation = input.Calculation
+	component.Amount = input.Amount
+	component.Percentage = input.Percentage
+	component.Taxable = input.Taxable
+	component.StartDate = startDate
+	component.EndDate = endDate
+	return nil
+}
+
+// findEmployeePayComponent loads a component and checks that it belongs to the employee in the path.
+func findEmployeePayComponent(c *gin.Context) (models.RecurringPayComponent, bool) {
+	var component models.RecurringPayComponent
+	employeeID, err1 := strconv.Atoi(c.Param("id"))
+	componentID, err2 := strconv.Atoi(c.Param("componentId"))
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id or component id"})
+		return component, false
+	}
+
+	err := database.DB.Where("employee_id = ?", employeeID).First(&component, componentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pay component not found."})
+		return component, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pay component."})
+		return component, false
+	}
+	return component, true
+}
+
+// ListEmployeePayComponents returns the recurring allowances and deductions of an employee.
+func ListEmployeePayComponents(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+
+	var components []models.RecurringPayComponent
+	if err := database.DB.Where("employee_id = ?", employeeID).Order("start_date, id").Find(&components).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve pay components"})
+		return
+	}
+	c.JSON(http.StatusOK, components)
+}
+
+// CreateEmployeePayComponent assigns a recurring allowance or deduction to an employee.
+func CreateEmployeePayComponent(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+
+	var input payComponentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	component := models.RecurringPayComponent{
+		EmployeeID: employee.ID,
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	if err := input.apply(&component); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+		return
+	}
+	if err := services.ValidateRecurringPayComponent(component); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&component).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pay component."})
+		return
+	}
+
+	details := fmt.Sprintf("Assigned %s %s (ID %d) to employee ID %d.", component.Type, component.Code, component.ID, employee.ID)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_PAY_COMPONENT", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, component)
+}
+
+// UpdateEmployeePayComponent replaces the settings of a recurring pay component.
+func UpdateEmployeePayComponent(c *gin.Context) {
+	component, ok := findEmployeePayComponent(c)
+	if !ok {
+		return
+	}
+
+	var input payComponentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := input.apply(&component); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+		return
+	}
+	if err := services.ValidateRecurringPayComponent(component); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	component.UpdatedByID = adminID
+	component.RequestIP = c.GetString("request_ip")
+	if err := database.DB.Save(&component).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update pay component."})
+		return
+	}
+
+	details := fmt.Sprintf("Updated pay component %s (ID %d) of employee ID %d.", component.Code, component.ID, component.EmployeeID)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_PAY_COMPONENT", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, component)
+}
+
+// DeleteEmployeePayComponent removes a recurring pay component. Payslips already
+// generated keep their line items; to stop a component from a date on, set its end date instead.
+func DeleteEmployeePayComponent(c *gin.Context) {
+	component, ok := findEmployeePayComponent(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(&component).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete pay component."})
+		return
+	}
+
+	details := fmt.Sprintf("Deleted pay component %s (ID %d) of employee ID %d.", component.Code, component.ID, component.EmployeeID)
+	go services.CreateAuditLog(c.GetUint("user_id"), services.UserTypeAdmin, "DELETED_PAY_COMPONENT", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Pay component deleted."})
+}
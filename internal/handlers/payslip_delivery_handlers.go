@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/mail"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListPayslipDeliveries returns the email deliveries of a period's payslips,
+// newest first, optionally only those with the status query parameter.
+func ListPayslipDeliveries(c *gin.Context) {
+	periodID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payroll period id"})
+		return
+	}
+
+	query := database.DB.Where("payroll_period_id = ?", periodID).Order("id desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if employeeID := c.Query("employee_id"); employeeID != "" {
+		query = query.Where("employee_id = ?", employeeID)
+	}
+	var deliveries []models.PayslipDelivery
+	if err := query.Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve payslip deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// SendPayslips queues the emailing of a period's payslips that haven't been
+// sent or queued yet, e.g. after fixing the addresses of those that bounced.
+func SendPayslips(c *gin.Context) {
+	periodID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payroll period id"})
+		return
+	}
+	if mail.Outgoing == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	deliveries, err := services.QueuePayslipDeliveries(uint(periodID), adminID, c.GetString("request_ip"))
+	switch {
+	case errors.Is(err, services.ErrPeriodNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll period not found."})
+		return
+	case errors.Is(err, services.ErrPayrollNotRun):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue payslip emails."})
+		return
+	}
+
+	details := fmt.Sprintf("Queued %d payslip emails for payroll period ID %d.", len(deliveries), periodID)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "QUEUED_PAYSLIP_EMAILS", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusAccepted, deliveries)
+}
+
+// ResendPayslip queues a payslip delivery's payslip again, to the employee's
+// current email address.
+func ResendPayslip(c *gin.Context) {
+	deliveryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payslip delivery id"})
+		return
+	}
+	if mail.Outgoing == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not configured."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	delivery, err := services.ResendPayslipDelivery(uint(deliveryID), adminID, c.GetString("request_ip"))
+	switch {
+	case errors.Is(err, services.ErrDeliveryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payslip delivery not found."})
+		return
+	case errors.Is(err, services.ErrPayslipNotFound):
+		c.JSON(http.StatusConflict, gin.H{"error": "The payslip has been voided."})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue payslip email."})
+		return
+	}
+
+	details := fmt.Sprintf("Queued payslip ID %d to be emailed again to %q (delivery ID %d).", delivery.PayslipID, delivery.Recipient, delivery.ID)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "RESENT_PAYSLIP", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// UpdateEmployeeEmail sets the address an employee's payslips are emailed to.
+func UpdateEmployeeEmail(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+
+	var input struct {
+		Email string `json:"email" binding:"omitempty,email"` // Empty to remove it
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	updates := map[string]interface{}{"email": input.Email, "updated_by_id": adminID}
+	if err := database.DB.Model(&employee).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update employee email."})
+		return
+	}
+
+	details := fmt.Sprintf("Set email of employee ID %d to %q.", employee.ID, input.Email)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_EMPLOYEE_EMAIL", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, employee)
+}
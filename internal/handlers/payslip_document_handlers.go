@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"payslip-generator/internal/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadPayslipPDF renders the caller's payslip for a period as a PDF.
+func DownloadPayslipPDF(c *gin.Context) {
+	servePayslipPDF(c, c.GetUint("user_id"))
+}
+
+// DownloadEmployeePayslipPDF renders any employee's payslip for a period as a PDF.
+func DownloadEmployeePayslipPDF(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+	servePayslipPDF(c, uint(employeeID))
+}
+
+// servePayslipPDF answers with the employee's payslip for the period_id query
+// parameter as a PDF attachment.
+func servePayslipPDF(c *gin.Context, employeeID uint) {
+	periodID, err := strconv.Atoi(c.Query("period_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or invalid period_id query parameter"})
+		return
+	}
+
+	doc, err := services.LoadPayslipDocument(employeeID, uint(periodID))
+	if errors.Is(err, services.ErrPayslipNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Payslip for this period not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payslip."})
+		return
+	}
+
+	var out bytes.Buffer
+	if err := services.RenderPayslipPDF(doc, &out); errors.Is(err, services.ErrNoDocumentPassword) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The payslip must be encrypted, but the employee's details its password is derived from are missing."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render payslip."})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName("pdf")}))
+	c.Data(http.StatusOK, "application/pdf", out.Bytes())
+}
+
+// servePayslipHTML answers with the employee's payslip for a period rendered
+// with the payslip template of their group.
+func servePayslipHTML(c *gin.Context, employeeID, periodID uint) {
+	doc, err := services.LoadPayslipDocument(employeeID, periodID)
+	if errors.Is(err, services.ErrPayslipNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Payslip for this period not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payslip."})
+		return
+	}
+
+	var out bytes.Buffer
+	if err := services.RenderPayslipHTML(doc, &out); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render payslip."})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", out.Bytes())
+}
+
+// ExportPayslips streams the PDF payslips of a payroll period as a ZIP
+// archive, with a manifest of their checksums.
+func ExportPayslips(c *gin.Context) {
+	periodID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payroll period id"})
+		return
+	}
+
+	export, err := services.PreparePayslipExport(uint(periodID))
+	switch {
+	case errors.Is(err, services.ErrPeriodNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payroll period not found."})
+		return
+	case errors.Is(err, services.ErrPayrollNotRun):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payslips."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	details := fmt.Sprintf("Exported the payslips of payroll period ID %d.", periodID)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "EXPORTED_PAYSLIPS", details, c.GetString("request_ip"))
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.FileName()}))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	if err := export.Write(c.Writer); err != nil {
+		// The status is already sent. The archive is cut short without its
+		// central directory, so it can't be mistaken for a complete one.
+		log.Printf("[Payslip Export] Error exporting payslips of period ID %d: %v", periodID, err)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListPayslipProtections returns the payslip protection of every employee group that has one.
+func ListPayslipProtections(c *gin.Context) {
+	var protections []models.PayslipProtection
+	if err := database.DB.Order("\"group\"").Find(&protections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve payslip protections"})
+		return
+	}
+	c.JSON(http.StatusOK, protections)
+}
+
+// UpdatePayslipProtection sets how the payslip documents of an employee group
+// are encrypted, creating the group's protection if it has none.
+func UpdatePayslipProtection(c *gin.Context) {
+	var input struct {
+		Group        string `json:"group"` // Empty for employees whose group has no protection of its own
+		Scheme       string `json:"scheme" binding:"required"`
+		PasswordRule string `json:"passwordRule"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var protection models.PayslipProtection
+	err := database.DB.Where("\"group\" = ?", input.Group).First(&protection).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payslip protection."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	if protection.ID == 0 {
+		protection.CreatedByID = adminID
+	}
+	protection.Group = input.Group
+	protection.Scheme = input.Scheme
+	protection.PasswordRule = input.PasswordRule
+	protection.UpdatedByID = adminID
+	protection.RequestIP = c.GetString("request_ip")
+	if err := services.ValidatePayslipProtection(protection); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := database.DB.Save(&protection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save payslip protection."})
+		return
+	}
+
+	details := fmt.Sprintf("Set payslip protection of group %q to %s with rule %q.", protection.Group, protection.Scheme, protection.PasswordRule)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_PAYSLIP_PROTECTION", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, protection)
+}
+
+// UpdateEmployeePersonalDetails sets the employee number and date of birth
+// that document passwords can be derived from.
+func UpdateEmployeePersonalDetails(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+
+	var input struct {
+		EmployeeNumber string `json:"employeeNumber"`
+		DateOfBirth    string `json:"dateOfBirth"` // YYYY-MM-DD, or empty to clear it
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var dateOfBirth *time.Time
+	if input.DateOfBirth != "" {
+		date, err := time.Parse("2006-01-02", input.DateOfBirth)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format for dateOfBirth. Use YYYY-MM-DD."})
+			return
+		}
+		dateOfBirth = &date
+	}
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	updates := map[string]interface{}{
+		"employee_number": input.EmployeeNumber,
+		"date_of_birth":   dateOfBirth,
+		"updated_by_id":   adminID,
+	}
+	if err := database.DB.Model(&employee).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update personal details."})
+		return
+	}
+
+	details := fmt.Sprintf("Updated employee number and date of birth of employee ID %d.", employee.ID)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_PERSONAL_DETAILS", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, employee)
+}
+
+// SetDocumentPassword sets the password that opens the caller's payslip
+// documents, for groups whose documents are protected with passwords
+// employees choose.
+func SetDocumentPassword(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	employeeID := c.GetUint("user_id")
+	err := services.SetEmployeeDocumentPassword(employeeID, input.Password)
+	if errors.Is(err, services.ErrInvalidDocumentPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if errors.Is(err, services.ErrDocumentPasswordNotAllowed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Your payslip documents are not protected with a password you choose."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set document password."})
+		return
+	}
+
+	go services.CreateAuditLog(employeeID, services.UserTypeEmployee, "SET_DOCUMENT_PASSWORD", "Set payslip document password.", c.GetString("request_ip"))
+	c.JSON(http.StatusOK, gin.H{"message": "Document password set."})
+}
+
+// ClearDocumentPassword removes the caller's document password, so that their
+// payslip documents are protected with the password derived by their group's rule.
+func ClearDocumentPassword(c *gin.Context) {
+	employeeID := c.GetUint("user_id")
+	if err := services.SetEmployeeDocumentPassword(employeeID, ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear document password."})
+		return
+	}
+
+	go services.CreateAuditLog(employeeID, services.UserTypeEmployee, "CLEARED_DOCUMENT_PASSWORD", "Cleared payslip document password.", c.GetString("request_ip"))
+	c.JSON(http.StatusOK, gin.H{"message": "Document password cleared."})
+}
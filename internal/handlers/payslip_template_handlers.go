@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// payslipTemplateInput is the request body for creating a payslip template or
+// adding a version to one.
+type payslipTemplateInput struct {
+	Name  string `json:"name" binding:"required"`
+	Group string `json:"group"` // Empty for employees whose group has no template
+	Body  string `json:"body" binding:"required"`
+}
+
+// ListPayslipTemplates returns every payslip template with its version
+// numbers, newest first, without their sources.
+func ListPayslipTemplates(c *gin.Context) {
+	var templates []models.PayslipTemplate
+	err := database.DB.
+		Preload("Versions", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "created_at", "payslip_template_id", "version", "created_by_id").Order("version desc")
+		}).
+		Order("name").
+		Find(&templates).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve payslip templates"})
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+// CreatePayslipTemplate creates a payslip template for an employee group with
+// its first version.
+func CreatePayslipTemplate(c *gin.Context) {
+	var input payslipTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	tmpl := models.PayslipTemplate{
+		Name:  input.Name,
+		Group: input.Group,
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&tmpl).Error; err != nil {
+			return err
+		}
+		version, err := services.AddPayslipTemplateVersion(tx, tmpl.ID, input.Body, adminID, c.GetString("request_ip"))
+		tmpl.Versions = []models.PayslipTemplateVersion{version}
+		return err
+	})
+	if errors.Is(err, services.ErrInvalidPayslipTemplate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create payslip template. The name or group may already be taken."})
+		return
+	}
+
+	details := fmt.Sprintf("Created payslip template ID %d %q for group %q.", tmpl.ID, tmpl.Name, tmpl.Group)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_PAYSLIP_TEMPLATE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, tmpl)
+}
+
+// GetPayslipTemplate returns a payslip template with the source of every version, newest first.
+func GetPayslipTemplate(c *gin.Context) {
+	tmpl, ok := findPayslipTemplate(c)
+	if !ok {
+		return
+	}
+	if err := database.DB.Where("payslip_template_id = ?", tmpl.ID).Order("version desc").Find(&tmpl.Versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payslip template versions."})
+		return
+	}
+	c.JSON(http.StatusOK, tmpl)
+}
+
+// UpdatePayslipTemplate renames or regroups a payslip template and adds its
+// new source as the next version. Earlier versions are kept, so reverting is
+// a matter of submitting an earlier source again.
+func UpdatePayslipTemplate(c *gin.Context) {
+	tmpl, ok := findPayslipTemplate(c)
+	if !ok {
+		return
+	}
+	var input payslipTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	tmpl.Name = input.Name
+	tmpl.Group = input.Group
+	tmpl.UpdatedByID = adminID
+	tmpl.RequestIP = c.GetString("request_ip")
+	var version models.PayslipTemplateVersion
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&tmpl).Error; err != nil {
+			return err
+		}
+		var err error
+		version, err = services.AddPayslipTemplateVersion(tx, tmpl.ID, input.Body, adminID, c.GetString("request_ip"))
+		return err
+	})
+	if errors.Is(err, services.ErrInvalidPayslipTemplate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to update payslip template. The name or group may already be taken."})
+		return
+	}
+	tmpl.Versions = []models.PayslipTemplateVersion{version}
+
+	details := fmt.Sprintf("Added version %d of payslip template ID %d %q for group %q.", version.Version, tmpl.ID, tmpl.Name, tmpl.Group)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_PAYSLIP_TEMPLATE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, tmpl)
+}
+
+// PreviewPayslipTemplateSource renders a template source from the request
+// body against sample data, without saving it.
+func PreviewPayslipTemplateSource(c *gin.Context) {
+	var input struct {
+		Body string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	servePayslipTemplatePreview(c, input.Body)
+}
+
+// PreviewPayslipTemplate renders a saved version of a payslip template
+// against sample data: the latest one, or that of the version query parameter.
+func PreviewPayslipTemplate(c *gin.Context) {
+	tmpl, ok := findPayslipTemplate(c)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Where("payslip_template_id = ?", tmpl.ID).Order("version desc")
+	if v := c.Query("version"); v != "" {
+		number, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+		query = query.Where("version = ?", number)
+	}
+	var version models.PayslipTemplateVersion
+	if err := query.First(&version).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payslip template version not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payslip template version."})
+		return
+	}
+	servePayslipTemplatePreview(c, version.Body)
+}
+
+// servePayslipTemplatePreview answers with a template source rendered against
+// the sample payslip as an HTML page.
+func servePayslipTemplatePreview(c *gin.Context, body string) {
+	var out bytes.Buffer
+	if err := services.RenderPayslipTemplate(body, services.SamplePayslipDocument(), &out); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", out.Bytes())
+}
+
+// findPayslipTemplate loads the payslip template in the :id path parameter,
+// answering 400 or 404 itself if there is none.
+func findPayslipTemplate(c *gin.Context) (models.PayslipTemplate, bool) {
+	var tmpl models.PayslipTemplate
+	templateID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payslip template id"})
+		return tmpl, false
+	}
+	if err := database.DB.First(&tmpl, templateID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payslip template not found."})
+		return tmpl, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve payslip template."})
+		return tmpl, false
+	}
+	return tmpl, true
+}
+
+// UpdateEmployeeGroup sets the group that selects an employee's payslip template.
+func UpdateEmployeeGroup(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+
+	var input struct {
+		Group string `json:"group"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	updates := map[string]interface{}{"group": input.Group, "updated_by_id": adminID}
+	if err := database.DB.Model(&employee).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update employee group."})
+		return
+	}
+
+	details := fmt.Sprintf("Set group of employee ID %d to %q.", employee.ID, input.Group)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_EMPLOYEE_GROUP", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, employee)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/services"
+	"payslip-generator/internal/storage"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxReceiptRequestSize bounds the body of a request carrying receipts: the
+// largest allowed files plus room for the other form fields.
+const maxReceiptRequestSize = services.MaxReceiptsPerClaim*services.MaxReceiptSize + 1<<20
+
+// receiptUploads opens and checks the files sent in the "receipts" form field,
+// answering 400 itself if any is unacceptable. The returned function closes the files.
+func receiptUploads(c *gin.Context) ([]services.ReceiptUpload, func(), bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReceiptRequestSize)
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read the multipart form: " + err.Error()})
+		return nil, nil, false
+	}
+	headers := form.File["receipts"]
+	if len(headers) > services.MaxReceiptsPerClaim {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At most " + strconv.Itoa(services.MaxReceiptsPerClaim) + " receipts can be attached."})
+		return nil, nil, false
+	}
+
+	var files []multipart.File
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	uploads := make([]services.ReceiptUpload, 0, len(headers))
+	for _, h := range headers {
+		f, err := h.Open()
+		if err != nil {
+			closeFiles()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read " + h.Filename})
+			return nil, nil, false
+		}
+		files = append(files, f)
+		upload := services.ReceiptUpload{FileName: h.Filename, Size: h.Size, File: f}
+		if _, _, err := services.CheckReceipt(upload); err != nil {
+			closeFiles()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, nil, false
+		}
+		uploads = append(uploads, upload)
+	}
+	return uploads, closeFiles, true
+}
+
+// UploadReimbursementReceipts attaches receipts to one of the employee's own pending reimbursements.
+func UploadReimbursementReceipts(c *gin.Context) {
+	reimbursementID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reimbursement id"})
+		return
+	}
+	employeeID := c.GetUint("user_id")
+
+	var reimbursement models.Reimbursement
+	err = database.DB.Where("id = ? AND employee_id = ?", reimbursementID, employeeID).First(&reimbursement).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reimbursement not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reimbursement."})
+		return
+	}
+	if reimbursement.Status != models.ClaimPending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Receipts can only be added while the claim is pending."})
+		return
+	}
+
+	uploads, closeUploads, ok := receiptUploads(c)
+	if !ok {
+		return
+	}
+	defer closeUploads()
+	if len(uploads) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No files were sent in the receipts field."})
+		return
+	}
+
+	receipts, err := services.AttachReceipts(c.Request.Context(), reimbursement.ID, employeeID, uploads, c.GetString("request_ip"))
+	if errors.Is(err, services.ErrInvalidReceipt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store receipts."})
+		return
+	}
+	c.JSON(http.StatusCreated, receipts)
+}
+
+// DownloadMyReceipt sends a receipt of one of the employee's own reimbursements.
+func DownloadMyReceipt(c *gin.Context) {
+	reimbursementID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reimbursement id"})
+		return
+	}
+	var count int64
+	if err := database.DB.Model(&models.Reimbursement{}).
+		Where("id = ? AND employee_id = ?", reimbursementID, c.GetUint("user_id")).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reimbursement."})
+		return
+	}
+	if count == 0 {
+		// Other employees' claims are indistinguishable from missing ones.
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found."})
+		return
+	}
+	serveReceipt(c, uint(reimbursementID))
+}
+
+// DownloadReceipt sends a receipt of any reimbursement.
+func DownloadReceipt(c *gin.Context) {
+	reimbursementID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reimbursement id"})
+		return
+	}
+	serveReceipt(c, uint(reimbursementID))
+}
+
+// serveReceipt streams the receipt in the path from storage, with its
+// checksum so the download can be verified.
+func serveReceipt(c *gin.Context, reimbursementID uint) {
+	receiptID, err := strconv.Atoi(c.Param("receiptId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt id"})
+		return
+	}
+
+	receipt, file, err := services.OpenReceipt(c.Request.Context(), reimbursementID, uint(receiptID))
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve receipt."})
+		return
+	}
+	defer file.Close()
+
+	c.DataFromReader(http.StatusOK, receipt.Size, receipt.ContentType, file, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": receipt.FileName}),
+		"X-Checksum-SHA256":   receipt.SHA256,
+	})
+}
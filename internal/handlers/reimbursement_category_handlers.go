@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"payslip-generator/internal/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// reimbursementCategoryInput is the request body for creating or replacing a reimbursement category.
+type reimbursementCategoryInput struct {
+	Code            string       `json:"code" binding:"required"`
+	Name            string       `json:"name" binding:"required"`
+	Currency        string       `json:"currency" binding:"required"`
+	ClaimLimit      money.Amount `json:"claimLimit"`
+	PeriodLimit     money.Amount `json:"periodLimit"`
+	RequiredFields  string       `json:"requiredFields"`
+	OverLimitAction string       `json:"overLimitAction"` // Defaults to "reject"
+}
+
+// bindReimbursementCategory reads and validates a reimbursementCategoryInput
+// into category, answering 400 itself on failure.
+func bindReimbursementCategory(c *gin.Context, category *models.ReimbursementCategory) bool {
+	var input reimbursementCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	category.Code = strings.ToUpper(input.Code)
+	category.Name = input.Name
+	category.Currency = strings.ToUpper(input.Currency)
+	category.ClaimLimit = input.ClaimLimit
+	category.PeriodLimit = input.PeriodLimit
+	category.RequiredFields = input.RequiredFields
+	category.OverLimitAction = input.OverLimitAction
+	if category.OverLimitAction == "" {
+		category.OverLimitAction = models.OverLimitReject
+	}
+	if err := services.ValidateReimbursementCategory(*category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// ListReimbursementCategories returns every reimbursement category.
+func ListReimbursementCategories(c *gin.Context) {
+	var categories []models.ReimbursementCategory
+	if err := database.DB.Order("code").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve reimbursement categories"})
+		return
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+// CreateReimbursementCategory creates a reimbursement category.
+func CreateReimbursementCategory(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+	category := models.ReimbursementCategory{
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	if !bindReimbursementCategory(c, &category) {
+		return
+	}
+
+	if err := database.DB.Create(&category).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create reimbursement category. The code may already be taken."})
+		return
+	}
+
+	details := fmt.Sprintf("Created reimbursement category ID %d %s.", category.ID, category.Code)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_REIMBURSEMENT_CATEGORY", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateReimbursementCategory replaces a category's settings. Claims already
+// submitted keep the flags they were given.
+func UpdateReimbursementCategory(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reimbursement category id"})
+		return
+	}
+
+	var category models.ReimbursementCategory
+	if err := database.DB.First(&category, categoryID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reimbursement category not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reimbursement category."})
+		return
+	}
+	if !bindReimbursementCategory(c, &category) {
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	category.UpdatedByID = adminID
+	category.RequestIP = c.GetString("request_ip")
+	if err := database.DB.Save(&category).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to update reimbursement category. The code may already be taken."})
+		return
+	}
+
+	details := fmt.Sprintf("Updated reimbursement category ID %d %s.", category.ID, category.Code)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_REIMBURSEMENT_CATEGORY", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, category)
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// roleResponse is the JSON shape of a role with its permission codes flattened.
+type roleResponse struct {
+	ID          uint     `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+func toRoleResponse(role models.Role) roleResponse {
+	codes := []string{}
+	for _, p := range role.Permissions {
+		codes = append(codes, p.Permission)
+	}
+	return roleResponse{ID: role.ID, Name: role.Name, Description: role.Description, Permissions: codes}
+}
+
+// ListPermissions returns every permission code that can be granted to a role.
+func ListPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, services.AllPermissions)
+}
+
+// ListRoles returns all roles with their permissions.
+func ListRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := database.DB.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve roles"})
+		return
+	}
+
+	response := []roleResponse{}
+	for _, r := range roles {
+		response = append(response, toRoleResponse(r))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateRole defines a new role with a set of permissions.
+func CreateRole(c *gin.Context) {
+	var input struct {
+		Name        string   `json:"name" binding:"required"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidatePermissions(input.Permissions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	role := models.Role{
+		Name:        input.Name,
+		Description: input.Description,
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	for _, code := range input.Permissions {
+		role.Permissions = append(role.Permissions, models.RolePermission{Permission: code})
+	}
+
+	if err := database.DB.Create(&role).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create role. The name may already be taken."})
+		return
+	}
+
+	details := fmt.Sprintf("Created role %q with permissions %v.", role.Name, input.Permissions)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_ROLE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, toRoleResponse(role))
+}
+
+// UpdateRole replaces the description and permissions of a role.
+func UpdateRole(c *gin.Context) {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role id"})
+		return
+	}
+
+	var input struct {
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidatePermissions(input.Permissions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var role models.Role
+	if err := database.DB.First(&role, roleID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"description": input.Description, "updated_by_id": adminID}
+		if err := tx.Model(&role).Updates(updates).Error; err != nil {
+			return err
+		}
+		return services.SetRolePermissions(tx, role.ID, input.Permissions)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role."})
+		return
+	}
+
+	database.DB.Preload("Permissions").First(&role, role.ID)
+
+	details := fmt.Sprintf("Updated role %q with permissions %v.", role.Name, input.Permissions)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_ROLE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, toRoleResponse(role))
+}
+
+// DeleteRole removes a role and revokes it from every admin holding it.
+func DeleteRole(c *gin.Context) {
+	roleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role id"})
+		return
+	}
+
+	var role models.Role
+	if err := database.DB.First(&role, roleID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve role."})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM admin_roles WHERE role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role."})
+		return
+	}
+
+	details := fmt.Sprintf("Deleted role %q.", role.Name)
+	go services.CreateAuditLog(c.GetUint("user_id"), services.UserTypeAdmin, "DELETED_ROLE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted."})
+}
+
+// AssignAdminRoles replaces the set of roles held by an admin.
+func AssignAdminRoles(c *gin.Context) {
+	targetID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid admin id"})
+		return
+	}
+
+	var input struct {
+		RoleIDs []uint `json:"roleIds" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var target models.Admin
+	if err := database.DB.First(&target, targetID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Admin not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve admin."})
+		return
+	}
+
+	roles := []models.Role{}
+	if len(input.RoleIDs) > 0 {
+		if err := database.DB.Where("id IN ?", input.RoleIDs).Find(&roles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve roles."})
+			return
+		}
+	}
+	if len(roles) != len(input.RoleIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One or more roles do not exist."})
+		return
+	}
+
+	if err := database.DB.Model(&target).Association("Roles").Replace(roles); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign roles."})
+		return
+	}
+
+	names := []string{}
+	for _, r := range roles {
+		names = append(names, r.Name)
+	}
+	details := fmt.Sprintf("Assigned roles %v to admin ID %d.", names, target.ID)
+	go services.CreateAuditLog(c.GetUint("user_id"), services.UserTypeAdmin, "ASSIGNED_ROLES", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, gin.H{"adminId": target.ID, "roles": names})
+}
@@ -2,10 +2,11 @@ package handlers
 
 import (
 	"fmt"
-	"math"
 	"net/http"
 	"payslip-generator/internal/database"
 	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"payslip-generator/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -18,13 +19,29 @@ func SeedDatabase(c *gin.Context) {
 	admin := models.Admin{Username: "admin", Password: string(hashedPassword)}
 	database.DB.FirstOrCreate(&admin, "username = ?", "admin")
 
+	// Seed the built-in roles and grant the seeded admin full access
+	if err := services.EnsureDefaultRoles(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to seed roles."})
+		return
+	}
+	var administrator models.Role
+	database.DB.Where("name = ?", services.RoleAdministrator).First(&administrator)
+	database.DB.Model(&admin).Association("Roles").Append(&administrator)
+
+	// Seed the built-in leave types
+	if err := services.EnsureDefaultLeaveTypes(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to seed leave types."})
+		return
+	}
+
 	// Seed Employees - Password is the same as the username (e.g., "employee1")
 	for i := 0; i < 100; i++ {
 		username := fmt.Sprintf("employee%d", i+1)
 		var employee models.Employee
 		err := database.DB.FirstOrInit(&employee, models.Employee{Username: username}).Error
 		if err == nil && employee.ID == 0 { // Only create if it doesn't exist
-			employee.Salary = math.Round(5000000 + (float64(i) * 100000))
+			employee.Salary = money.FromUnits(5000000 + int64(i)*100000)
+			employee.Email = username + "@example.com"
 
 			// Set password to be the same as the username
 			pass, _ := bcrypt.GenerateFromPassword([]byte(username), bcrypt.DefaultCost)
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"payslip-generator/internal/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListTaxTables returns every tax table version, newest first.
+func ListTaxTables(c *gin.Context) {
+	var tables []models.TaxTable
+	err := database.DB.
+		Preload("Brackets", func(db *gorm.DB) *gorm.DB { return db.Order("lower_bound") }).
+		Order("effective_from desc").
+		Find(&tables).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve tax tables"})
+		return
+	}
+	c.JSON(http.StatusOK, tables)
+}
+
+// CreateTaxTable adds a new tax table version effective from the given date.
+// Existing versions are never modified so historical periods recompute identically.
+func CreateTaxTable(c *gin.Context) {
+	var input struct {
+		Name               string       `json:"name" binding:"required"`
+		EffectiveFrom      string       `json:"effectiveFrom" binding:"required"` // "YYYY-MM-DD"
+		Basis              string       `json:"basis" binding:"required"`
+		Currency           string       `json:"currency"` // Defaults to models.DefaultCurrency
+		PersonalAllowance  money.Amount `json:"personalAllowance"`
+		SpouseAllowance    money.Amount `json:"spouseAllowance"`
+		DependentAllowance money.Amount `json:"dependentAllowance"`
+		MaxDependents      int          `json:"maxDependents"`
+		Brackets           []struct {
+			LowerBound money.Amount `json:"lowerBound"`
+			UpperBound money.Amount `json:"upperBound"`
+			Rate       float64      `json:"rate"`
+		} `json:"brackets" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	effectiveFrom, err := time.Parse("2006-01-02", input.EffectiveFrom)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	table := models.TaxTable{
+		Name:               input.Name,
+		EffectiveFrom:      effectiveFrom,
+		Basis:              input.Basis,
+		Currency:           strings.ToUpper(input.Currency),
+		PersonalAllowance:  input.PersonalAllowance,
+		SpouseAllowance:    input.SpouseAllowance,
+		DependentAllowance: input.DependentAllowance,
+		MaxDependents:      input.MaxDependents,
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	if table.Currency == "" {
+		table.Currency = models.DefaultCurrency
+	}
+	for _, b := range input.Brackets {
+		table.Brackets = append(table.Brackets, models.TaxBracket{LowerBound: b.LowerBound, UpperBound: b.UpperBound, Rate: b.Rate})
+	}
+
+	if err := services.ValidateTaxTable(table); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&table).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create tax table. A version may already take effect on this date."})
+		return
+	}
+
+	details := fmt.Sprintf("Created tax table ID %d %q effective from %s.", table.ID, table.Name, input.EffectiveFrom)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_TAX_TABLE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, table)
+}
+
+// GetTaxTable returns a single tax table version with its brackets.
+func GetTaxTable(c *gin.Context) {
+	tableID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax table id"})
+		return
+	}
+
+	var table models.TaxTable
+	err = database.DB.
+		Preload("Brackets", func(db *gorm.DB) *gorm.DB { return db.Order("lower_bound") }).
+		First(&table, tableID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tax table not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tax table."})
+		return
+	}
+	c.JSON(http.StatusOK, table)
+}
+
+// UpdateEmployeeTaxProfile sets the marital status and dependents used for an employee's tax allowance.
+func UpdateEmployeeTaxProfile(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+
+	var input struct {
+		MaritalStatus string `json:"maritalStatus" binding:"required,oneof=single married"`
+		Dependents    *int   `json:"dependents" binding:"required,gte=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	updates := map[string]interface{}{
+		"tax_marital_status": input.MaritalStatus,
+		"tax_dependents":     *input.Dependents,
+		"updated_by_id":      adminID,
+	}
+	if err := database.DB.Model(&employee).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tax profile."})
+		return
+	}
+
+	details := fmt.Sprintf("Set tax profile of employee ID %d to %s with %d dependents.", employee.ID, input.MaritalStatus, *input.Dependents)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_TAX_PROFILE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, employee)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// workScheduleInput is the request body for creating or replacing a work schedule.
+type workScheduleInput struct {
+	Name                   string  `json:"name" binding:"required"`
+	Weekdays               string  `json:"weekdays"`
+	RotationPattern        string  `json:"rotationPattern"`
+	RotationStart          string  `json:"rotationStart"` // "YYYY-MM-DD", required with rotationPattern
+	HoursPerDay            float64 `json:"hoursPerDay" binding:"required"`
+	StartTime              string  `json:"startTime"` // "HH:MM", optional, set together with endTime
+	EndTime                string  `json:"endTime"`
+	OvertimeFromAttendance bool    `json:"overtimeFromAttendance"`
+}
+
+// bindWorkSchedule reads and validates a workScheduleInput into schedule,
+// answering 400 itself on failure.
+func bindWorkSchedule(c *gin.Context, schedule *models.WorkSchedule) bool {
+	var input workScheduleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	schedule.Name = input.Name
+	schedule.Weekdays = strings.ToLower(input.Weekdays)
+	schedule.RotationPattern = strings.ToUpper(input.RotationPattern)
+	schedule.RotationStart = nil
+	schedule.HoursPerDay = input.HoursPerDay
+	schedule.StartTime = input.StartTime
+	schedule.EndTime = input.EndTime
+	schedule.OvertimeFromAttendance = input.OvertimeFromAttendance
+	if input.RotationStart != "" {
+		start, err := time.Parse("2006-01-02", input.RotationStart)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Please use YYYY-MM-DD."})
+			return false
+		}
+		schedule.RotationStart = &start
+	}
+	if err := services.ValidateWorkSchedule(*schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// ListWorkSchedules returns every work schedule.
+func ListWorkSchedules(c *gin.Context) {
+	var schedules []models.WorkSchedule
+	if err := database.DB.Order("name").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve work schedules"})
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+// CreateWorkSchedule creates a weekly or rotating work schedule.
+func CreateWorkSchedule(c *gin.Context) {
+	adminID := c.GetUint("user_id")
+	schedule := models.WorkSchedule{
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   c.GetString("request_ip"),
+		},
+	}
+	if !bindWorkSchedule(c, &schedule) {
+		return
+	}
+
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create work schedule. The name may already be taken."})
+		return
+	}
+
+	details := fmt.Sprintf("Created work schedule ID %d %q.", schedule.ID, schedule.Name)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "CREATED_WORK_SCHEDULE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// UpdateWorkSchedule replaces a schedule's settings. Payslips already
+// generated keep the working days they were calculated with.
+func UpdateWorkSchedule(c *gin.Context) {
+	scheduleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid work schedule id"})
+		return
+	}
+
+	var schedule models.WorkSchedule
+	if err := database.DB.First(&schedule, scheduleID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Work schedule not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve work schedule."})
+		return
+	}
+	if !bindWorkSchedule(c, &schedule) {
+		return
+	}
+
+	adminID := c.GetUint("user_id")
+	schedule.UpdatedByID = adminID
+	schedule.RequestIP = c.GetString("request_ip")
+	if err := database.DB.Save(&schedule).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to update work schedule. The name may already be taken."})
+		return
+	}
+
+	details := fmt.Sprintf("Updated work schedule ID %d %q.", schedule.ID, schedule.Name)
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_WORK_SCHEDULE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// UpdateEmployeeWorkSchedule assigns a work schedule to an employee, or
+// returns them to the standard schedule when workScheduleId is null.
+func UpdateEmployeeWorkSchedule(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid employee id"})
+		return
+	}
+
+	var input struct {
+		WorkScheduleID *uint `json:"workScheduleId"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Employee not found."})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve employee."})
+		return
+	}
+	if input.WorkScheduleID != nil {
+		if err := database.DB.First(&models.WorkSchedule{}, *input.WorkScheduleID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Work schedule not found."})
+			return
+		}
+	}
+
+	adminID := c.GetUint("user_id")
+	updates := map[string]interface{}{
+		"work_schedule_id": input.WorkScheduleID,
+		"updated_by_id":    adminID,
+	}
+	if err := database.DB.Model(&employee).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work schedule."})
+		return
+	}
+
+	details := fmt.Sprintf("Set employee ID %d to the standard work schedule.", employee.ID)
+	if input.WorkScheduleID != nil {
+		details = fmt.Sprintf("Set employee ID %d to work schedule ID %d.", employee.ID, *input.WorkScheduleID)
+	}
+	go services.CreateAuditLog(adminID, services.UserTypeAdmin, "UPDATED_EMPLOYEE_WORK_SCHEDULE", details, c.GetString("request_ip"))
+
+	c.JSON(http.StatusOK, employee)
+}
@@ -0,0 +1,144 @@
+// Package mail sends email, such as payslips to employees, through an SMTP server.
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRejected is returned when the server permanently refuses a message, e.g.
+// for an unknown recipient. Sending it again won't help.
+var ErrRejected = errors.New("rejected by the mail server")
+
+// Attachment is a file attached to a message.
+type Attachment struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// Message is an email with a plain text body.
+type Message struct {
+	To          string // e.g. "jdoe@example.com"
+	Subject     string
+	Text        string
+	Attachments []Attachment
+}
+
+// Sender delivers messages.
+type Sender interface {
+	// Send delivers msg. Errors wrapping ErrRejected are permanent; others
+	// may go away when sending again later.
+	Send(ctx context.Context, msg Message) error
+}
+
+// Outgoing is the sender used by the application, or nil if email is not configured.
+var Outgoing Sender
+
+// SetupMail sets Outgoing to an SMTPSender if SMTP_HOST is set, with:
+//
+//   - SMTP_PORT, 587 if unset.
+//   - SMTP_FROM, the sender's address, e.g. "Payroll <payroll@example.com>".
+//   - SMTP_USERNAME and SMTP_PASSWORD to authenticate, if set.
+//   - SMTP_TLS: "starttls" (the default) upgrades the connection when the server
+//     offers it, "tls" connects with TLS (usually port 465) and "none" never uses it.
+func SetupMail() error {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		Outgoing = nil
+		return nil
+	}
+	config := SMTPConfig{
+		Host:     host,
+		From:     os.Getenv("SMTP_FROM"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		TLS:      os.Getenv("SMTP_TLS"),
+	}
+	if port := os.Getenv("SMTP_PORT"); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP_PORT %q", port)
+		}
+		config.Port = p
+	}
+	sender, err := NewSMTPSender(config)
+	if err != nil {
+		return err
+	}
+	Outgoing = sender
+	return nil
+}
+
+// compose formats the message for sending from the given address, as
+// multipart/mixed with the text first and the attachments base64 encoded.
+func (m Message) compose(from, to *netmail.Address, date time.Time) ([]byte, error) {
+	var out bytes.Buffer
+	messageID := make([]byte, 16)
+	if _, err := rand.Read(messageID); err != nil {
+		return nil, err
+	}
+	body := multipart.NewWriter(&out)
+	domain := from.Address[strings.LastIndexByte(from.Address, '@')+1:]
+	for _, header := range [][2]string{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", "<" + hex.EncodeToString(messageID) + "@" + domain + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": body.Boundary()})},
+	} {
+		fmt.Fprintf(&out, "%s: %s\r\n", header[0], header[1])
+	}
+	out.WriteString("\r\n")
+
+	text, err := body.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(text)
+	qp.Write([]byte(m.Text))
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, a := range m.Attachments {
+		part, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.ContentType, map[string]string{"name": a.FileName})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.FileName})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Base64 in lines of 76 characters, as MIME requires.
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,146 @@
+// Package mailtest provides an SMTP server on the loopback interface that
+// stands in for a real mail server in tests.
+package mailtest
+
+import (
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Message is a message the server accepted.
+type Message struct {
+	From string
+	To   []string
+	Data []byte // As sent, headers included
+}
+
+// Server accepts every message, except for the recipients it is told to reject.
+type Server struct {
+	Host string
+	Port int
+
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	messages []Message
+	rejected map[string]bool
+}
+
+// NewServer starts a server on a random port of 127.0.0.1. Close it when done.
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("mailtest: failed to listen: %v", err))
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	s := &Server{Host: addr.IP.String(), Port: addr.Port, listener: listener, rejected: map[string]bool{}}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Reject makes the server refuse the address as a recipient, as a mail
+// server does for an unknown mailbox.
+func (s *Server) Reject(address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected[strings.ToLower(address)] = true
+}
+
+// Messages returns the messages accepted so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Close stops the server and waits for open connections to finish.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(textproto.NewConn(conn))
+		}()
+	}
+}
+
+// handle speaks enough SMTP for net/smtp: no TLS and no authentication.
+func (s *Server) handle(c *textproto.Conn) {
+	var msg Message
+	c.PrintfLine("220 mailtest ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			c.PrintfLine("250-mailtest")
+			c.PrintfLine("250 8BITMIME")
+		case "HELO", "NOOP":
+			c.PrintfLine("250 OK")
+		case "MAIL":
+			msg = Message{From: address(arg)}
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			to := address(arg)
+			s.mu.Lock()
+			rejected := s.rejected[strings.ToLower(to)]
+			s.mu.Unlock()
+			if rejected {
+				c.PrintfLine("550 5.1.1 %s: mailbox unavailable", to)
+				continue
+			}
+			msg.To = append(msg.To, to)
+			c.PrintfLine("250 OK")
+		case "DATA":
+			if len(msg.To) == 0 {
+				c.PrintfLine("554 No valid recipients")
+				continue
+			}
+			c.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = data
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			c.PrintfLine("250 OK: queued as %s", strconv.Itoa(len(s.Messages())))
+			msg = Message{}
+		case "RSET":
+			msg = Message{}
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// address extracts the address from a "FROM:<a@example.com>" or "TO:<...>" argument.
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ") // Drop parameters such as BODY=8BITMIME
+	return strings.Trim(addr, "<>")
+}
@@ -0,0 +1,136 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// TLS modes of an SMTPSender.
+const (
+	TLSStartTLS = "starttls" // Upgrade the connection if the server offers STARTTLS
+	TLSImplicit = "tls"      // Connect with TLS
+	TLSNone     = "none"     // Never use TLS
+)
+
+// smtpTimeout bounds a whole SMTP conversation when the context has no deadline.
+const smtpTimeout = 2 * time.Minute
+
+// SMTPConfig configures an SMTPSender.
+type SMTPConfig struct {
+	Host     string
+	Port     int    // 587 if zero
+	From     string // The sender's address, e.g. "Payroll <payroll@example.com>"
+	Username string // Authenticates with PLAIN if set
+	Password string
+	TLS      string // TLSStartTLS if empty
+}
+
+// SMTPSender sends each message in its own connection to an SMTP server.
+type SMTPSender struct {
+	config SMTPConfig
+	from   *netmail.Address
+}
+
+// NewSMTPSender checks the configuration and returns a sender using it.
+func NewSMTPSender(config SMTPConfig) (*SMTPSender, error) {
+	if config.Host == "" {
+		return nil, errors.New("SMTP host is required")
+	}
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	if config.TLS == "" {
+		config.TLS = TLSStartTLS
+	}
+	if config.TLS != TLSStartTLS && config.TLS != TLSImplicit && config.TLS != TLSNone {
+		return nil, fmt.Errorf("unknown SMTP TLS mode %q, expected \"starttls\", \"tls\" or \"none\"", config.TLS)
+	}
+	from, err := netmail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP sender address %q: %w", config.From, err)
+	}
+	return &SMTPSender{config: config, from: from}, nil
+}
+
+// Send delivers the message. The server refusing the recipient or the
+// message with a permanent (5xx) reply is reported as ErrRejected.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("%w: invalid recipient address %q", ErrRejected, msg.To)
+	}
+	data, err := msg.compose(s.from, to, time.Now())
+	if err != nil {
+		return fmt.Errorf("composing message: %w", err)
+	}
+
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(s.config.Port))
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	if s.config.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.config.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if s.config.TLS == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if s.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return rejected(err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return rejected(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return rejected(err)
+	}
+	return client.Quit()
+}
+
+// rejected wraps permanent negative replies in ErrRejected.
+func rejected(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"payslip-generator/internal/mail/mailtest"
+	"testing"
+)
+
+func TestSMTPSenderDeliversToTheServer(t *testing.T) {
+	server := mailtest.NewServer()
+	defer server.Close()
+	server.Reject("gone@example.com")
+
+	sender, err := NewSMTPSender(SMTPConfig{Host: server.Host, Port: server.Port, From: "Payroll <payroll@example.com>", TLS: TLSNone})
+	if err != nil {
+		t.Fatalf("Expected a sender, but got %v", err)
+	}
+	attachment := bytes.Repeat([]byte("%PDF-1.4 payslip "), 20)
+	err = sender.Send(context.Background(), Message{
+		To:          "jdoe@example.com",
+		Subject:     "Slip gaji – Juni",
+		Text:        "Terlampir slip gaji Anda.",
+		Attachments: []Attachment{{FileName: "payslip-jdoe.pdf", ContentType: "application/pdf", Data: attachment}},
+	})
+	if err != nil {
+		t.Fatalf("Expected the message to be sent, but got %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 || messages[0].From != "payroll@example.com" || len(messages[0].To) != 1 || messages[0].To[0] != "jdoe@example.com" {
+		t.Fatalf("Expected one message from payroll to jdoe, but got %+v", messages)
+	}
+	msg, err := netmail.ReadMessage(bytes.NewReader(messages[0].Data))
+	if err != nil {
+		t.Fatalf("Expected a parsable message, but got %v", err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != "Slip gaji – Juni" {
+		t.Errorf("Expected the subject to be encoded, but got %q", subject)
+	}
+	_, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	text, _ := parts.NextPart() // Decodes quoted-printable
+	if body, _ := io.ReadAll(text); string(body) != "Terlampir slip gaji Anda." {
+		t.Errorf("Expected the text, but got %q", body)
+	}
+	file, err := parts.NextPart()
+	if err != nil || file.FileName() != "payslip-jdoe.pdf" {
+		t.Fatalf("Expected the attachment, but got %v", err)
+	}
+	if data, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, file)); !bytes.Equal(data, attachment) {
+		t.Errorf("Expected the attachment to survive the trip")
+	}
+
+	err = sender.Send(context.Background(), Message{To: "gone@example.com", Subject: "Payslip"})
+	if !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected a refused recipient to be ErrRejected, but got %v", err)
+	}
+
+	server.Close()
+	err = sender.Send(context.Background(), Message{To: "jdoe@example.com", Subject: "Payslip"})
+	if err == nil || errors.Is(err, ErrRejected) {
+		t.Errorf("Expected an unreachable server to be a temporary error, but got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"payslip-generator/internal/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthRequired validates the bearer token and stores the authenticated
+// principal in the context as "user_id" and "user_type". Requests from
+// user types not listed in allowedTypes are rejected.
+func AuthRequired(allowedTypes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or malformed Authorization header."})
+			return
+		}
+
+		claims, err := services.ParseToken(tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token."})
+			return
+		}
+
+		allowed := len(allowedTypes) == 0
+		for _, t := range allowedTypes {
+			if claims.UserType == t {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You are not allowed to access this resource."})
+			return
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("user_type", claims.UserType)
+		c.Next()
+	}
+}
+
+// RequirePermission rejects admins whose roles don't grant the permission.
+// It must run after AuthRequired so that "user_id" is set.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, err := services.AdminHasPermission(c.GetUint("user_id"), permission)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions."})
+			return
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing permission: " + permission})
+			return
+		}
+		c.Next()
+	}
+}
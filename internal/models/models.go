@@ -1,6 +1,7 @@
 package models
 
 import (
+	"payslip-generator/internal/money"
 	"time"
 
 	"gorm.io/gorm"
@@ -20,43 +21,149 @@ type BaseModel struct {
 // Employee represents the employee data model.
 type Employee struct {
 	BaseModel
-	Username string  `gorm:"unique;not null" json:"username"`
-	Password string  `json:"-"`
-	Salary   float64 `gorm:"not null" json:"salary"`
+	Username          string       `gorm:"unique;not null" json:"username"`
+	Password          string       `json:"-"`
+	Email             string       `gorm:"index" json:"email"` // Where payslips are sent
+	Salary            money.Amount `gorm:"not null" json:"salary"`
+	Currency          string       `gorm:"size:3;not null;default:IDR" json:"currency"`     // ISO 4217 code the salary is paid in
+	TaxMaritalStatus  string       `gorm:"not null;default:single" json:"taxMaritalStatus"` // "single" or "married"
+	TaxDependents     int          `gorm:"not null;default:0" json:"taxDependents"`
+	Location          string       `gorm:"index" json:"location"`       // Selects the holiday calendar for the location
+	HolidayCalendarID *uint        `json:"holidayCalendarId,omitempty"` // Overrides the location's calendar
+	WorkScheduleID    *uint        `json:"workScheduleId,omitempty"`    // Standard Monday to Friday, 8 hour days when unset
+	Group             string       `gorm:"index" json:"group"`          // e.g. a legal entity; selects the payslip template
+	EmployeeNumber    string       `gorm:"index" json:"employeeNumber"`
+	DateOfBirth       *time.Time   `gorm:"type:date" json:"dateOfBirth,omitempty"`
+	DocumentPassword  string       `json:"-"` // Set by the employee to open payslip documents, sealed with the server's key
 }
 
+// DefaultCurrency is the currency of salaries and claims that don't specify one.
+const DefaultCurrency = "IDR"
+
+// Tax marital statuses used to determine an employee's tax-free allowance.
+const (
+	TaxStatusSingle  = "single"
+	TaxStatusMarried = "married"
+)
+
 // Admin represents the admin user data model.
 type Admin struct {
 	BaseModel
 	Username string `gorm:"unique;not null" json:"username"`
 	Password string `json:"-"`
+	Roles    []Role `gorm:"many2many:admin_roles;" json:"roles,omitempty"`
 }
 
 // Attendance represents an employee's daily attendance record.
 type Attendance struct {
 	BaseModel
-	EmployeeID uint      `gorm:"not null;index" json:"employeeId"`
-	CheckIn    time.Time `gorm:"not null" json:"checkIn"`
+	EmployeeID        uint       `gorm:"not null;index" json:"employeeId"`
+	CheckIn           time.Time  `gorm:"not null" json:"checkIn"`
+	CheckOut          *time.Time `json:"checkOut,omitempty"`
+	WorkedMinutes     int        `json:"workedMinutes"`     // From check-in to check-out; 0 until checked out
+	LateMinutes       int        `json:"lateMinutes"`       // Checked in after the schedule's start time
+	EarlyLeaveMinutes int        `json:"earlyLeaveMinutes"` // Checked out before the schedule's end time
+	OnHoliday         bool       `json:"onHoliday"`         // Submitted on a public holiday of the employee's calendar
 }
 
+// Claim types and statuses. A claim is pending until it has collected its
+// required approvals, and is rejected by any single rejection.
+const (
+	ClaimTypeOvertime      = "overtime"
+	ClaimTypeReimbursement = "reimbursement"
+
+	ClaimPending  = "pending"
+	ClaimApproved = "approved"
+	ClaimRejected = "rejected"
+)
+
+// What happens to a reimbursement over its category's limits.
+const (
+	OverLimitReject = "reject"
+	OverLimitFlag   = "flag" // Accept it and flag it for the approvers
+)
+
 // Overtime represents an employee's overtime request.
 type Overtime struct {
 	BaseModel
-	EmployeeID   uint      `gorm:"not null;index" json:"employeeId"`
-	Date         time.Time `gorm:"type:date;not null" json:"date"`
-	Hours        float64   `gorm:"not null" json:"hours"`
-	IsApproved   bool      `gorm:"default:true" json:"isApproved"`
-	PayrollRunID *uint     `gorm:"index" json:"payrollRunId,omitempty"`
+	EmployeeID        uint            `gorm:"not null;index" json:"employeeId"`
+	Date              time.Time       `gorm:"type:date;not null" json:"date"`
+	Hours             float64         `gorm:"not null" json:"hours"`
+	Status            string          `gorm:"not null;index;default:pending" json:"status"`
+	RequiredApprovals int             `gorm:"not null;default:1" json:"requiredApprovals"` // Set from the approval rules on submission
+	DecisionReason    string          `json:"decisionReason,omitempty"`
+	Approvals         []ClaimApproval `gorm:"polymorphic:Claim;polymorphicValue:overtime" json:"approvals,omitempty"`
+	PayrollRunID      *uint           `gorm:"index" json:"payrollRunId,omitempty"`
 }
 
 // Reimbursement represents an employee's reimbursement request.
 type Reimbursement struct {
 	BaseModel
-	EmployeeID   uint    `gorm:"not null;index" json:"employeeId"`
-	Description  string  `gorm:"not null" json:"description"`
-	Amount       float64 `gorm:"not null" json:"amount"`
-	IsApproved   bool    `gorm:"default:true" json:"isApproved"`
-	PayrollRunID *uint   `gorm:"index" json:"payrollRunId,omitempty"`
+	EmployeeID        uint                   `gorm:"not null;index" json:"employeeId"`
+	Description       string                 `gorm:"not null" json:"description"`
+	Amount            money.Amount           `gorm:"not null" json:"amount"`
+	Currency          string                 `gorm:"size:3;not null;default:IDR" json:"currency"` // Converted to the employee's pay currency by the payroll run
+	CategoryID        *uint                  `gorm:"index" json:"categoryId,omitempty"`
+	Category          *ReimbursementCategory `json:"category,omitempty"`
+	ExpenseDate       *time.Time             `gorm:"type:date" json:"expenseDate,omitempty"`
+	Merchant          string                 `json:"merchant,omitempty"`
+	PolicyFlags       string                 `json:"policyFlags,omitempty"` // Category limits the claim exceeds, for the approvers to review
+	Status            string                 `gorm:"not null;index;default:pending" json:"status"`
+	RequiredApprovals int                    `gorm:"not null;default:1" json:"requiredApprovals"` // Set from the approval rules on submission
+	DecisionReason    string                 `json:"decisionReason,omitempty"`
+	Approvals         []ClaimApproval        `gorm:"polymorphic:Claim;polymorphicValue:reimbursement" json:"approvals,omitempty"`
+	Receipts          []ReimbursementReceipt `json:"receipts,omitempty"`
+	PayrollRunID      *uint                  `gorm:"index" json:"payrollRunId,omitempty"`
+}
+
+// ReimbursementCategory groups expense claims, such as travel or meals, and
+// sets the limits and required details that apply to them.
+type ReimbursementCategory struct {
+	BaseModel
+	Code            string       `gorm:"unique;not null" json:"code"` // e.g. "TRAVEL"
+	Name            string       `gorm:"not null" json:"name"`
+	Currency        string       `gorm:"size:3;not null;default:IDR" json:"currency"`    // Currency of the limits
+	ClaimLimit      money.Amount `json:"claimLimit"`                                     // Most a single claim may be for; 0 for no limit
+	PeriodLimit     money.Amount `json:"periodLimit"`                                    // Most an employee may claim per payroll period; 0 for no limit
+	RequiredFields  string       `json:"requiredFields"`                                 // e.g. "expenseDate,merchant,receipt"
+	OverLimitAction string       `gorm:"not null;default:reject" json:"overLimitAction"` // OverLimitReject or OverLimitFlag
+}
+
+// ReimbursementReceipt is an image or PDF attached to a reimbursement claim.
+// The file itself is kept in object storage under StorageKey.
+type ReimbursementReceipt struct {
+	BaseModel
+	ReimbursementID uint   `gorm:"not null;index" json:"reimbursementId"`
+	FileName        string `gorm:"not null" json:"fileName"`    // As uploaded, for the download
+	ContentType     string `gorm:"not null" json:"contentType"` // Detected from the content, not taken from the client
+	Size            int64  `gorm:"not null" json:"size"`
+	SHA256          string `gorm:"size:64;not null" json:"sha256"`
+	StorageKey      string `gorm:"not null;uniqueIndex" json:"-"`
+}
+
+// ClaimApproval records one admin's decision on an overtime or reimbursement claim.
+type ClaimApproval struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	ClaimType string    `gorm:"not null;uniqueIndex:idx_claim_approver" json:"claimType"` // ClaimTypeOvertime or ClaimTypeReimbursement
+	ClaimID   uint      `gorm:"not null;uniqueIndex:idx_claim_approver" json:"claimId"`
+	AdminID   uint      `gorm:"not null;uniqueIndex:idx_claim_approver" json:"adminId"`
+	Level     int       `gorm:"not null" json:"level"`    // 1 for the first approval, 2 for the second, ...
+	Decision  string    `gorm:"not null" json:"decision"` // ClaimApproved or ClaimRejected
+	Reason    string    `json:"reason,omitempty"`
+	RequestIP string    `json:"-"`
+}
+
+// ApprovalRule requires more than one approval for claims at or above a
+// threshold: overtime of at least MinHours, or reimbursements of at least
+// MinAmount in Currency. The highest Levels among matching rules applies.
+type ApprovalRule struct {
+	BaseModel
+	ClaimType string       `gorm:"not null;index" json:"claimType"`
+	MinHours  float64      `json:"minHours,omitempty"`
+	MinAmount money.Amount `json:"minAmount,omitempty"`
+	Currency  string       `gorm:"size:3" json:"currency,omitempty"`
+	Levels    int          `gorm:"not null" json:"levels"`
 }
 
 // PayrollPeriod defines the start and end dates for a payroll run.
@@ -67,20 +174,360 @@ type PayrollPeriod struct {
 	IsRun     bool      `gorm:"default:false" json:"isRun"`
 }
 
+// Payroll run statuses.
+const (
+	PayrollRunQueued    = "queued"
+	PayrollRunRunning   = "running"
+	PayrollRunSucceeded = "succeeded"
+	PayrollRunFailed    = "failed"
+	PayrollRunReversed  = "reversed"
+)
+
+// PayrollRun tracks a single execution of payroll for a period.
+type PayrollRun struct {
+	BaseModel
+	PayrollPeriodID uint              `gorm:"not null;index" json:"payrollPeriodId"`
+	Status          string            `gorm:"not null;index" json:"status"`
+	TotalEmployees  int               `json:"totalEmployees"`
+	ProcessedCount  int               `json:"processedCount"`
+	FailedCount     int               `json:"failedCount"`
+	SkippedCount    int               `json:"skippedCount"` // Employees left without a payslip because their deductions exceed their earnings
+	Error           string            `json:"error,omitempty"`
+	StartedAt       *time.Time        `json:"startedAt"`
+	FinishedAt      *time.Time        `json:"finishedAt"`
+	Legacy          bool              `gorm:"not null;default:false" json:"legacy,omitempty"` // Stands in for a payroll run made before runs were tracked
+	Errors          []PayrollRunError `json:"errors,omitempty"`
+}
+
+// PayrollRunError records why a payslip could not be generated for an employee.
+// A skipped employee was left out without failing the run.
+type PayrollRunError struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"createdAt"`
+	PayrollRunID uint      `gorm:"not null;index" json:"payrollRunId"`
+	EmployeeID   uint      `gorm:"not null" json:"employeeId"`
+	Message      string    `json:"message"`
+	Skipped      bool      `json:"skipped,omitempty"`
+}
+
 // Payslip stores the generated payslip details.
 type Payslip struct {
 	BaseModel
-	EmployeeID      uint    `gorm:"not null;index" json:"employeeId"`
-	PayrollPeriodID uint    `gorm:"not null;index" json:"payrollPeriodId"`
-	BaseSalary      float64 `json:"baseSalary"`
-	DaysAttended    int     `json:"daysAttended"`
-	WorkingDays     int     `json:"workingDays"`
-	ProratedSalary  float64 `json:"proratedSalary"`
-	OvertimeHours   float64 `json:"overtimeHours"`
-	OvertimePay     float64 `json:"overtimePay"`
-	Reimbursement   float64 `json:"reimbursement"`
-	TakeHomePay     float64 `json:"takeHomePay"`
-	PayslipDetails  string  `gorm:"type:jsonb" json:"payslipDetails"`
+	EmployeeID            uint              `gorm:"not null;index" json:"employeeId"`
+	PayrollPeriodID       uint              `gorm:"not null;index" json:"payrollPeriodId"`
+	PayrollRunID          uint              `gorm:"index" json:"payrollRunId"`
+	Currency              string            `gorm:"size:3;not null;default:IDR" json:"currency"` // The employee's pay currency; every amount below is in it
+	BaseSalary            money.Amount      `json:"baseSalary"`
+	DaysAttended          int               `json:"daysAttended"` // Includes approved paid leave
+	PaidLeaveDays         int               `json:"paidLeaveDays"`
+	UnpaidLeaveDays       int               `json:"unpaidLeaveDays"`
+	WorkingDays           int               `json:"workingDays"`
+	ProratedSalary        money.Amount      `json:"proratedSalary"`
+	OvertimeHours         float64           `json:"overtimeHours"`
+	OvertimePay           money.Amount      `json:"overtimePay"`
+	Reimbursement         money.Amount      `json:"reimbursement"`
+	TaxableIncome         money.Amount      `json:"taxableIncome"`
+	TaxWithheld           money.Amount      `json:"taxWithheld"`
+	TaxTableID            *uint             `json:"taxTableId,omitempty"`
+	GrossEarnings         money.Amount      `json:"grossEarnings"`
+	TotalDeductions       money.Amount      `json:"totalDeductions"`
+	TakeHomePay           money.Amount      `json:"takeHomePay"`
+	EmployerContributions money.Amount      `json:"employerContributions"` // Employer cost on top of gross pay, not paid to the employee
+	PayslipDetails        string            `gorm:"type:jsonb" json:"payslipDetails"`
+	VoidedAt              *time.Time        `gorm:"index" json:"voidedAt,omitempty"` // Set when the payroll run is reversed
+	VoidReason            string            `json:"voidReason,omitempty"`
+	LineItems             []PayslipLineItem `gorm:"constraint:OnDelete:CASCADE" json:"lineItems,omitempty"`
+}
+
+// Payslip line item types.
+const (
+	LineItemEarning              = "earning"
+	LineItemDeduction            = "deduction"
+	LineItemEmployerContribution = "employer_contribution"
+)
+
+// PayslipLineItem is one earning, deduction or employer contribution on a payslip.
+type PayslipLineItem struct {
+	ID          uint         `gorm:"primarykey" json:"id"`
+	PayslipID   uint         `gorm:"not null;index" json:"-"`
+	Code        string       `gorm:"not null" json:"code"` // e.g. "BASIC_SALARY", "INCOME_TAX"
+	Description string       `json:"description"`
+	Type        string       `gorm:"not null" json:"type"`
+	Quantity    float64      `json:"quantity"`
+	Rate        float64      `json:"rate"`
+	Amount      money.Amount `gorm:"not null" json:"amount"`
+	Taxable     bool         `json:"taxable"` // Earnings: subject to income tax. Deductions: reduce taxable income.
+}
+
+// PayslipTemplate is an HTML layout for the payslips of an employee group.
+// Templates are edited by adding versions; the latest version is used.
+type PayslipTemplate struct {
+	BaseModel
+	Name     string                   `gorm:"unique;not null" json:"name"`
+	Group    string                   `gorm:"uniqueIndex;not null" json:"group"` // Empty for the template of employees whose group has none
+	Versions []PayslipTemplateVersion `gorm:"constraint:OnDelete:CASCADE" json:"versions,omitempty"`
+}
+
+// PayslipTemplateVersion is one revision of a payslip template's
+// html/template source. Versions are never edited.
+type PayslipTemplateVersion struct {
+	ID                uint      `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time `json:"createdAt"`
+	PayslipTemplateID uint      `gorm:"not null;uniqueIndex:idx_payslip_template_version" json:"-"`
+	Version           int       `gorm:"not null;uniqueIndex:idx_payslip_template_version" json:"version"` // 1 for the first version, 2 for the second, ...
+	Body              string    `gorm:"type:text;not null" json:"body"`
+	CreatedByID       uint      `json:"createdById"`
+	RequestIP         string    `json:"-"`
+}
+
+// Payslip delivery statuses.
+const (
+	DeliveryQueued  = "queued"  // Waiting to be sent, or to be retried
+	DeliverySent    = "sent"    // Accepted by the mail server
+	DeliveryBounced = "bounced" // Refused by the mail server, e.g. for an unknown address
+	DeliveryFailed  = "failed"  // Given up on after the last retry, or impossible to send
+)
+
+// PayslipDelivery tracks the emailing of a payslip to its employee. Every
+// distribution and resend of a payslip has its own delivery.
+type PayslipDelivery struct {
+	ID              uint       `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+	PayslipID       uint       `gorm:"not null;index" json:"payslipId"`
+	EmployeeID      uint       `gorm:"not null;index" json:"employeeId"`
+	PayrollPeriodID uint       `gorm:"not null;index" json:"payrollPeriodId"`
+	Recipient       string     `json:"recipient"` // The employee's email address when the delivery was queued
+	Status          string     `gorm:"not null;index;default:queued" json:"status"`
+	Attempts        int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt   *time.Time `json:"nextAttemptAt,omitempty"` // While queued
+	SentAt          *time.Time `json:"sentAt,omitempty"`
+	Error           string     `json:"error,omitempty"` // Of the last attempt
+	CreatedByID     uint       `json:"createdById"`
+	RequestIP       string     `json:"-"`
+}
+
+// Payslip protection schemes: how the password that opens an employee's
+// payslip documents is chosen.
+const (
+	ProtectionNone     = "none"     // Documents are not encrypted
+	ProtectionRule     = "rule"     // Derived from the employee's details by the password rule
+	ProtectionEmployee = "employee" // Set by the employee, or derived by the rule until they do
+)
+
+// PayslipProtection configures the encryption of the payslip documents of an
+// employee group, e.g. a company.
+type PayslipProtection struct {
+	BaseModel
+	Group        string `gorm:"uniqueIndex;not null" json:"group"` // Empty for employees whose group has no protection of its own
+	Scheme       string `gorm:"not null;default:none" json:"scheme"`
+	PasswordRule string `json:"passwordRule"` // e.g. "{dob}{employeeNumber}", used by ProtectionRule and ProtectionEmployee
+}
+
+// Calculation types for recurring pay components.
+const (
+	CalculationFixed      = "fixed"
+	CalculationPercentage = "percentage"
+)
+
+// RecurringPayComponent is an allowance or deduction paid on every payslip of
+// an employee while it is active (e.g. a transport allowance or loan repayment).
+type RecurringPayComponent struct {
+	BaseModel
+	EmployeeID  uint         `gorm:"not null;index" json:"employeeId"`
+	Code        string       `gorm:"not null" json:"code"` // Line item code, e.g. "TRANSPORT"
+	Description string       `json:"description"`
+	Type        string       `gorm:"not null" json:"type"`        // LineItemEarning or LineItemDeduction
+	Calculation string       `gorm:"not null" json:"calculation"` // CalculationFixed or CalculationPercentage
+	Amount      money.Amount `json:"amount"`                      // Used with CalculationFixed
+	Percentage  float64      `json:"percentage"`                  // Of base salary, used with CalculationPercentage
+	Taxable     bool         `json:"taxable"`                     // Same meaning as PayslipLineItem.Taxable
+	StartDate   time.Time    `gorm:"type:date;not null" json:"startDate"`
+	EndDate     *time.Time   `gorm:"type:date" json:"endDate,omitempty"` // Nil while open-ended
+}
+
+// Tax table bases: whether bracket bounds and allowances are annual or per pay period.
+const (
+	TaxBasisAnnual  = "annual"
+	TaxBasisMonthly = "monthly"
+)
+
+// TaxTable is one version of the income tax rules, valid from EffectiveFrom
+// until the next version takes effect. Versions are never edited once created,
+// so a historical period always recomputes with the table it was run with.
+type TaxTable struct {
+	BaseModel
+	Name               string       `gorm:"not null" json:"name"`
+	EffectiveFrom      time.Time    `gorm:"type:date;not null;uniqueIndex" json:"effectiveFrom"`
+	Basis              string       `gorm:"not null;default:annual" json:"basis"`
+	PersonalAllowance  money.Amount `json:"personalAllowance"`  // Tax-free amount for every employee
+	SpouseAllowance    money.Amount `json:"spouseAllowance"`    // Added for married employees
+	DependentAllowance money.Amount `json:"dependentAllowance"` // Added per dependent, up to MaxDependents
+	MaxDependents      int          `json:"maxDependents"`
+	Currency           string       `gorm:"size:3;not null;default:IDR" json:"currency"` // Of the bounds and allowances; converted for employees paid in another currency
+	Brackets           []TaxBracket `gorm:"constraint:OnDelete:CASCADE" json:"brackets"`
+}
+
+// TaxBracket taxes the part of the income between LowerBound and UpperBound at Rate.
+// An UpperBound of zero means the bracket has no upper limit.
+type TaxBracket struct {
+	ID         uint         `gorm:"primarykey" json:"-"`
+	TaxTableID uint         `gorm:"not null;index" json:"-"`
+	LowerBound money.Amount `json:"lowerBound"`
+	UpperBound money.Amount `json:"upperBound"`
+	Rate       float64      `gorm:"not null" json:"rate"` // e.g. 0.05 for 5%
+}
+
+// Day types that overtime tiers apply to.
+const (
+	OvertimeWorkday = "workday"  // A working day in the employee's schedule
+	OvertimeRestDay = "rest_day" // A day off in the employee's schedule, e.g. a weekend
+	OvertimeHoliday = "holiday"  // A public holiday in the employee's calendar
+)
+
+// OvertimePolicy is one version of the overtime rules. The latest version
+// effective on a date applies; versions are never edited.
+type OvertimePolicy struct {
+	BaseModel
+	Name               string         `gorm:"not null" json:"name"`
+	EffectiveFrom      time.Time      `gorm:"type:date;not null;uniqueIndex" json:"effectiveFrom"`
+	DailyLimitHours    float64        `gorm:"not null" json:"dailyLimitHours"` // Most overtime claimed for one date, across all claims
+	MonthlyCapHours    float64        `json:"monthlyCapHours"`                 // Most overtime paid per payroll period; 0 for no cap
+	EarliestSubmission string         `json:"earliestSubmission"`              // "HH:MM" server time from which overtime can be claimed for the same day; empty for any time
+	Tiers              []OvertimeTier `gorm:"constraint:OnDelete:CASCADE" json:"tiers"`
+}
+
+// OvertimeTier pays the overtime hours of a day beyond the previous tier's
+// UpToHours, and up to its own, at Multiplier times the hourly rate. An
+// UpToHours of zero means the tier has no upper limit.
+type OvertimeTier struct {
+	ID               uint    `gorm:"primarykey" json:"-"`
+	OvertimePolicyID uint    `gorm:"not null;index" json:"-"`
+	DayType          string  `gorm:"not null" json:"dayType"`
+	UpToHours        float64 `json:"upToHours"`
+	Multiplier       float64 `gorm:"not null" json:"multiplier"` // e.g. 1.5
+}
+
+// Contribution bases: what a statutory contribution rate is applied to.
+const (
+	ContributionBaseSalary          = "base_salary"
+	ContributionBaseTaxableEarnings = "taxable_earnings"
+)
+
+// ContributionRule is one version of a statutory contribution (e.g. pension or
+// health insurance) shared between employee and employer. For each code, the
+// latest version effective on a period's end date applies; versions are never edited.
+type ContributionRule struct {
+	BaseModel
+	Code          string       `gorm:"not null;uniqueIndex:idx_contribution_version" json:"code"` // Line item code, e.g. "PENSION"
+	Description   string       `json:"description"`
+	EffectiveFrom time.Time    `gorm:"type:date;not null;uniqueIndex:idx_contribution_version" json:"effectiveFrom"`
+	Base          string       `gorm:"not null" json:"base"`
+	Currency      string       `gorm:"size:3;not null;default:IDR" json:"currency"` // Of the base cap; converted for employees paid in another currency
+	BaseCap       money.Amount `json:"baseCap"`                                     // Maximum contributory base per period; 0 means no cap
+	EmployeeRate  float64      `json:"employeeRate"`
+	EmployerRate  float64      `json:"employerRate"`
+	TaxDeductible bool         `json:"taxDeductible"` // Whether the employee share reduces taxable income
+}
+
+// ExchangeRate converts one unit of FromCurrency into Rate units of ToCurrency,
+// from EffectiveFrom until a later rate for the same pair takes effect.
+type ExchangeRate struct {
+	BaseModel
+	FromCurrency  string    `gorm:"size:3;not null;uniqueIndex:idx_exchange_rate_version" json:"fromCurrency"`
+	ToCurrency    string    `gorm:"size:3;not null;uniqueIndex:idx_exchange_rate_version" json:"toCurrency"`
+	Rate          float64   `gorm:"not null" json:"rate"`
+	EffectiveFrom time.Time `gorm:"type:date;not null;uniqueIndex:idx_exchange_rate_version" json:"effectiveFrom"`
+}
+
+// HolidayCalendar is a set of public holidays. It applies to employees who are
+// assigned to it directly, or whose Location matches its Location.
+type HolidayCalendar struct {
+	BaseModel
+	Name               string    `gorm:"unique;not null" json:"name"`
+	Location           string    `gorm:"index" json:"location"`                        // Empty: only applies to employees assigned directly
+	BlockAttendance    bool      `json:"blockAttendance"`                              // Reject attendance on holidays instead of flagging it
+	OvertimeMultiplier float64   `gorm:"not null;default:3" json:"overtimeMultiplier"` // Of the hourly rate, for overtime on holidays
+	Holidays           []Holiday `gorm:"constraint:OnDelete:CASCADE" json:"holidays,omitempty"`
+}
+
+// Holiday is one public holiday in a calendar.
+type Holiday struct {
+	ID                uint      `gorm:"primarykey" json:"id"`
+	HolidayCalendarID uint      `gorm:"not null;uniqueIndex:idx_calendar_date" json:"holidayCalendarId"`
+	Date              time.Time `gorm:"type:date;not null;uniqueIndex:idx_calendar_date" json:"date"`
+	Name              string    `json:"name"`
+}
+
+// WorkSchedule describes the days an employee is expected to work and for how
+// long. A weekly schedule lists its working weekdays; a rotating schedule
+// repeats RotationPattern day by day from RotationStart and ignores Weekdays.
+type WorkSchedule struct {
+	BaseModel
+	Name                   string     `gorm:"unique;not null" json:"name"`
+	Weekdays               string     `json:"weekdays"`                                 // e.g. "mon,tue,wed,thu"
+	RotationPattern        string     `json:"rotationPattern,omitempty"`                // "W" for a working day, "O" for a day off, e.g. "WWWWOOOO"
+	RotationStart          *time.Time `gorm:"type:date" json:"rotationStart,omitempty"` // The first day of the pattern
+	HoursPerDay            float64    `gorm:"not null;default:8" json:"hoursPerDay"`
+	StartTime              string     `json:"startTime,omitempty"`    // "15:04"; with EndTime, late arrivals and early leaves are measured against it
+	EndTime                string     `json:"endTime,omitempty"`      // "15:04"; at or before StartTime for a shift that ends the next day
+	OvertimeFromAttendance bool       `json:"overtimeFromAttendance"` // Pay time worked beyond HoursPerDay as overtime
+}
+
+// LeaveType is a kind of leave such as annual, sick or unpaid leave. Types
+// that track a balance accrue AccrualDaysPerMonth days each month, up to MaxBalance.
+type LeaveType struct {
+	BaseModel
+	Code                string  `gorm:"unique;not null" json:"code"` // e.g. "ANNUAL"
+	Name                string  `gorm:"not null" json:"name"`
+	Paid                bool    `json:"paid"`          // Paid leave counts as attended; unpaid leave does not
+	TracksBalance       bool    `json:"tracksBalance"` // Requests may not exceed the balance
+	AccrualDaysPerMonth float64 `json:"accrualDaysPerMonth"`
+	MaxBalance          float64 `json:"maxBalance"` // 0 for no cap
+}
+
+// Leave request statuses.
+const (
+	LeaveRequestPending   = "pending"
+	LeaveRequestApproved  = "approved"
+	LeaveRequestRejected  = "rejected"
+	LeaveRequestCancelled = "cancelled"
+)
+
+// LeaveRequest is an employee's request for leave over a range of dates.
+type LeaveRequest struct {
+	BaseModel
+	EmployeeID     uint       `gorm:"not null;index" json:"employeeId"`
+	LeaveTypeID    uint       `gorm:"not null;index" json:"leaveTypeId"`
+	LeaveType      *LeaveType `json:"leaveType,omitempty"`
+	StartDate      time.Time  `gorm:"type:date;not null" json:"startDate"`
+	EndDate        time.Time  `gorm:"type:date;not null" json:"endDate"`
+	Days           float64    `gorm:"not null" json:"days"` // Working days in the range per the employee's schedule and holidays
+	Reason         string     `json:"reason"`
+	Status         string     `gorm:"not null;index;default:pending" json:"status"`
+	DecidedByID    *uint      `json:"decidedById,omitempty"`
+	DecidedAt      *time.Time `json:"decidedAt,omitempty"`
+	DecisionReason string     `json:"decisionReason,omitempty"`
+}
+
+// Leave ledger entry kinds.
+const (
+	LeaveEntryAccrual    = "accrual"
+	LeaveEntryTaken      = "taken"
+	LeaveEntryAdjustment = "adjustment"
+)
+
+// LeaveLedgerEntry credits or debits an employee's balance of a leave type.
+// The balance is the sum of the entries; they are never edited.
+type LeaveLedgerEntry struct {
+	BaseModel
+	EmployeeID     uint    `gorm:"not null;index;uniqueIndex:idx_leave_accrual" json:"employeeId"`
+	LeaveTypeID    uint    `gorm:"not null;index;uniqueIndex:idx_leave_accrual" json:"leaveTypeId"`
+	Kind           string  `gorm:"not null" json:"kind"`
+	Days           float64 `gorm:"not null" json:"days"`                                               // Negative for leave taken
+	AccrualMonth   *string `gorm:"size:7;uniqueIndex:idx_leave_accrual" json:"accrualMonth,omitempty"` // "2006-01", set on accruals only
+	LeaveRequestID *uint   `json:"leaveRequestId,omitempty"`
+	Note           string  `json:"note,omitempty"`
 }
 
 // AuditLog tracks significant events in the system.
@@ -93,3 +540,31 @@ type AuditLog struct {
 	Details   string    `json:"details"`                // e.g., "Ran payroll for period ID: 1"
 	RequestIP string    `json:"requestIp"`
 }
+
+// Role groups a set of permissions that can be granted to admins.
+type Role struct {
+	BaseModel
+	Name        string           `gorm:"unique;not null" json:"name"`
+	Description string           `json:"description"`
+	Permissions []RolePermission `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// RolePermission grants a single permission code (e.g. "payroll:run") to a role.
+type RolePermission struct {
+	ID         uint   `gorm:"primarykey" json:"-"`
+	RoleID     uint   `gorm:"not null;uniqueIndex:idx_role_permission" json:"-"`
+	Permission string `gorm:"not null;uniqueIndex:idx_role_permission" json:"permission"`
+}
+
+// SeededPermission records a permission code the administrator role has been
+// granted at startup, so that a code an admin later removes from it stays removed.
+type SeededPermission struct {
+	Code      string `gorm:"primarykey"`
+	CreatedAt time.Time
+}
+
+// SetupStep records a one-time data setup that has run, so that it is not repeated at the next startup.
+type SetupStep struct {
+	Name      string `gorm:"primarykey"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,239 @@
+// Package money provides a fixed-point decimal amount type for monetary values,
+// so that salaries, claims and payslip totals are never held in binary floating point.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of minor units in one major unit (two decimal places).
+const Scale = 100
+
+// ErrInvalidAmount is returned when a value cannot be read as an amount.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// Amount is a monetary value held as an integer number of minor units (cents).
+// It serializes to JSON as a decimal number and is stored as NUMERIC(20,2).
+type Amount int64
+
+// Zero is the zero amount.
+const Zero Amount = 0
+
+// FromMinor returns the amount for a number of minor units.
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// FromUnits returns the amount for a whole number of major units.
+func FromUnits(units int64) Amount {
+	return Amount(units * Scale)
+}
+
+// FromFloat converts a float to the nearest minor unit. It is only meant for
+// values that were already floats, such as legacy database columns.
+func FromFloat(f float64) Amount {
+	return Amount(math.Round(f * Scale))
+}
+
+// Parse reads a decimal string such as "1250000", "-3.5" or "19.99".
+// More than two decimal places are rejected rather than silently rounded.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	digits := s
+	negative := false
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("%w: %q has more than two decimal places", ErrInvalidAmount, s)
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+		}
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Amount(minor), nil
+}
+
+// MustParse is like Parse but panics on error. It is intended for constants and tests.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Minor returns the amount as a number of minor units.
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// Float returns the amount as a float, for display and ratios only.
+func (a Amount) Float() float64 {
+	return float64(a) / Scale
+}
+
+// Rat returns the amount in minor units as an exact rational, for calculations
+// that must only be rounded once at the end.
+func (a Amount) Rat() *big.Rat {
+	return new(big.Rat).SetInt64(int64(a))
+}
+
+// String formats the amount with exactly two decimal places, e.g. "1250000.00".
+func (a Amount) String() string {
+	minor := int64(a)
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/Scale, minor%Scale)
+}
+
+// Grouped formats the amount like String with the whole units grouped in
+// thousands, e.g. "1,250,000.00", for printed documents.
+func (a Amount) Grouped() string {
+	s := a.String()
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	var grouped strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(r)
+	}
+	return sign + grouped.String() + "." + frac
+}
+
+// Mul multiplies the amount by a factor such as a tax rate or a number of hours,
+// rounding the result once with the given rule. The factor is taken as the
+// shortest decimal that represents it, so 0.05 is exactly five hundredths.
+func (a Amount) Mul(factor float64, r Rounding) Amount {
+	return r.Round(new(big.Rat).Mul(a.Rat(), Decimal(factor)))
+}
+
+// MulRat multiplies the amount by an exact factor, such as an exchange rate, rounding the result once.
+func (a Amount) MulRat(factor *big.Rat, r Rounding) Amount {
+	return r.Round(new(big.Rat).Mul(a.Rat(), factor))
+}
+
+// MulFrac multiplies the amount by num/den, rounding the result once.
+func (a Amount) MulFrac(num, den int64, r Rounding) Amount {
+	if den == 0 {
+		return 0
+	}
+	return r.Round(new(big.Rat).Mul(a.Rat(), big.NewRat(num, den)))
+}
+
+// Min returns the smaller of two amounts.
+func Min(a, b Amount) Amount {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Max returns the larger of two amounts.
+func Max(a, b Amount) Amount {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Decimal converts a float to the exact rational of its shortest decimal form.
+func Decimal(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	return r
+}
+
+// MarshalJSON writes the amount as a JSON number with two decimal places.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a string holding a decimal number.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	parsed, err := Parse(strings.Trim(s, `"`))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value stores the amount as an exact decimal string.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan reads a NUMERIC, integer or legacy floating point column.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+	case int64:
+		*a = FromUnits(v)
+	case float64:
+		*a = FromFloat(v)
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidAmount, src)
+	}
+	return nil
+}
+
+func (a *Amount) scanString(s string) error {
+	parsed, err := Parse(s)
+	if err != nil {
+		// Databases may return more precision than two places; round it.
+		f, ferr := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if ferr != nil {
+			return err
+		}
+		parsed = FromFloat(f)
+	}
+	*a = parsed
+	return nil
+}
+
+// GormDataType makes amount columns exact decimals.
+func (Amount) GormDataType() string {
+	return "numeric(20,2)"
+}
@@ -0,0 +1,109 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected Amount
+	}{
+		{"1250000", 125000000},
+		{"19.99", 1999},
+		{"-3.5", -350},
+		{".5", 50},
+		{"+7", 700},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil || got != tt.expected {
+			t.Errorf("Parse(%q) = %d, %v; expected %d", tt.in, got, err, tt.expected)
+		}
+	}
+
+	for _, in := range []string{"", "1.234", "12a", "1e6", "-", "-+5", "+-5", "--5"} {
+		if _, err := Parse(in); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("Expected Parse(%q) to fail, but got %v", in, err)
+		}
+	}
+}
+
+func TestGrouped(t *testing.T) {
+	tests := map[Amount]string{
+		MustParse("1250000"):   "1,250,000.00",
+		MustParse("999.5"):     "999.50",
+		MustParse("-123456.7"): "-123,456.70",
+		Zero:                   "0.00",
+	}
+	for in, expected := range tests {
+		if got := in.Grouped(); got != expected {
+			t.Errorf("Grouped(%s) = %q; expected %q", in, got, expected)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	var payload struct {
+		Amount Amount `json:"amount"`
+	}
+	if err := json.Unmarshal([]byte(`{"amount": 5000000.10}`), &payload); err != nil {
+		t.Fatalf("Expected a JSON number to parse, but got %v", err)
+	}
+	if payload.Amount != 500000010 {
+		t.Errorf("Expected 500000010 minor units, but got %d", payload.Amount)
+	}
+
+	out, _ := json.Marshal(payload)
+	if string(out) != `{"amount":5000000.10}` {
+		t.Errorf("Expected two decimal places in JSON, but got %s", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": "12.345"}`), &payload); err == nil {
+		t.Error("Expected more than two decimal places to be rejected")
+	}
+}
+
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		rule     string
+		minor    *big.Rat // exact value in minor units
+		expected Amount
+	}{
+		{"half_up", big.NewRat(25, 10), 3},
+		{"half_up", big.NewRat(-25, 10), -3},
+		{"half_even", big.NewRat(25, 10), 2},
+		{"half_even", big.NewRat(35, 10), 4},
+		{"down", big.NewRat(29, 10), 2},
+		{"up", big.NewRat(21, 10), 3},
+		{"down:1000", big.NewRat(123456, 1), 100000}, // down to 1000.00
+		{"half_up:1", big.NewRat(123450, 1), 123500}, // half up to whole units
+	}
+	for _, tt := range tests {
+		r, err := ParseRounding(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRounding(%q) failed: %v", tt.rule, err)
+		}
+		if got := r.Round(tt.minor); got != tt.expected {
+			t.Errorf("%s rounding of %s: expected %d, got %d", tt.rule, tt.minor.FloatString(2), tt.expected, got)
+		}
+	}
+
+	if _, err := ParseRounding("nearest"); err == nil {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+}
+
+func TestMulIsExact(t *testing.T) {
+	// 0.1 * 3 is not 0.3 in floating point; the amount must still come out exact.
+	if got := MustParse("0.10").Mul(3, Cent); got != MustParse("0.30") {
+		t.Errorf("Expected 0.30, but got %s", got)
+	}
+	// 10,000,000 / 21 days * 20 days rounds once: 9523809.5238... -> 9523809.52
+	if got := FromUnits(10000000).MulFrac(20, 21, Cent); got != MustParse("9523809.52") {
+		t.Errorf("Expected 9523809.52, but got %s", got)
+	}
+}
@@ -0,0 +1,85 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Rounding modes.
+const (
+	RoundHalfUp   = "half_up"   // Halves away from zero
+	RoundHalfEven = "half_even" // Halves to the even neighbour (banker's rounding)
+	RoundDown     = "down"      // Towards zero (truncate)
+	RoundUp       = "up"        // Away from zero
+)
+
+// Rounding is a rule for turning an exact result into an amount: a mode and
+// the increment to round to, e.g. half_up to the cent or down to whole units.
+type Rounding struct {
+	Mode      string
+	Increment Amount
+}
+
+// Cent rounds half up to the nearest minor unit.
+var Cent = Rounding{Mode: RoundHalfUp, Increment: 1}
+
+// ParseRounding reads a rule written as "mode" or "mode:increment",
+// e.g. "half_even" or "down:1000". The increment defaults to 0.01.
+func ParseRounding(s string) (Rounding, error) {
+	mode, increment, hasIncrement := strings.Cut(strings.TrimSpace(s), ":")
+	r := Rounding{Mode: mode, Increment: 1}
+	if hasIncrement {
+		inc, err := Parse(increment)
+		if err != nil {
+			return r, err
+		}
+		r.Increment = inc
+	}
+	return r, r.Validate()
+}
+
+// Validate checks the mode and the increment.
+func (r Rounding) Validate() error {
+	switch r.Mode {
+	case RoundHalfUp, RoundHalfEven, RoundDown, RoundUp:
+	default:
+		return fmt.Errorf("unknown rounding mode %q", r.Mode)
+	}
+	if r.Increment <= 0 {
+		return fmt.Errorf("rounding increment must be positive, got %s", r.Increment)
+	}
+	return nil
+}
+
+// String formats the rule as ParseRounding reads it.
+func (r Rounding) String() string {
+	return r.Mode + ":" + r.Increment.String()
+}
+
+// Round rounds an exact number of minor units to a multiple of the increment.
+func (r Rounding) Round(minor *big.Rat) Amount {
+	inc := int64(r.Increment)
+	if inc <= 0 {
+		inc = 1
+	}
+	steps := new(big.Rat).Quo(minor, new(big.Rat).SetInt64(inc))
+
+	// Split into the whole number of increments (truncated towards zero) and the rest.
+	quo, rem := new(big.Int).QuoRem(steps.Num(), steps.Denom(), new(big.Int))
+	if rem.Sign() != 0 {
+		away := false
+		switch r.Mode {
+		case RoundUp:
+			away = true
+		case RoundHalfUp, RoundHalfEven:
+			// Compare twice the remainder against the denominator to find the half.
+			cmp := new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(steps.Denom())
+			away = cmp > 0 || (cmp == 0 && (r.Mode == RoundHalfUp || quo.Bit(0) == 1))
+		}
+		if away {
+			quo.Add(quo, big.NewInt(int64(steps.Sign())))
+		}
+	}
+	return Amount(quo.Int64() * inc)
+}
@@ -0,0 +1,146 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// permissions granted to readers who open the document with the user
+// password: printing, copying text and accessibility, but not changing the
+// document. Every bit is set except the two reserved low bits and those of
+// modifying, annotating, filling in forms and assembling.
+const permissions = -1 &^ (1 | 2 | 8 | 32 | 256 | 1024)
+
+// encryption holds the keys of a document encrypted with the standard
+// security handler, revision 6 (AES-256, PDF 2.0 and Adobe extension level 8).
+type encryption struct {
+	user, owner  []byte
+	random       io.Reader
+	key          []byte // The file encryption key every string and stream is encrypted with
+	o, u, oe, ue []byte
+	perms        []byte
+	id           []byte
+}
+
+// Encrypt protects the document with AES-256. Readers must enter the user
+// password to open it. The owner password lifts the restriction on changing
+// the document; if empty, a random one is used so that nobody can.
+// Passwords longer than 127 bytes are truncated.
+func (d *Document) Encrypt(userPassword, ownerPassword string) {
+	d.encryption = &encryption{user: truncatePassword(userPassword), owner: truncatePassword(ownerPassword), random: rand.Reader}
+}
+
+func truncatePassword(password string) []byte {
+	b := []byte(password)
+	if len(b) > 127 {
+		b = b[:127]
+	}
+	return b
+}
+
+// setup generates the file key and the password entries of the encryption dictionary.
+func (e *encryption) setup() error {
+	e.key = make([]byte, 32)
+	salts := make([]byte, 32) // User validation, user key, owner validation and owner key salts
+	e.id = make([]byte, 16)
+	tail := make([]byte, 4)
+	for _, b := range [][]byte{e.key, salts, e.id, tail} {
+		if _, err := io.ReadFull(e.random, b); err != nil {
+			return fmt.Errorf("generating encryption keys: %w", err)
+		}
+	}
+	if len(e.owner) == 0 {
+		e.owner = make([]byte, 32)
+		if _, err := io.ReadFull(e.random, e.owner); err != nil {
+			return fmt.Errorf("generating encryption keys: %w", err)
+		}
+		e.owner = []byte(hex.EncodeToString(e.owner))
+	}
+
+	e.u = append(hash2B(e.user, salts[0:8], nil), salts[0:16]...)
+	e.ue = encryptKey(hash2B(e.user, salts[8:16], nil), e.key)
+	e.o = append(hash2B(e.owner, salts[16:24], e.u), salts[16:32]...)
+	e.oe = encryptKey(hash2B(e.owner, salts[24:32], e.u), e.key)
+
+	perms := make([]byte, 16)
+	p := int32(permissions)
+	binary.LittleEndian.PutUint32(perms, uint32(p))
+	copy(perms[4:], []byte{0xff, 0xff, 0xff, 0xff, 'T', 'a', 'd', 'b'})
+	copy(perms[12:], tail)
+	block, _ := aes.NewCipher(e.key)
+	e.perms = make([]byte, 16)
+	block.Encrypt(e.perms, perms)
+	return nil
+}
+
+// dictionary returns the document's encryption dictionary.
+func (e *encryption) dictionary() string {
+	return fmt.Sprintf("<< /Filter /Standard /V 5 /R 6 /Length 256 "+
+		"/CF << /StdCF << /Type /CryptFilter /CFM /AESV3 /AuthEvent /DocOpen /Length 32 >> >> /StmF /StdCF /StrF /StdCF "+
+		"/P %d /O <%x> /U <%x> /OE <%x> /UE <%x> /Perms <%x> /EncryptMetadata true >>",
+		permissions, e.o, e.u, e.oe, e.ue, e.perms)
+}
+
+// encrypt encrypts a string or stream with AES-256 in CBC mode, prefixed with
+// a random initialization vector.
+func (e *encryption) encrypt(data []byte) ([]byte, error) {
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	plain := append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	out := make([]byte, aes.BlockSize+len(plain))
+	if _, err := io.ReadFull(e.random, out[:aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	block, _ := aes.NewCipher(e.key)
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], plain)
+	return out, nil
+}
+
+// encryptKey encrypts the file key for the /UE and /OE entries: AES-256 in
+// CBC mode with a zero initialization vector and no padding.
+func encryptKey(intermediate, key []byte) []byte {
+	block, _ := aes.NewCipher(intermediate)
+	out := make([]byte, len(key))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, key)
+	return out
+}
+
+// hash2B is the password hash of revision 6 (ISO 32000-2, algorithm 2.B).
+// udata is the /U entry when hashing the owner password and empty otherwise.
+func hash2B(password, salt, udata []byte) []byte {
+	first := sha256.Sum256(bytes.Join([][]byte{password, salt, udata}, nil))
+	k := first[:]
+	var e []byte
+	for round := 0; round < 64 || int(e[len(e)-1]) > round-32; round++ {
+		k1 := bytes.Repeat(bytes.Join([][]byte{password, k, udata}, nil), 64)
+		block, _ := aes.NewCipher(k[:16])
+		e = make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+
+		// The first 16 bytes as a number modulo 3 pick the next hash; as
+		// 256 % 3 == 1, that is the sum of the bytes modulo 3.
+		sum := 0
+		for _, b := range e[:16] {
+			sum += int(b)
+		}
+		switch sum % 3 {
+		case 0:
+			h := sha256.Sum256(e)
+			k = h[:]
+		case 1:
+			h := sha512.Sum384(e)
+			k = h[:]
+		default:
+			h := sha512.Sum512(e)
+			k = h[:]
+		}
+	}
+	return k[:32]
+}
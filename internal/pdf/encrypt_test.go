@@ -0,0 +1,109 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	doc := New(A4Width, A4Height)
+	doc.SetInfo("Title", "Payslip")
+	doc.AddPage().Text(50, 50, Helvetica, 12, "Take-home pay: 9,906,250.00")
+	doc.Encrypt("01021990E042", "")
+
+	var out bytes.Buffer
+	if _, err := doc.WriteTo(&out); err != nil {
+		t.Fatalf("Expected the document to be written, but got %v", err)
+	}
+	data := out.Bytes()
+	if bytes.Contains(data, []byte("Take-home pay")) || bytes.Contains(data, []byte("(Payslip)")) {
+		t.Fatalf("Expected the content and information to be encrypted")
+	}
+	if !regexp.MustCompile(`trailer\n<< .*/Encrypt 8 0 R /ID \[<[0-9a-f]{32}> <[0-9a-f]{32}>\] >>`).Match(data) {
+		t.Errorf("Expected the trailer to reference the encryption dictionary")
+	}
+
+	entry := func(name string) []byte {
+		t.Helper()
+		m := regexp.MustCompile(`/` + name + ` <([0-9a-f]+)>`).FindSubmatch(data)
+		if m == nil {
+			t.Fatalf("Expected a /%s entry", name)
+		}
+		b, _ := hex.DecodeString(string(m[1]))
+		return b
+	}
+	u, ue := entry("U"), entry("UE")
+
+	// A reader checks the password against /U and decrypts the file key from /UE.
+	if !bytes.Equal(hash2B([]byte("01021990E042"), u[32:40], nil), u[:32]) {
+		t.Fatalf("Expected the user password to be accepted")
+	}
+	if bytes.Equal(hash2B([]byte("01021990E043"), u[32:40], nil), u[:32]) {
+		t.Errorf("Expected a wrong password to be refused")
+	}
+	block, _ := aes.NewCipher(hash2B([]byte("01021990E042"), u[40:48], nil))
+	key := make([]byte, 32)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(key, ue)
+
+	// The key decrypts the page's content stream.
+	m := regexp.MustCompile(`(?s)<< /Length (\d+) >>\nstream\n`).FindSubmatchIndex(data)
+	length, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+	stream := data[m[1] : m[1]+length]
+	block, _ = aes.NewCipher(key)
+	plain := make([]byte, len(stream)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, stream[:aes.BlockSize]).CryptBlocks(plain, stream[aes.BlockSize:])
+	plain = plain[:len(plain)-int(plain[len(plain)-1])]
+	if !bytes.Contains(plain, []byte("(Take-home pay: 9,906,250.00) Tj")) {
+		t.Errorf("Expected the decrypted stream to contain the text, but got %q", plain)
+	}
+}
+
+// countingReader yields the bytes 0, 1, 2, ... so that keys and salts are predictable.
+type countingReader struct{ next byte }
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.next
+		r.next++
+	}
+	return len(p), nil
+}
+
+// TestEncryptKnownAnswer checks the encryption dictionary against values
+// computed for the same keys, salts and passwords with an implementation of
+// ISO 32000-2 (algorithms 2.B, 8, 9 and 10) written separately from this
+// package, using Python's hashlib and the OpenSSL command line for AES. The
+// file key is bytes 0x00-0x1f, the salts 0x20-0x3f, the ID 0x40-0x4f and the
+// /Perms tail 0x50-0x53.
+func TestEncryptKnownAnswer(t *testing.T) {
+	doc := New(A4Width, A4Height)
+	doc.AddPage().Text(50, 50, Helvetica, 12, "Take-home pay: 9,906,250.00")
+	doc.Encrypt("01021990E042", "owner-secret")
+	doc.encryption.random = &countingReader{}
+
+	var out bytes.Buffer
+	if _, err := doc.WriteTo(&out); err != nil {
+		t.Fatalf("Expected the document to be written, but got %v", err)
+	}
+	expected := map[string]string{
+		"U":     "acb88b5904e989689bd2ec7eb1cc9de3fc760f86f8e4d888041f1eb83bf82aa8202122232425262728292a2b2c2d2e2f",
+		"UE":    "d17781ad30fa6f28df5a53ddab4bb11447b8be711ed2435c3cec1aace637284e",
+		"O":     "0b1d3465ee9389392de01e4cd308302b373f31affb1d750ec2a78dd3ca96cdb3303132333435363738393a3b3c3d3e3f",
+		"OE":    "3724205394b43c1058592923e687f88e889b717e733f7b3282882cf0e11ad1c6",
+		"Perms": "aabe562f1494fa39c841023fd3b0e225",
+	}
+	for name, want := range expected {
+		m := regexp.MustCompile(`/` + name + ` <([0-9a-f]+)>`).FindSubmatch(out.Bytes())
+		if m == nil || string(m[1]) != want {
+			t.Errorf("Expected /%s <%s>, but got %q", name, want, m)
+		}
+	}
+	if !bytes.Contains(out.Bytes(), []byte("/ID [<404142434445464748494a4b4c4d4e4f> <404142434445464748494a4b4c4d4e4f>]")) {
+		t.Errorf("Expected the document ID to be the 16 bytes read after the salts")
+	}
+}
@@ -0,0 +1,301 @@
+// Package pdf writes simple PDF documents of text, lines and shaded boxes.
+// It uses the standard Helvetica fonts every PDF reader provides, so no font
+// files or external programs are needed.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page sizes in points (1/72 inch).
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Font is one of the standard fonts.
+type Font int
+
+// The standard fonts a Document can use.
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+var fontNames = [...]string{Helvetica: "Helvetica", HelveticaBold: "Helvetica-Bold"}
+
+// Document is a PDF document being built page by page.
+type Document struct {
+	width, height float64
+	pages         []*Page
+	info          map[string]string
+	encryption    *encryption // Set by Encrypt
+}
+
+// Page is one page of a Document. Coordinates are in points from the
+// top-left corner, y increasing downwards.
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+}
+
+// New returns an empty document with pages of the given size.
+func New(width, height float64) *Document {
+	return &Document{width: width, height: height, info: map[string]string{}}
+}
+
+// SetInfo sets a document information entry such as "Title" or "Author".
+func (d *Document) SetInfo(key, value string) {
+	d.info[key] = value
+}
+
+// Width returns the page width.
+func (d *Document) Width() float64 { return d.width }
+
+// Height returns the page height.
+func (d *Document) Height() float64 { return d.height }
+
+// AddPage appends a blank page and returns it.
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Pages returns the number of pages added so far.
+func (d *Document) Pages() int { return len(d.pages) }
+
+// Page returns the nth page, counting from 1, to draw on it again such as to
+// add page numbers once the number of pages is known.
+func (d *Document) Page(n int) *Page { return d.pages[n-1] }
+
+// Text draws text with its baseline starting at (x, y).
+func (p *Page) Text(x, y float64, font Font, size float64, text string) {
+	fmt.Fprintf(&p.content, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		font+1, num(size), num(x), num(p.doc.height-y), escape(encode(text)))
+}
+
+// TextRight draws text ending at x, for right-aligned columns such as amounts.
+func (p *Page) TextRight(x, y float64, font Font, size float64, text string) {
+	p.Text(x-TextWidth(font, size, text), y, font, size, text)
+}
+
+// Line draws a line of the given width and gray level (0 black, 1 white).
+func (p *Page) Line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(&p.content, "q %s G %s w %s %s m %s %s l S Q\n",
+		num(gray), num(width), num(x1), num(p.doc.height-y1), num(x2), num(p.doc.height-y2))
+}
+
+// FillRect fills a rectangle whose top-left corner is (x, y) with a gray level.
+func (p *Page) FillRect(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.content, "q %s g %s %s %s %s re f Q\n",
+		num(gray), num(x), num(p.doc.height-y-h), num(w), num(h))
+}
+
+// TextWidth returns the width of text set in a font at a size.
+func TextWidth(font Font, size float64, text string) float64 {
+	widths := &helveticaWidths
+	if font == HelveticaBold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, b := range encode(text) {
+		if b >= 32 && b < 127 {
+			total += widths[b-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Truncate shortens text with an ellipsis so that it fits within width.
+func Truncate(font Font, size, width float64, text string) string {
+	if TextWidth(font, size, text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && TextWidth(font, size, string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// WriteTo writes the document in PDF format.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if d.encryption != nil {
+		if err := d.encryption.setup(); err != nil {
+			return 0, err
+		}
+	}
+
+	out := &countingWriter{w: bufio.NewWriter(w)}
+	var offsets []int64
+	object := func(body string) {
+		offsets = append(offsets, out.n)
+		fmt.Fprintf(out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	// text writes a string, encrypted if the document is.
+	text := func(b []byte) (string, error) {
+		if d.encryption == nil {
+			return "(" + escape(b) + ")", nil
+		}
+		encrypted, err := d.encryption.encrypt(b)
+		return fmt.Sprintf("<%x>", encrypted), err
+	}
+
+	// Objects 1 and 2 are the catalog and page tree, followed by the fonts,
+	// the information dictionary, a page and content stream per page and
+	// the encryption dictionary, if any.
+	firstPage := 3 + len(fontNames) + 1
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	var fonts strings.Builder
+	for i := range fontNames {
+		fmt.Fprintf(&fonts, "/F%d %d 0 R ", i+1, 3+i)
+	}
+
+	if d.encryption == nil {
+		fmt.Fprint(out, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+		object("<< /Type /Catalog /Pages 2 0 R >>")
+	} else {
+		// AES-256 came with PDF 2.0 and, before it, Adobe's extension level 8 to 1.7.
+		fmt.Fprint(out, "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+		object("<< /Type /Catalog /Pages 2 0 R /Extensions << /ADBE << /BaseVersion /1.7 /ExtensionLevel 8 >> >> >>")
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	for _, name := range fontNames {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+	var info strings.Builder
+	for _, key := range []string{"Title", "Author", "Subject", "Creator", "Producer"} {
+		if value, ok := d.info[key]; ok {
+			s, err := text(encode(value))
+			if err != nil {
+				return out.n, err
+			}
+			fmt.Fprintf(&info, "/%s %s ", key, s)
+		}
+	}
+	object(fmt.Sprintf("<< %s>>", info.String()))
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << %s>> >> /Contents %d 0 R >>",
+			num(d.width), num(d.height), fonts.String(), firstPage+2*i+1))
+		content := page.content.Bytes()
+		if d.encryption != nil {
+			var err error
+			if content, err = d.encryption.encrypt(content); err != nil {
+				return out.n, err
+			}
+		}
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	trailer := fmt.Sprintf("/Size %d /Root 1 0 R /Info %d 0 R", len(offsets)+1, firstPage-1)
+	if d.encryption != nil {
+		object(d.encryption.dictionary())
+		trailer = fmt.Sprintf("/Size %d /Root 1 0 R /Info %d 0 R /Encrypt %d 0 R /ID [<%x> <%x>]",
+			len(offsets)+1, firstPage-1, len(offsets), d.encryption.id, d.encryption.id)
+	}
+
+	xref := out.n
+	fmt.Fprintf(out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(out, "trailer\n<< %s >>\nstartxref\n%d\n%%%%EOF\n", trailer, xref)
+	if out.err != nil {
+		return out.n, out.err
+	}
+	return out.n, out.w.Flush()
+}
+
+// countingWriter counts the bytes written for the cross-reference table and
+// keeps the first error so the writes above don't each need checking.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// encode converts text to the WinAnsi encoding of the standard fonts. Latin-1
+// characters are kept, a few common typographic ones are mapped and the rest
+// become "?".
+func encode(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r < 128 || (r >= 160 && r <= 255):
+			out = append(out, byte(r))
+		case winAnsiExtras[r] != 0:
+			out = append(out, winAnsiExtras[r])
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// escape quotes the characters with a special meaning in PDF strings.
+func escape(b []byte) string {
+	var s strings.Builder
+	for _, c := range b {
+		switch c {
+		case '\\', '(', ')':
+			s.WriteByte('\\')
+			s.WriteByte(c)
+		case '\n', '\r':
+			s.WriteByte(' ')
+		default:
+			s.WriteByte(c)
+		}
+	}
+	return s.String()
+}
+
+// num formats a coordinate without needless decimals.
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Glyph widths of the printable ASCII characters, in thousandths of the font
+// size, from the Adobe font metrics of the standard fonts.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
@@ -0,0 +1,72 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	doc := New(A4Width, A4Height)
+	doc.SetInfo("Title", "Payslip (June)")
+	first := doc.AddPage()
+	first.FillRect(40, 40, 100, 20, 0.9)
+	first.Text(50, 55, HelveticaBold, 12, "Total: Rp 1.000 (net)")
+	first.Line(40, 70, 200, 70, 0.5, 0)
+	doc.AddPage().TextRight(200, 100, Helvetica, 10, "Café – €5")
+
+	var out bytes.Buffer
+	n, err := doc.WriteTo(&out)
+	if err != nil {
+		t.Fatalf("Expected the document to be written, but got %v", err)
+	}
+	if n != int64(out.Len()) {
+		t.Errorf("Expected %d bytes to be reported, but got %d", out.Len(), n)
+	}
+	data := out.Bytes()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatalf("Expected a PDF header and trailer, but got %q", data)
+	}
+	if !bytes.Contains(data, []byte("/Count 2")) {
+		t.Errorf("Expected two pages")
+	}
+	if !bytes.Contains(data, []byte(`(Total: Rp 1.000 \(net\)) Tj`)) {
+		t.Errorf("Expected parentheses in text to be escaped")
+	}
+	if !bytes.Contains(data, []byte("(Caf\xe9 \x96 \x805) Tj")) {
+		t.Errorf("Expected text to be WinAnsi encoded")
+	}
+
+	// Every cross-reference entry must point at the start of its object.
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		t.Fatalf("Expected startxref to point at the xref table")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	if len(entries) != 9 {
+		t.Fatalf("Expected 9 objects, but got %d", len(entries))
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(string(data[offset:]), want) {
+			t.Errorf("Expected object %d at offset %d", i+1, offset)
+		}
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	// "Wi" is 944 + 222 thousandths in Helvetica and 944 + 278 in bold.
+	if w := TextWidth(Helvetica, 10, "Wi"); w != 11.66 {
+		t.Errorf("Expected a width of 11.66, but got %v", w)
+	}
+	if w := TextWidth(HelveticaBold, 10, "Wi"); w != 12.22 {
+		t.Errorf("Expected a width of 12.22, but got %v", w)
+	}
+	if s := Truncate(Helvetica, 10, 30, "Reimbursement"); s != "Rei..." {
+		t.Errorf("Expected the text to be truncated to fit, but got %q", s)
+	}
+}
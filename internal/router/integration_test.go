@@ -1,20 +1,25 @@
 package router_test
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"payslip-generator/internal/config"
 	"payslip-generator/internal/database"
+	"payslip-generator/internal/mail"
+	"payslip-generator/internal/mail/mailtest"
 	"payslip-generator/internal/models"
 	"payslip-generator/internal/router"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -25,6 +30,8 @@ func TestMain(m *testing.M) {
 	// Setup
 	gin.SetMode(gin.TestMode)
 	config.LoadConfig() // Although we override DB, good practice to load others
+	os.Setenv("JWT_SECRET", "integration-test-secret")
+	os.Setenv("DOCUMENT_PASSWORD_KEY", "integration-test-document-key")
 
 	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
 	if err != nil {
@@ -36,6 +43,15 @@ func TestMain(m *testing.M) {
 		&models.Employee{}, &models.Admin{}, &models.Attendance{},
 		&models.Overtime{}, &models.Reimbursement{}, &models.PayrollPeriod{},
 		&models.Payslip{}, &models.AuditLog{},
+		&models.Role{}, &models.RolePermission{}, &models.SeededPermission{}, &models.SetupStep{},
+		&models.PayrollRun{}, &models.PayrollRunError{},
+		&models.TaxTable{}, &models.TaxBracket{},
+		&models.PayslipLineItem{}, &models.RecurringPayComponent{}, &models.ContributionRule{}, &models.ExchangeRate{},
+		&models.HolidayCalendar{}, &models.Holiday{}, &models.WorkSchedule{},
+		&models.LeaveType{}, &models.LeaveRequest{}, &models.LeaveLedgerEntry{},
+		&models.ClaimApproval{}, &models.ApprovalRule{}, &models.ReimbursementReceipt{}, &models.ReimbursementCategory{},
+		&models.OvertimePolicy{}, &models.OvertimeTier{}, &models.PayslipTemplate{}, &models.PayslipTemplateVersion{},
+		&models.PayslipProtection{}, &models.PayslipDelivery{},
 	)
 
 	testRouter = router.SetupRouter()
@@ -46,13 +62,38 @@ func TestMain(m *testing.M) {
 }
 
 func performRequest(r http.Handler, method, path string, body []byte) *httptest.ResponseRecorder {
+	return performAuthRequest(r, method, path, "", body)
+}
+
+func performAuthRequest(r http.Handler, method, path, token string, body []byte) *httptest.ResponseRecorder {
 	req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 	return w
 }
 
+// login obtains an access token through the public login endpoint.
+func login(t *testing.T, userType, username, password string) string {
+	t.Helper()
+	payload := []byte(fmt.Sprintf(`{"username": %q, "password": %q, "userType": %q}`, username, password, userType))
+	w := performRequest(testRouter, "POST", "/auth/login", payload)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for logging in as %s, got %d. Body: %s", username, w.Code, w.Body.String())
+	}
+	var response struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Token == "" {
+		t.Fatalf("Expected a token for %s, got none", username)
+	}
+	return response.Token
+}
+
 func TestFullPayrollFlow(t *testing.T) {
 	// 1. Seed the database
 	w_seed := performRequest(testRouter, "POST", "/seed", nil)
@@ -60,9 +101,26 @@ func TestFullPayrollFlow(t *testing.T) {
 		t.Fatalf("Expected status 200 for seeding, got %d", w_seed.Code)
 	}
 
-	// 2. Admin creates a payroll period
-	periodPayload := []byte(`{"startDate": "2025-06-01", "endDate": "2025-06-30", "adminId": 1}`)
-	w_period := performRequest(testRouter, "POST", "/admin/payroll-periods", periodPayload)
+	adminToken := login(t, "admin", "admin", "admin")
+	employeeToken := login(t, "employee", "employee5", "employee5")
+
+	// Payslips are emailed through a local SMTP stand-in
+	mailServer := mailtest.NewServer()
+	defer mailServer.Close()
+	sender, err := mail.NewSMTPSender(mail.SMTPConfig{Host: mailServer.Host, Port: mailServer.Port, From: "payroll@example.com", TLS: mail.TLSNone})
+	if err != nil {
+		t.Fatalf("Expected an SMTP sender, got %v", err)
+	}
+	mail.Outgoing = sender
+	defer func() { mail.Outgoing = nil }()
+	mailServer.Reject("employee7@example.com")
+
+	// 2. Admin creates a payroll period covering the current month
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, -1)
+	periodPayload := []byte(fmt.Sprintf(`{"startDate": %q, "endDate": %q}`, monthStart.Format("2006-01-02"), monthEnd.Format("2006-01-02")))
+	w_period := performAuthRequest(testRouter, "POST", "/admin/payroll-periods", adminToken, periodPayload)
 	if w_period.Code != http.StatusCreated {
 		t.Fatalf("Expected status 201 for creating period, got %d", w_period.Code)
 	}
@@ -73,23 +131,120 @@ func TestFullPayrollFlow(t *testing.T) {
 	}
 
 	// 3. Employee submits attendance
-	attendancePayload := []byte(`{"employeeId": 5}`)
-	w_att := performRequest(testRouter, "POST", "/employee/attendance", attendancePayload)
+	w_att := performAuthRequest(testRouter, "POST", "/employee/attendance", employeeToken, nil)
 	if w_att.Code != http.StatusCreated {
 		t.Fatalf("Expected status 201 for submitting attendance, got %d", w_att.Code)
 	}
 
-	// 4. Admin runs payroll
-	runPayload := []byte(`{"payrollPeriodId": 1, "adminId": 1}`)
-	w_run := performRequest(testRouter, "POST", "/admin/run-payroll", runPayload)
+	// 4. Admin previews, then runs payroll
+	w_preview := performAuthRequest(testRouter, "POST", "/admin/payroll-periods/1/preview", adminToken, nil)
+	if w_preview.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for previewing payroll, got %d", w_preview.Code)
+	}
+	var previewResponse struct {
+		EmployeeCount int `json:"employeeCount"`
+	}
+	json.Unmarshal(w_preview.Body.Bytes(), &previewResponse)
+	if previewResponse.EmployeeCount != 100 {
+		t.Errorf("Expected a preview for 100 employees, got %d", previewResponse.EmployeeCount)
+	}
+
+	runPayload := []byte(`{"payrollPeriodId": 1}`)
+	w_run := performAuthRequest(testRouter, "POST", "/admin/run-payroll", adminToken, runPayload)
 	if w_run.Code != http.StatusAccepted {
 		t.Fatalf("Expected status 202 for running payroll, got %d", w_run.Code)
 	}
-	// In a real app, you might need a small delay for the goroutine to finish
-	time.Sleep(100 * time.Millisecond)
+	var runResponse struct {
+		PayrollRun models.PayrollRun `json:"payrollRun"`
+	}
+	json.Unmarshal(w_run.Body.Bytes(), &runResponse)
+	if runResponse.PayrollRun.ID == 0 {
+		t.Fatal("Expected the payroll run to be returned, got zero ID")
+	}
+
+	// Poll the run until the background job finishes
+	var run models.PayrollRun
+	for i := 0; i < 100; i++ {
+		w_status := performAuthRequest(testRouter, "GET", fmt.Sprintf("/admin/payroll-runs/%d", runResponse.PayrollRun.ID), adminToken, nil)
+		if w_status.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for polling payroll run, got %d", w_status.Code)
+		}
+		json.Unmarshal(w_status.Body.Bytes(), &run)
+		if run.FinishedAt != nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if run.Status != models.PayrollRunSucceeded {
+		t.Fatalf("Expected payroll run to succeed, got status %q with errors %v", run.Status, run.Errors)
+	}
+	if run.ProcessedCount != run.TotalEmployees || run.TotalEmployees == 0 {
+		t.Errorf("Expected all %d employees to be processed, got %d", run.TotalEmployees, run.ProcessedCount)
+	}
+
+	// Running the same period again is rejected
+	w_rerun := performAuthRequest(testRouter, "POST", "/admin/run-payroll", adminToken, runPayload)
+	if w_rerun.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for re-running payroll, got %d", w_rerun.Code)
+	}
+
+	// The payslips are emailed after the run; the one to a refused address bounces.
+	// Wait until every payslip has a delivery and none is still queued.
+	var deliveries []models.PayslipDelivery
+	for i := 0; i < 100; i++ {
+		w_deliveries := performAuthRequest(testRouter, "GET", "/admin/payroll-periods/1/payslips/deliveries", adminToken, nil)
+		if w_deliveries.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for listing payslip deliveries, got %d", w_deliveries.Code)
+		}
+		deliveries = nil
+		json.Unmarshal(w_deliveries.Body.Bytes(), &deliveries)
+		queued := 0
+		for _, d := range deliveries {
+			if d.Status == models.DeliveryQueued {
+				queued++
+			}
+		}
+		if len(deliveries) == 100 && queued == 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	statuses := map[string]int{}
+	var bounced models.PayslipDelivery
+	for _, d := range deliveries {
+		statuses[d.Status]++
+		if d.Status == models.DeliveryBounced {
+			bounced = d
+		}
+	}
+	if statuses[models.DeliverySent] != 99 || statuses[models.DeliveryBounced] != 1 || bounced.Recipient != "employee7@example.com" {
+		t.Fatalf("Expected 99 payslips sent and the one to employee7 bounced, got %v", statuses)
+	}
+	if len(mailServer.Messages()) != 99 {
+		t.Errorf("Expected the SMTP server to receive 99 messages, got %d", len(mailServer.Messages()))
+	}
+	if w_email := performAuthRequest(testRouter, "PUT", fmt.Sprintf("/admin/employees/%d/email", bounced.EmployeeID), adminToken, []byte(`{"email": "not an address"}`)); w_email.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid email address, got %d", w_email.Code)
+	}
+	if w_email := performAuthRequest(testRouter, "PUT", fmt.Sprintf("/admin/employees/%d/email", bounced.EmployeeID), adminToken, []byte(`{"email": "e7@example.com"}`)); w_email.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for fixing the email address, got %d", w_email.Code)
+	}
+	w_resend := performAuthRequest(testRouter, "POST", fmt.Sprintf("/admin/payslip-deliveries/%d/resend", bounced.ID), adminToken, nil)
+	if w_resend.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202 for resending the payslip, got %d. Body: %s", w_resend.Code, w_resend.Body.String())
+	}
+	for i := 0; i < 100 && len(mailServer.Messages()) < 100; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if messages := mailServer.Messages(); len(messages) != 100 || messages[99].To[0] != "e7@example.com" {
+		t.Errorf("Expected the payslip to be resent to the new address")
+	}
+	if w_send := performAuthRequest(testRouter, "POST", "/admin/payroll-periods/1/payslips/send", adminToken, nil); w_send.Code != http.StatusAccepted || w_send.Body.String() != "[]" {
+		t.Errorf("Expected nothing left to send, got %d: %s", w_send.Code, w_send.Body.String())
+	}
 
 	// 5. Employee generates their payslip
-	w_get_payslip := performRequest(testRouter, "GET", "/employee/payslip?employee_id=5&period_id=1", nil)
+	w_get_payslip := performAuthRequest(testRouter, "GET", "/employee/payslip?period_id=1", employeeToken, nil)
 	if w_get_payslip.Code != http.StatusOK {
 		t.Fatalf("Expected status 200 for getting payslip, got %d. Body: %s", w_get_payslip.Code, w_get_payslip.Body.String())
 	}
@@ -104,9 +259,90 @@ func TestFullPayrollFlow(t *testing.T) {
 	if payslipResponse.TakeHomePay <= 0 {
 		t.Error("Expected positive take-home pay, but got zero or less")
 	}
+	if len(payslipResponse.LineItems) == 0 || payslipResponse.LineItems[0].Code != "BASIC_SALARY" {
+		t.Errorf("Expected the payslip to be itemized starting with BASIC_SALARY, got %+v", payslipResponse.LineItems)
+	}
+
+	// The payslip can also be downloaded as a PDF, by the employee and by an admin
+	w_pdf := performAuthRequest(testRouter, "GET", "/employee/payslip.pdf?period_id=1", employeeToken, nil)
+	if w_pdf.Code != http.StatusOK || w_pdf.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("Expected a PDF payslip with status 200, got %d. Body: %s", w_pdf.Code, w_pdf.Body.String())
+	}
+	if !bytes.HasPrefix(w_pdf.Body.Bytes(), []byte("%PDF-")) || !bytes.Contains(w_pdf.Body.Bytes(), []byte("(employee5) Tj")) {
+		t.Error("Expected a PDF document naming the employee")
+	}
+	w_admin_pdf := performAuthRequest(testRouter, "GET", "/admin/employees/5/payslip.pdf?period_id=1", adminToken, nil)
+	if w_admin_pdf.Code != http.StatusOK || !bytes.Equal(w_admin_pdf.Body.Bytes(), w_pdf.Body.Bytes()) {
+		t.Errorf("Expected the admin to download the same PDF, got status %d", w_admin_pdf.Code)
+	}
+	w_missing_pdf := performAuthRequest(testRouter, "GET", "/employee/payslip.pdf?period_id=99", employeeToken, nil)
+	if w_missing_pdf.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a period without a payslip, got %d", w_missing_pdf.Code)
+	}
+
+	// An admin sets up an HTML template for the employee's group, which browsers then get
+	w_preview_tmpl := performAuthRequest(testRouter, "POST", "/admin/payslip-templates/preview", adminToken, []byte(`{"body": "<h1>{{.Employee.Username}}</h1>"}`))
+	if w_preview_tmpl.Code != http.StatusOK || w_preview_tmpl.Body.String() != "<h1>jdoe</h1>" {
+		t.Errorf("Expected the template to be previewed with sample data, got %d: %s", w_preview_tmpl.Code, w_preview_tmpl.Body.String())
+	}
+	tmplPayload := []byte(`{"name": "PT Example", "group": "pt-example", "body": "<h1>Slip gaji {{.Employee.Username}}</h1>"}`)
+	if w_tmpl := performAuthRequest(testRouter, "POST", "/admin/payslip-templates", adminToken, tmplPayload); w_tmpl.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 for creating a payslip template, got %d. Body: %s", w_tmpl.Code, w_tmpl.Body.String())
+	}
+	if w_group := performAuthRequest(testRouter, "PUT", "/admin/employees/5/group", adminToken, []byte(`{"group": "pt-example"}`)); w_group.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for setting the employee's group, got %d", w_group.Code)
+	}
+	req_html, _ := http.NewRequest("GET", "/employee/payslip?period_id=1", nil)
+	req_html.Header.Set("Authorization", "Bearer "+employeeToken)
+	req_html.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9")
+	w_html := httptest.NewRecorder()
+	testRouter.ServeHTTP(w_html, req_html)
+	if w_html.Code != http.StatusOK || w_html.Body.String() != "<h1>Slip gaji employee5</h1>" {
+		t.Errorf("Expected the payslip rendered with the group's template, got %d: %s", w_html.Code, w_html.Body.String())
+	}
+
+	// The group's PDFs are then encrypted with a password derived from the employee's details, or one they choose
+	protectionPayload := []byte(`{"group": "pt-example", "scheme": "employee", "passwordRule": "{dob}{employeeNumber}"}`)
+	if w_protection := performAuthRequest(testRouter, "PUT", "/admin/payslip-protection", adminToken, protectionPayload); w_protection.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for protecting the group's payslips, got %d. Body: %s", w_protection.Code, w_protection.Body.String())
+	}
+	if w_unprotected := performAuthRequest(testRouter, "GET", "/employee/payslip.pdf?period_id=1", employeeToken, nil); w_unprotected.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 without the details the password is derived from, got %d", w_unprotected.Code)
+	}
+	detailsPayload := []byte(`{"employeeNumber": "E005", "dateOfBirth": "1990-02-01"}`)
+	if w_details := performAuthRequest(testRouter, "PUT", "/admin/employees/5/personal-details", adminToken, detailsPayload); w_details.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for setting personal details, got %d. Body: %s", w_details.Code, w_details.Body.String())
+	}
+	w_encrypted := performAuthRequest(testRouter, "GET", "/employee/payslip.pdf?period_id=1", employeeToken, nil)
+	if w_encrypted.Code != http.StatusOK || !bytes.Contains(w_encrypted.Body.Bytes(), []byte("/Encrypt ")) || bytes.Contains(w_encrypted.Body.Bytes(), []byte("(employee5) Tj")) {
+		t.Errorf("Expected an encrypted PDF, got status %d", w_encrypted.Code)
+	}
+	if w_short := performAuthRequest(testRouter, "PUT", "/employee/document-password", employeeToken, []byte(`{"password": "short"}`)); w_short.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a short document password, got %d", w_short.Code)
+	}
+	if w_docpw := performAuthRequest(testRouter, "PUT", "/employee/document-password", employeeToken, []byte(`{"password": "my payslips"}`)); w_docpw.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for setting a document password, got %d. Body: %s", w_docpw.Code, w_docpw.Body.String())
+	}
+
+	// HR downloads every payslip of the period at once
+	w_export := performAuthRequest(testRouter, "GET", "/admin/payroll-periods/1/payslips/export", adminToken, nil)
+	if w_export.Code != http.StatusOK || w_export.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("Expected a ZIP export with status 200, got %d. Body: %s", w_export.Code, w_export.Body.String())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w_export.Body.Bytes()), int64(w_export.Body.Len()))
+	if err != nil {
+		t.Fatalf("Expected a valid ZIP archive, got %v", err)
+	}
+	exported := map[string]bool{}
+	for _, f := range archive.File {
+		exported[f.Name] = true
+	}
+	if !exported["manifest.csv"] || !exported["payslip-employee5-"+monthStart.Format("2006-01-02")+"_"+monthEnd.Format("2006-01-02")+".pdf"] {
+		t.Errorf("Expected the employee's payslip and the manifest in the export, got %v", exported)
+	}
 
 	// 6. Check that the audit log was created
-	w_logs := performRequest(testRouter, "GET", "/admin/audit-logs", nil)
+	w_logs := performAuthRequest(testRouter, "GET", "/admin/audit-logs", adminToken, nil)
 	if w_logs.Code != http.StatusOK {
 		t.Fatalf("Expected status 200 for getting audit logs, got %d", w_logs.Code)
 	}
@@ -127,3 +363,95 @@ func TestFullPayrollFlow(t *testing.T) {
 		t.Error("Expected to find an audit log for running payroll, but it was not found")
 	}
 }
+
+func TestAuthorization(t *testing.T) {
+	performRequest(testRouter, "POST", "/seed", nil)
+
+	t.Run("rejects requests without a token", func(t *testing.T) {
+		w := performRequest(testRouter, "GET", "/admin/audit-logs", nil)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 without a token, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects wrong credentials", func(t *testing.T) {
+		payload := []byte(`{"username": "admin", "password": "wrong", "userType": "admin"}`)
+		w := performRequest(testRouter, "POST", "/auth/login", payload)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 for wrong password, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects employees on admin routes", func(t *testing.T) {
+		employeeToken := login(t, "employee", "employee7", "employee7")
+		w := performAuthRequest(testRouter, "GET", "/admin/audit-logs", employeeToken, nil)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for employee on admin route, got %d", w.Code)
+		}
+	})
+}
+
+func TestRoleBasedAccess(t *testing.T) {
+	performRequest(testRouter, "POST", "/seed", nil)
+	adminToken := login(t, "admin", "admin", "admin")
+
+	// Create a second admin without any roles
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("auditor"), bcrypt.MinCost)
+	auditor := models.Admin{Username: "auditor", Password: string(hashed)}
+	database.DB.FirstOrCreate(&auditor, "username = ?", "auditor")
+	auditorToken := login(t, "admin", "auditor", "auditor")
+
+	t.Run("admin without roles is denied", func(t *testing.T) {
+		w := performAuthRequest(testRouter, "GET", "/admin/audit-logs", auditorToken, nil)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for admin without roles, got %d", w.Code)
+		}
+	})
+
+	var roles []struct {
+		ID   uint   `json:"id"`
+		Name string `json:"name"`
+	}
+	w_roles := performAuthRequest(testRouter, "GET", "/admin/roles", adminToken, nil)
+	if w_roles.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for listing roles, got %d", w_roles.Code)
+	}
+	json.Unmarshal(w_roles.Body.Bytes(), &roles)
+	var auditorRoleID uint
+	for _, r := range roles {
+		if r.Name == "auditor" {
+			auditorRoleID = r.ID
+		}
+	}
+	if auditorRoleID == 0 {
+		t.Fatal("Expected the built-in auditor role to be seeded")
+	}
+
+	assignPayload := []byte(fmt.Sprintf(`{"roleIds": [%d]}`, auditorRoleID))
+	w_assign := performAuthRequest(testRouter, "PUT", fmt.Sprintf("/admin/admins/%d/roles", auditor.ID), adminToken, assignPayload)
+	if w_assign.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for assigning roles, got %d. Body: %s", w_assign.Code, w_assign.Body.String())
+	}
+
+	t.Run("auditor can read audit logs", func(t *testing.T) {
+		w := performAuthRequest(testRouter, "GET", "/admin/audit-logs", auditorToken, nil)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200 for auditor reading audit logs, got %d", w.Code)
+		}
+	})
+
+	t.Run("auditor cannot run payroll", func(t *testing.T) {
+		w := performAuthRequest(testRouter, "POST", "/admin/run-payroll", auditorToken, []byte(`{"payrollPeriodId": 1}`))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for auditor running payroll, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects roles with unknown permissions", func(t *testing.T) {
+		payload := []byte(`{"name": "bogus", "permissions": ["payroll:delete"]}`)
+		w := performAuthRequest(testRouter, "POST", "/admin/roles", adminToken, payload)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for unknown permission, got %d", w.Code)
+		}
+	})
+}
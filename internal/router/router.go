@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"payslip-generator/internal/handlers"
 	"payslip-generator/internal/middleware"
+	"payslip-generator/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -22,22 +23,148 @@ func SetupRouter() *gin.Engine {
 	// Public Endpoint to Seed Data
 	r.POST("/seed", handlers.SeedDatabase)
 
+	// Public Endpoint to obtain an access token
+	r.POST("/auth/login", handlers.Login)
+
 	// Admin Routes
-	admin := r.Group("/admin")
+	admin := r.Group("/admin", middleware.AuthRequired(services.UserTypeAdmin))
 	{
-		admin.POST("/payroll-periods", handlers.CreatePayrollPeriod)
-		admin.POST("/run-payroll", handlers.RunPayroll)
-		admin.GET("/payslips/summary", handlers.GetPayslipSummary)
-		admin.GET("/audit-logs", handlers.GetAuditLogs) // New endpoint to view audit logs
+		admin.POST("/payroll-periods", middleware.RequirePermission(services.PermManagePeriods), handlers.CreatePayrollPeriod)
+		admin.POST("/payroll-periods/:id/preview", middleware.RequirePermission(services.PermRunPayroll), handlers.PreviewPayroll)
+		admin.POST("/payroll-periods/:id/reverse", middleware.RequirePermission(services.PermReversePayroll), handlers.ReversePayroll)
+		admin.GET("/payroll-periods/:id/payslips/export", middleware.RequirePermission(services.PermReadPayslips), handlers.ExportPayslips)
+		admin.GET("/payroll-periods/:id/payslips/deliveries", middleware.RequirePermission(services.PermReadPayslips), handlers.ListPayslipDeliveries)
+		admin.POST("/payroll-periods/:id/payslips/send", middleware.RequirePermission(services.PermSendPayslips), handlers.SendPayslips)
+		admin.POST("/payslip-deliveries/:id/resend", middleware.RequirePermission(services.PermSendPayslips), handlers.ResendPayslip)
+		admin.POST("/run-payroll", middleware.RequirePermission(services.PermRunPayroll), handlers.RunPayroll)
+		admin.GET("/payroll-runs/:id", middleware.RequirePermission(services.PermRunPayroll), handlers.GetPayrollRun)
+		admin.GET("/payslips/summary", middleware.RequirePermission(services.PermReadPayslips), handlers.GetPayslipSummary)
+		admin.GET("/employees/:id/payslip.pdf", middleware.RequirePermission(services.PermReadPayslips), handlers.DownloadEmployeePayslipPDF)
+		admin.GET("/audit-logs", middleware.RequirePermission(services.PermReadAuditLogs), handlers.GetAuditLogs) // New endpoint to view audit logs
+
+		// Overtime and reimbursement approval
+		claims := admin.Group("", middleware.RequirePermission(services.PermApproveClaims))
+		claims.GET("/claims/pending", handlers.ListPendingClaims)
+		claims.POST("/overtime/:id/approve", handlers.ApproveOvertime)
+		claims.POST("/overtime/:id/reject", handlers.RejectOvertime)
+		claims.POST("/reimbursements/:id/approve", handlers.ApproveReimbursement)
+		claims.POST("/reimbursements/:id/reject", handlers.RejectReimbursement)
+		claims.GET("/reimbursements/:id/receipts/:receiptId", handlers.DownloadReceipt)
+
+		approvalRules := admin.Group("/approval-rules", middleware.RequirePermission(services.PermManageApprovalRules))
+		approvalRules.GET("", handlers.ListApprovalRules)
+		approvalRules.POST("", handlers.CreateApprovalRule)
+		approvalRules.DELETE("/:id", handlers.DeleteApprovalRule)
+
+		categories := admin.Group("/reimbursement-categories", middleware.RequirePermission(services.PermManageReimbursementCategories))
+		categories.GET("", handlers.ListReimbursementCategories)
+		categories.POST("", handlers.CreateReimbursementCategory)
+		categories.PUT("/:id", handlers.UpdateReimbursementCategory)
+
+		overtimePolicies := admin.Group("/overtime-policies", middleware.RequirePermission(services.PermManageOvertimePolicies))
+		overtimePolicies.GET("", handlers.ListOvertimePolicies)
+		overtimePolicies.POST("", handlers.CreateOvertimePolicy)
+		overtimePolicies.GET("/:id", handlers.GetOvertimePolicy)
+
+		payslipTemplates := admin.Group("/payslip-templates", middleware.RequirePermission(services.PermManagePayslipTemplates))
+		payslipTemplates.GET("", handlers.ListPayslipTemplates)
+		payslipTemplates.POST("", handlers.CreatePayslipTemplate)
+		payslipTemplates.POST("/preview", handlers.PreviewPayslipTemplateSource)
+		payslipTemplates.GET("/:id", handlers.GetPayslipTemplate)
+		payslipTemplates.PUT("/:id", handlers.UpdatePayslipTemplate)
+		payslipTemplates.GET("/:id/preview", handlers.PreviewPayslipTemplate)
+
+		payslipProtection := admin.Group("/payslip-protection", middleware.RequirePermission(services.PermManagePayslipProtection))
+		payslipProtection.GET("", handlers.ListPayslipProtections)
+		payslipProtection.PUT("", handlers.UpdatePayslipProtection)
+
+		// Income tax and statutory contribution configuration
+		taxes := admin.Group("", middleware.RequirePermission(services.PermManageTaxes))
+		taxes.GET("/tax-tables", handlers.ListTaxTables)
+		taxes.POST("/tax-tables", handlers.CreateTaxTable)
+		taxes.GET("/tax-tables/:id", handlers.GetTaxTable)
+		taxes.GET("/contribution-rules", handlers.ListContributionRules)
+		taxes.POST("/contribution-rules", handlers.CreateContributionRule)
+
+		// Exchange rates
+		rates := admin.Group("/exchange-rates", middleware.RequirePermission(services.PermManageExchangeRates))
+		rates.GET("", handlers.ListExchangeRates)
+		rates.POST("", handlers.CreateExchangeRate)
+		rates.POST("/import", handlers.ImportExchangeRates)
+
+		// Holiday calendars
+		holidays := admin.Group("/holiday-calendars", middleware.RequirePermission(services.PermManageHolidays))
+		holidays.GET("", handlers.ListHolidayCalendars)
+		holidays.POST("", handlers.CreateHolidayCalendar)
+		holidays.GET("/:id", handlers.GetHolidayCalendar)
+		holidays.POST("/:id/holidays", handlers.AddHoliday)
+		holidays.DELETE("/:id/holidays/:holidayId", handlers.DeleteHoliday)
+		holidays.POST("/:id/import", handlers.ImportHolidays)
+
+		// Work schedules
+		schedules := admin.Group("/work-schedules", middleware.RequirePermission(services.PermManageWorkSchedules))
+		schedules.GET("", handlers.ListWorkSchedules)
+		schedules.POST("", handlers.CreateWorkSchedule)
+		schedules.PUT("/:id", handlers.UpdateWorkSchedule)
+
+		// Leave types, accruals and balances
+		leave := admin.Group("", middleware.RequirePermission(services.PermManageLeave))
+		leave.GET("/leave-types", handlers.ListLeaveTypes)
+		leave.POST("/leave-types", handlers.CreateLeaveType)
+		leave.PUT("/leave-types/:id", handlers.UpdateLeaveType)
+		leave.POST("/leave/accruals", handlers.AccrueLeave)
+		leave.POST("/leave/adjustments", handlers.AdjustLeaveBalance)
+		leave.GET("/employees/:id/leave-balances", handlers.GetEmployeeLeaveBalances)
+
+		// Leave approval
+		leaveApproval := admin.Group("/leave/requests", middleware.RequirePermission(services.PermApproveLeave))
+		leaveApproval.GET("", handlers.ListLeaveRequests)
+		leaveApproval.POST("/:id/approve", handlers.ApproveLeaveRequest)
+		leaveApproval.POST("/:id/reject", handlers.RejectLeaveRequest)
+
+		// Employee management
+		employees := admin.Group("/employees", middleware.RequirePermission(services.PermManageEmployees))
+		employees.PUT("/:id/salary", handlers.UpdateEmployeeSalary)
+		employees.PUT("/:id/tax-profile", handlers.UpdateEmployeeTaxProfile)
+		employees.PUT("/:id/holiday-calendar", handlers.UpdateEmployeeHolidayCalendar)
+		employees.PUT("/:id/work-schedule", handlers.UpdateEmployeeWorkSchedule)
+		employees.PUT("/:id/group", handlers.UpdateEmployeeGroup)
+		employees.PUT("/:id/personal-details", handlers.UpdateEmployeePersonalDetails)
+		employees.PUT("/:id/email", handlers.UpdateEmployeeEmail)
+		employees.GET("/:id/attendance", handlers.ListEmployeeAttendance)
+		employees.GET("/:id/pay-components", handlers.ListEmployeePayComponents)
+		employees.POST("/:id/pay-components", handlers.CreateEmployeePayComponent)
+		employees.PUT("/:id/pay-components/:componentId", handlers.UpdateEmployeePayComponent)
+		employees.DELETE("/:id/pay-components/:componentId", handlers.DeleteEmployeePayComponent)
+
+		// Role and permission management
+		roles := admin.Group("", middleware.RequirePermission(services.PermManageRoles))
+		roles.GET("/permissions", handlers.ListPermissions)
+		roles.GET("/roles", handlers.ListRoles)
+		roles.POST("/roles", handlers.CreateRole)
+		roles.PUT("/roles/:id", handlers.UpdateRole)
+		roles.DELETE("/roles/:id", handlers.DeleteRole)
+		roles.PUT("/admins/:id/roles", handlers.AssignAdminRoles)
 	}
 
 	// Employee Routes
-	employee := r.Group("/employee")
+	employee := r.Group("/employee", middleware.AuthRequired(services.UserTypeEmployee))
 	{
 		employee.POST("/attendance", handlers.SubmitAttendance)
+		employee.POST("/attendance/checkout", handlers.SubmitCheckout)
 		employee.POST("/overtime", handlers.SubmitOvertime)
+		employee.GET("/reimbursement-categories", handlers.ListReimbursementCategories)
 		employee.POST("/reimbursements", handlers.SubmitReimbursement)
+		employee.POST("/reimbursements/:id/receipts", handlers.UploadReimbursementReceipts)
+		employee.GET("/reimbursements/:id/receipts/:receiptId", handlers.DownloadMyReceipt)
 		employee.GET("/payslip", handlers.GeneratePayslip)
+		employee.GET("/payslip.pdf", handlers.DownloadPayslipPDF)
+		employee.PUT("/document-password", handlers.SetDocumentPassword)
+		employee.DELETE("/document-password", handlers.ClearDocumentPassword)
+		employee.GET("/leave/balances", handlers.GetMyLeaveBalances)
+		employee.GET("/leave/requests", handlers.ListMyLeaveRequests)
+		employee.POST("/leave/requests", handlers.SubmitLeaveRequest)
+		employee.POST("/leave/requests/:id/cancel", handlers.CancelLeaveRequest)
 	}
 
 	return r
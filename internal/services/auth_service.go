@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User types carried in access tokens and audit log entries.
+const (
+	UserTypeAdmin    = "admin"
+	UserTypeEmployee = "employee"
+)
+
+// tokenTTL is how long an issued access token stays valid.
+const tokenTTL = 12 * time.Hour
+
+// ErrInvalidCredentials is returned when the username or password does not match.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Claims is the JWT payload identifying an authenticated admin or employee.
+type Claims struct {
+	UserID   uint   `json:"uid"`
+	UserType string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// jwtSecret reads the signing key from the JWT_SECRET environment variable.
+func jwtSecret() ([]byte, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, errors.New("JWT_SECRET is not configured")
+	}
+	return []byte(secret), nil
+}
+
+// Authenticate checks the credentials against the stored bcrypt hash and
+// returns the ID of the matching admin or employee.
+func Authenticate(userType, username, password string) (uint, error) {
+	var userID uint
+	var hash string
+	switch userType {
+	case UserTypeAdmin:
+		var admin models.Admin
+		if err := database.DB.Where("username = ?", username).First(&admin).Error; err != nil {
+			return 0, ErrInvalidCredentials
+		}
+		userID, hash = admin.ID, admin.Password
+	case UserTypeEmployee:
+		var employee models.Employee
+		if err := database.DB.Where("username = ?", username).First(&employee).Error; err != nil {
+			return 0, ErrInvalidCredentials
+		}
+		userID, hash = employee.ID, employee.Password
+	default:
+		return 0, fmt.Errorf("unknown user type %q", userType)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return 0, ErrInvalidCredentials
+	}
+	return userID, nil
+}
+
+// GenerateToken issues a signed access token for the given principal.
+func GenerateToken(userID uint, userType string) (string, time.Time, error) {
+	secret, err := jwtSecret()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(tokenTTL)
+	claims := Claims{
+		UserID:   userID,
+		UserType: userType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// ParseToken validates the signature and expiry of a token and returns its claims.
+func ParseToken(tokenString string) (*Claims, error) {
+	secret, err := jwtSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
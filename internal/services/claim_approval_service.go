@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidApprovalRule is returned when an approval rule is inconsistent.
+	ErrInvalidApprovalRule = errors.New("invalid approval rule")
+	// ErrClaimNotFound is returned for an unknown overtime or reimbursement claim.
+	ErrClaimNotFound = errors.New("claim not found")
+	// ErrClaimNotPending is returned when deciding a claim that was already approved or rejected.
+	ErrClaimNotPending = errors.New("claim is not pending")
+	// ErrClaimAlreadyDecidedByAdmin is returned when an admin decides the same claim twice;
+	// each level of a multi-level approval must come from a different admin.
+	ErrClaimAlreadyDecidedByAdmin = errors.New("this admin has already decided on the claim")
+)
+
+// claimTables maps claim types to their tables.
+var claimTables = map[string]string{
+	models.ClaimTypeOvertime:      "overtimes",
+	models.ClaimTypeReimbursement: "reimbursements",
+}
+
+// ValidateApprovalRule checks that a rule has a threshold matching its claim type.
+func ValidateApprovalRule(r models.ApprovalRule) error {
+	if r.Levels < 2 {
+		return fmt.Errorf("%w: levels must be at least 2, as every claim needs one approval", ErrInvalidApprovalRule)
+	}
+	switch r.ClaimType {
+	case models.ClaimTypeOvertime:
+		if r.MinHours <= 0 || r.MinAmount != 0 || r.Currency != "" {
+			return fmt.Errorf("%w: overtime rules take a positive minHours only", ErrInvalidApprovalRule)
+		}
+	case models.ClaimTypeReimbursement:
+		if r.MinAmount <= 0 || r.MinHours != 0 {
+			return fmt.Errorf("%w: reimbursement rules take a positive minAmount and a currency", ErrInvalidApprovalRule)
+		}
+		if err := ValidateCurrency(r.Currency); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: claimType must be %q or %q", ErrInvalidApprovalRule, models.ClaimTypeOvertime, models.ClaimTypeReimbursement)
+	}
+	return nil
+}
+
+// RequiredApprovalsForOvertime returns how many approvals an overtime claim of the given hours needs.
+func RequiredApprovalsForOvertime(hours float64) (int, error) {
+	return requiredApprovals(database.DB.Where("claim_type = ? AND min_hours <= ?", models.ClaimTypeOvertime, hours))
+}
+
+// RequiredApprovalsForReimbursement returns how many approvals a reimbursement claim needs.
+// Only rules in the claim's currency apply.
+func RequiredApprovalsForReimbursement(amount money.Amount, currency string) (int, error) {
+	return requiredApprovals(database.DB.Where("claim_type = ? AND currency = ? AND min_amount <= ?", models.ClaimTypeReimbursement, currency, amount))
+}
+
+// requiredApprovals returns the highest level among the matching rules, or 1 if none match.
+func requiredApprovals(matching *gorm.DB) (int, error) {
+	var levels []int
+	if err := matching.Model(&models.ApprovalRule{}).Pluck("levels", &levels).Error; err != nil {
+		return 0, err
+	}
+	required := 1
+	for _, l := range levels {
+		if l > required {
+			required = l
+		}
+	}
+	return required, nil
+}
+
+// ClaimDecision is the state of a claim after an admin's decision.
+type ClaimDecision struct {
+	ClaimType         string `json:"claimType"`
+	ClaimID           uint   `json:"claimId"`
+	EmployeeID        uint   `json:"employeeId"`
+	Status            string `json:"status"`
+	Approvals         int    `json:"approvals"`
+	RequiredApprovals int    `json:"requiredApprovals"`
+}
+
+// DecideClaim records an admin's approval or rejection of a pending claim. A
+// rejection is final. An approval moves the claim to approved once it has
+// collected its required number of approvals from different admins; until then
+// it stays pending. Every decision is audited.
+func DecideClaim(claimType string, claimID, adminID uint, approve bool, reason, requestIP string) (ClaimDecision, error) {
+	table, ok := claimTables[claimType]
+	if !ok {
+		return ClaimDecision{}, fmt.Errorf("%w: unknown claim type %q", ErrClaimNotFound, claimType)
+	}
+
+	decision := ClaimDecision{ClaimType: claimType, ClaimID: claimID}
+	var level int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var claim struct {
+			EmployeeID        uint
+			Status            string
+			RequiredApprovals int
+		}
+		// Lock the claim so concurrent decisions are counted one after the other.
+		err := tx.Table(table).Clauses(clause.Locking{Strength: "UPDATE"}).Select("employee_id", "status", "required_approvals").
+			Where("id = ? AND deleted_at IS NULL", claimID).Take(&claim).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrClaimNotFound
+		} else if err != nil {
+			return err
+		}
+		if claim.Status != models.ClaimPending {
+			return ErrClaimNotPending
+		}
+		decision.EmployeeID = claim.EmployeeID
+		decision.RequiredApprovals = claim.RequiredApprovals
+
+		var approvals []models.ClaimApproval
+		if err := tx.Where("claim_type = ? AND claim_id = ?", claimType, claimID).Find(&approvals).Error; err != nil {
+			return err
+		}
+		for _, a := range approvals {
+			if a.AdminID == adminID {
+				return ErrClaimAlreadyDecidedByAdmin
+			}
+		}
+		level = len(approvals) + 1
+
+		status := models.ClaimRejected
+		if approve {
+			status = models.ClaimApproved
+		}
+		record := models.ClaimApproval{
+			ClaimType: claimType,
+			ClaimID:   claimID,
+			AdminID:   adminID,
+			Level:     level,
+			Decision:  status,
+			Reason:    reason,
+			RequestIP: requestIP,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+
+		decision.Approvals = len(approvals)
+		if approve {
+			decision.Approvals++
+			if decision.Approvals < claim.RequiredApprovals {
+				decision.Status = models.ClaimPending
+				return nil
+			}
+		}
+		decision.Status = status
+
+		// The status condition guards against a concurrent decision.
+		result := tx.Table(table).Where("id = ? AND status = ?", claimID, models.ClaimPending).
+			Updates(map[string]interface{}{"status": status, "decision_reason": reason, "updated_by_id": adminID, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrClaimNotPending
+		}
+		return nil
+	})
+	if err != nil {
+		return decision, err
+	}
+
+	action := "REJECTED_" + strings.ToUpper(claimType)
+	if approve {
+		action = "APPROVED_" + strings.ToUpper(claimType)
+	}
+	details := fmt.Sprintf("Decided %s claim ID %d of employee ID %d: level %d of %d, now %s.", claimType, claimID, decision.EmployeeID, level, decision.RequiredApprovals, decision.Status)
+	if reason != "" {
+		details += " Reason: " + reason
+	}
+	CreateAuditLog(adminID, UserTypeAdmin, action, details, requestIP)
+	return decision, nil
+}
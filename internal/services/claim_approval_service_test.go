@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"testing"
+	"time"
+)
+
+func TestMultiLevelClaimApproval(t *testing.T) {
+	cleanDB()
+	rule := models.ApprovalRule{ClaimType: models.ClaimTypeReimbursement, MinAmount: money.FromUnits(1000000), Currency: "IDR", Levels: 2}
+	if err := ValidateApprovalRule(rule); err != nil {
+		t.Fatalf("Expected a valid rule, but got %v", err)
+	}
+	testDB.Create(&rule)
+	if err := ValidateApprovalRule(models.ApprovalRule{ClaimType: models.ClaimTypeOvertime, MinAmount: 1, Levels: 2}); !errors.Is(err, ErrInvalidApprovalRule) {
+		t.Errorf("Expected an overtime rule with an amount to be rejected, but got %v", err)
+	}
+
+	if required, _ := RequiredApprovalsForReimbursement(money.FromUnits(500000), "IDR"); required != 1 {
+		t.Errorf("Expected 1 approval below the threshold, but got %d", required)
+	}
+	if required, _ := RequiredApprovalsForReimbursement(money.FromUnits(1000000), "USD"); required != 1 {
+		t.Errorf("Expected rules in another currency not to apply, but got %d", required)
+	}
+	required, _ := RequiredApprovalsForReimbursement(money.FromUnits(1000000), "IDR")
+	if required != 2 {
+		t.Fatalf("Expected 2 approvals at the threshold, but got %d", required)
+	}
+
+	employee := models.Employee{Username: "claimer", Salary: money.FromUnits(10000000)}
+	testDB.Create(&employee)
+	claim := models.Reimbursement{EmployeeID: employee.ID, Amount: money.FromUnits(1000000), Currency: "IDR", Description: "Hotel", Status: models.ClaimPending, RequiredApprovals: required}
+	testDB.Create(&claim)
+
+	decision, err := DecideClaim(models.ClaimTypeReimbursement, claim.ID, 1, true, "", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected the first approval to be recorded, but got %v", err)
+	}
+	if decision.Status != models.ClaimPending || decision.Approvals != 1 {
+		t.Errorf("Expected the claim to wait for a second approval, but got %+v", decision)
+	}
+	if _, err := DecideClaim(models.ClaimTypeReimbursement, claim.ID, 1, true, "", "127.0.0.1"); !errors.Is(err, ErrClaimAlreadyDecidedByAdmin) {
+		t.Errorf("Expected the same admin not to approve twice, but got %v", err)
+	}
+
+	decision, err = DecideClaim(models.ClaimTypeReimbursement, claim.ID, 2, true, "", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected the second approval to be recorded, but got %v", err)
+	}
+	if decision.Status != models.ClaimApproved {
+		t.Errorf("Expected the claim to be approved, but got %s", decision.Status)
+	}
+	if _, err := DecideClaim(models.ClaimTypeReimbursement, claim.ID, 3, false, "Late", "127.0.0.1"); !errors.Is(err, ErrClaimNotPending) {
+		t.Errorf("Expected a decided claim to stay decided, but got %v", err)
+	}
+	if _, err := DecideClaim(models.ClaimTypeOvertime, 9999, 1, true, "", "127.0.0.1"); !errors.Is(err, ErrClaimNotFound) {
+		t.Errorf("Expected an unknown claim to be reported, but got %v", err)
+	}
+}
+
+func TestPayrollIncludesOnlyApprovedClaims(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	employee := models.Employee{Username: "claimer", Salary: money.FromUnits(10000000)}
+	testDB.Create(&employee)
+
+	date := time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)
+	pending := models.Overtime{EmployeeID: employee.ID, Hours: 1, Date: date, Status: models.ClaimPending, RequiredApprovals: 1}
+	rejected := models.Overtime{EmployeeID: employee.ID, Hours: 2, Date: date.AddDate(0, 0, 1), Status: models.ClaimPending, RequiredApprovals: 1}
+	approved := models.Reimbursement{EmployeeID: employee.ID, Amount: money.FromUnits(20000), Description: "Taxi", Status: models.ClaimPending, RequiredApprovals: 1, BaseModel: models.BaseModel{CreatedAt: date}}
+	testDB.Create(&pending)
+	testDB.Create(&rejected)
+	testDB.Create(&approved)
+
+	if _, err := DecideClaim(models.ClaimTypeOvertime, rejected.ID, 1, false, "Not agreed", "127.0.0.1"); err != nil {
+		t.Fatalf("Expected the rejection to be recorded, but got %v", err)
+	}
+	if _, err := DecideClaim(models.ClaimTypeReimbursement, approved.ID, 1, true, "", "127.0.0.1"); err != nil {
+		t.Fatalf("Expected the approval to be recorded, but got %v", err)
+	}
+
+	in, err := loadPayslipInputs(employee, period)
+	if err != nil {
+		t.Fatalf("Expected the inputs to load, but got %v", err)
+	}
+	if len(in.Overtimes) != 0 {
+		t.Errorf("Expected pending and rejected overtime to be left out, but got %d claims", len(in.Overtimes))
+	}
+	if len(in.Reimbursements) != 1 || in.Reimbursements[0].ID != approved.ID {
+		t.Errorf("Expected only the approved reimbursement, but got %+v", in.Reimbursements)
+	}
+}
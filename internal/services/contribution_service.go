@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"time"
+)
+
+// ErrInvalidContributionRule is returned when a contribution rule is inconsistent.
+var ErrInvalidContributionRule = errors.New("invalid contribution rule")
+
+func init() {
+	registerPayComponent(payComponent{Code: "CONTRIBUTIONS", Stage: stagePreTax, Compute: contributionsComponent})
+}
+
+// ValidateContributionRule checks the code, base and rates of a contribution rule.
+func ValidateContributionRule(r models.ContributionRule) error {
+	if !payComponentCode.MatchString(r.Code) {
+		return fmt.Errorf("%w: code must be upper case letters, digits and underscores", ErrInvalidContributionRule)
+	}
+	for _, reserved := range builtInCodes {
+		if reserved == r.Code {
+			return fmt.Errorf("%w: code %s is reserved", ErrInvalidContributionRule, r.Code)
+		}
+	}
+	if r.Base != models.ContributionBaseSalary && r.Base != models.ContributionBaseTaxableEarnings {
+		return fmt.Errorf("%w: base must be %q or %q", ErrInvalidContributionRule, models.ContributionBaseSalary, models.ContributionBaseTaxableEarnings)
+	}
+	if err := ValidateCurrency(r.Currency); err != nil {
+		return err
+	}
+	if r.BaseCap < 0 {
+		return fmt.Errorf("%w: base cap cannot be negative", ErrInvalidContributionRule)
+	}
+	if r.EmployeeRate < 0 || r.EmployeeRate > 1 || r.EmployerRate < 0 || r.EmployerRate > 1 {
+		return fmt.Errorf("%w: rates must be between 0 and 1", ErrInvalidContributionRule)
+	}
+	return nil
+}
+
+// ContributionRulesForDate returns, for each contribution code, the latest
+// version effective on the given date. Codes whose version in effect has both
+// rates at zero have been retired and are left out.
+func ContributionRulesForDate(date time.Time) ([]models.ContributionRule, error) {
+	var versions []models.ContributionRule
+	if err := database.DB.Where("effective_from <= ?", date).Order("code, effective_from desc").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	var rules []models.ContributionRule
+	for i, r := range versions {
+		if i > 0 && versions[i-1].Code == r.Code {
+			continue // an older version of the same code
+		}
+		if r.EmployeeRate == 0 && r.EmployerRate == 0 {
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// convertContributionRules returns copies of the rules with each base cap in
+// another currency converted into currency, at the rate in effect on date.
+func convertContributionRules(rules []models.ContributionRule, currency string, date time.Time) ([]models.ContributionRule, error) {
+	converted := make([]models.ContributionRule, len(rules))
+	for i, r := range rules {
+		if r.Currency != currency {
+			rate, err := exchangeRateForDate(r.Currency, currency, date)
+			if err != nil {
+				return nil, fmt.Errorf("contribution %s: %w", r.Code, err)
+			}
+			r.BaseCap = r.BaseCap.MulRat(rate, money.Cent)
+			r.Currency = currency
+		}
+		converted[i] = r
+	}
+	return converted, nil
+}
+
+// contributionBase returns the amount a rule's rates apply to, after the cap.
+// The salary base is the basic salary earned this period, so it follows proration.
+func contributionBase(ctx *payslipContext, r models.ContributionRule) money.Amount {
+	var base money.Amount
+	switch r.Base {
+	case models.ContributionBaseSalary:
+		base = ctx.sum(models.LineItemEarning, CodeBasicSalary)
+	case models.ContributionBaseTaxableEarnings:
+		for _, item := range ctx.Items {
+			if item.Type == models.LineItemEarning && item.Taxable {
+				base += item.Amount
+			}
+		}
+	}
+	if r.BaseCap > 0 {
+		base = money.Min(base, r.BaseCap)
+	}
+	return base
+}
+
+// contributionsComponent takes the employee share of each contribution as a
+// deduction and records the employer share alongside it. The employer share
+// is not part of gross pay and does not affect take-home pay.
+func contributionsComponent(ctx *payslipContext) []models.PayslipLineItem {
+	var items []models.PayslipLineItem
+	for _, r := range ctx.ContributionRules {
+		base := contributionBase(ctx, r)
+		if r.EmployeeRate > 0 {
+			items = append(items, models.PayslipLineItem{
+				Code:        r.Code,
+				Description: r.Description + " (employee share)",
+				Type:        models.LineItemDeduction,
+				Quantity:    base.Float(),
+				Rate:        r.EmployeeRate,
+				Amount:      base.Mul(r.EmployeeRate, roundingRules.LineItems),
+				Taxable:     r.TaxDeductible,
+			})
+		}
+		if r.EmployerRate > 0 {
+			items = append(items, models.PayslipLineItem{
+				Code:        r.Code,
+				Description: r.Description + " (employer share)",
+				Type:        models.LineItemEmployerContribution,
+				Quantity:    base.Float(),
+				Rate:        r.EmployerRate,
+				Amount:      base.Mul(r.EmployerRate, roundingRules.LineItems),
+			})
+		}
+	}
+	return items
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"testing"
+	"time"
+)
+
+func TestContributionsSplitEmployeeAndEmployerShares(t *testing.T) {
+	in := payslipInputs{
+		Schedule: DefaultWorkSchedule,
+		Employee: models.Employee{Salary: money.FromUnits(20000000)},
+		Period: models.PayrollPeriod{
+			StartDate: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2025, 6, 6, 0, 0, 0, 0, time.UTC),
+		},
+		DaysAttended: 5,
+		ContributionRules: []models.ContributionRule{
+			{Code: "PENSION", Description: "Pension", Base: models.ContributionBaseSalary, BaseCap: money.FromUnits(10000000), EmployeeRate: 0.01, EmployerRate: 0.02, TaxDeductible: true},
+			{Code: "HEALTH", Description: "Health", Base: models.ContributionBaseTaxableEarnings, EmployerRate: 0.04},
+		},
+	}
+
+	payslip := computePayslip(in)
+
+	// Pension base is capped at 10M: 100k from the employee, 200k from the employer.
+	// Health has no employee share and is 4% of the full 20M: 800k from the employer.
+	if payslip.TotalDeductions != money.FromUnits(100000) {
+		t.Errorf("Expected deductions of 100000, but got %s", payslip.TotalDeductions)
+	}
+	if payslip.EmployerContributions != money.FromUnits(1000000) {
+		t.Errorf("Expected employer contributions of 1000000, but got %s", payslip.EmployerContributions)
+	}
+	if payslip.TakeHomePay != money.FromUnits(19900000) {
+		t.Errorf("Expected take-home pay of 19900000, but got %s", payslip.TakeHomePay)
+	}
+	if payslip.TaxableIncome != money.FromUnits(19900000) {
+		t.Errorf("Expected the pension share to reduce taxable income to 19900000, but got %s", payslip.TaxableIncome)
+	}
+
+	var employerItems int
+	for _, item := range payslip.LineItems {
+		if item.Type == models.LineItemEmployerContribution {
+			employerItems++
+		}
+	}
+	if employerItems != 2 {
+		t.Errorf("Expected 2 employer contribution line items, but got %d", employerItems)
+	}
+}
+
+func TestValidateContributionRule(t *testing.T) {
+	rule := models.ContributionRule{Code: "PENSION", Base: models.ContributionBaseSalary, Currency: "IDR", EmployeeRate: 0.01, EmployerRate: 0.02}
+	if err := ValidateContributionRule(rule); err != nil {
+		t.Fatalf("Expected rule to be valid, but got %v", err)
+	}
+
+	rule.EmployerRate = 2
+	if err := ValidateContributionRule(rule); !errors.Is(err, ErrInvalidContributionRule) {
+		t.Errorf("Expected a rate above 1 to be rejected, but got %v", err)
+	}
+}
+
+func TestContributionRulesForDateUsesVersionInEffect(t *testing.T) {
+	testDB.Exec("DELETE FROM contribution_rules")
+	defer testDB.Exec("DELETE FROM contribution_rules")
+
+	rules := []models.ContributionRule{
+		{Code: "PENSION", EffectiveFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Base: models.ContributionBaseSalary, EmployeeRate: 0.01},
+		{Code: "PENSION", EffectiveFrom: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), Base: models.ContributionBaseSalary, EmployeeRate: 0.02},
+		{Code: "HEALTH", EffectiveFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Base: models.ContributionBaseSalary, EmployerRate: 0.04},
+		{Code: "HEALTH", EffectiveFrom: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), Base: models.ContributionBaseSalary}, // retired
+	}
+	for i := range rules {
+		testDB.Create(&rules[i])
+	}
+
+	june, err := ContributionRulesForDate(time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(june) != 2 {
+		t.Fatalf("Expected both contributions in June 2025, got %+v (err %v)", june, err)
+	}
+
+	july, _ := ContributionRulesForDate(time.Date(2025, 7, 31, 0, 0, 0, 0, time.UTC))
+	if len(july) != 1 || july[0].ID != rules[1].ID {
+		t.Errorf("Expected only the July 2025 pension version in July 2025, got %+v", july)
+	}
+}
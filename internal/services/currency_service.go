@@ -0,0 +1,152 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCurrency is returned for anything that isn't a three-letter ISO 4217 code.
+	ErrInvalidCurrency = errors.New("invalid currency code")
+	// ErrInvalidExchangeRate is returned when an exchange rate is inconsistent.
+	ErrInvalidExchangeRate = errors.New("invalid exchange rate")
+	// ErrNoExchangeRate is returned when no rate between two currencies is in effect.
+	ErrNoExchangeRate = errors.New("no exchange rate")
+)
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// exchangeRateColumns is the header expected by ImportExchangeRates.
+var exchangeRateColumns = []string{"from_currency", "to_currency", "rate", "effective_from"}
+
+// ValidateCurrency checks that a code looks like an ISO 4217 currency code, e.g. "IDR".
+func ValidateCurrency(code string) error {
+	if !currencyCode.MatchString(code) {
+		return fmt.Errorf("%w: %q", ErrInvalidCurrency, code)
+	}
+	return nil
+}
+
+// ValidateExchangeRate checks the currencies and the rate.
+func ValidateExchangeRate(r models.ExchangeRate) error {
+	if err := ValidateCurrency(r.FromCurrency); err != nil {
+		return err
+	}
+	if err := ValidateCurrency(r.ToCurrency); err != nil {
+		return err
+	}
+	if r.FromCurrency == r.ToCurrency {
+		return fmt.Errorf("%w: currencies must differ", ErrInvalidExchangeRate)
+	}
+	if r.Rate <= 0 {
+		return fmt.Errorf("%w: rate must be positive", ErrInvalidExchangeRate)
+	}
+	return nil
+}
+
+// ImportExchangeRates reads a CSV file with the columns from_currency,
+// to_currency, rate and effective_from (YYYY-MM-DD) and creates every rate in
+// one transaction, so a file with a bad line leaves nothing behind.
+func ImportExchangeRates(r io.Reader, adminID uint, requestIP string) ([]models.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(exchangeRateColumns)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not read header: %v", ErrInvalidExchangeRate, err)
+	}
+	for i, column := range exchangeRateColumns {
+		if strings.ToLower(strings.TrimSpace(header[i])) != column {
+			return nil, fmt.Errorf("%w: header must be %s", ErrInvalidExchangeRate, strings.Join(exchangeRateColumns, ","))
+		}
+	}
+
+	var rates []models.ExchangeRate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExchangeRate, err)
+		}
+
+		rate, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: rate %q is not a number", ErrInvalidExchangeRate, line, record[2])
+		}
+		effectiveFrom, err := time.Parse("2006-01-02", record[3])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: date %q is not YYYY-MM-DD", ErrInvalidExchangeRate, line, record[3])
+		}
+		exchangeRate := models.ExchangeRate{
+			FromCurrency:  strings.ToUpper(record[0]),
+			ToCurrency:    strings.ToUpper(record[1]),
+			Rate:          rate,
+			EffectiveFrom: effectiveFrom,
+			BaseModel: models.BaseModel{
+				CreatedByID: adminID,
+				UpdatedByID: adminID,
+				RequestIP:   requestIP,
+			},
+		}
+		if err := ValidateExchangeRate(exchangeRate); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, exchangeRate)
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: the file has no rates", ErrInvalidExchangeRate)
+	}
+
+	if err := database.DB.Create(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// exchangeRateForDate returns the factor that converts an amount in one
+// currency into another on the given date. A rate loaded for the opposite
+// direction is inverted when no direct rate is in effect.
+func exchangeRateForDate(from, to string, date time.Time) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	var rate models.ExchangeRate
+	err := database.DB.
+		Where("from_currency = ? AND to_currency = ? AND effective_from <= ?", from, to, date).
+		Order("effective_from desc").
+		First(&rate).Error
+	if err == nil {
+		return money.Decimal(rate.Rate), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	err = database.DB.
+		Where("from_currency = ? AND to_currency = ? AND effective_from <= ?", to, from, date).
+		Order("effective_from desc").
+		First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w from %s to %s on %s", ErrNoExchangeRate, from, to, date.Format("2006-01-02"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Rat).Inv(money.Decimal(rate.Rate)), nil
+}
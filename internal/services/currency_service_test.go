@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForeignReimbursementIsConvertedToPayCurrency(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	employee := models.Employee{Username: "traveller", Salary: money.FromUnits(10000000), Currency: "IDR"}
+	testDB.Create(&employee)
+
+	submitted := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	testDB.Create(&models.Reimbursement{EmployeeID: employee.ID, Status: models.ClaimApproved, Amount: money.MustParse("12.50"), Currency: "USD", Description: "Taxi", BaseModel: models.BaseModel{CreatedAt: submitted}})
+	testDB.Create(&models.Reimbursement{EmployeeID: employee.ID, Status: models.ClaimApproved, Amount: money.FromUnits(50000), Currency: "IDR", Description: "Parking", BaseModel: models.BaseModel{CreatedAt: submitted}})
+
+	if _, err := calculatePayslipForEmployee(employee, period, 1, 1, "127.0.0.1"); !errors.Is(err, ErrNoExchangeRate) {
+		t.Fatalf("Expected the run to fail without a USD rate, but got %v", err)
+	}
+
+	// Only the inverse pair is loaded, and a later rate does not apply yet.
+	testDB.Create(&models.ExchangeRate{FromCurrency: "IDR", ToCurrency: "USD", Rate: 0.0000625, EffectiveFrom: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)})
+	testDB.Create(&models.ExchangeRate{FromCurrency: "IDR", ToCurrency: "USD", Rate: 0.00005, EffectiveFrom: time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)})
+
+	calc, err := calculatePayslipForEmployee(employee, period, 1, 1, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	// 12.50 USD at 16,000 IDR per USD, plus the 50,000 IDR claim
+	if calc.Payslip.Reimbursement != money.FromUnits(250000) {
+		t.Errorf("Expected reimbursements of 250000 IDR, but got %s", calc.Payslip.Reimbursement)
+	}
+	if calc.Payslip.Currency != "IDR" {
+		t.Errorf("Expected the payslip in IDR, but got %q", calc.Payslip.Currency)
+	}
+}
+
+func TestTaxAndContributionThresholdsAreConvertedToPayCurrency(t *testing.T) {
+	cleanDB()
+	testDB.Exec("DELETE FROM tax_tables")
+	testDB.Exec("DELETE FROM contribution_rules")
+	defer testDB.Exec("DELETE FROM tax_tables")
+	defer testDB.Exec("DELETE FROM contribution_rules")
+
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	employee := models.Employee{Username: "expat", Salary: money.FromUnits(1000), Currency: "USD"}
+	testDB.Create(&employee)
+	table := sampleTaxTable(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	testDB.Create(&table)
+	testDB.Create(&models.ContributionRule{Code: "PENSION", Description: "Pension", EffectiveFrom: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Base: models.ContributionBaseSalary, Currency: "IDR", BaseCap: money.FromUnits(12000000), EmployeeRate: 0.01})
+
+	if _, err := loadPayslipInputs(employee, period); !errors.Is(err, ErrNoExchangeRate) {
+		t.Fatalf("Expected the IDR thresholds to need a USD rate, but got %v", err)
+	}
+
+	testDB.Create(&models.ExchangeRate{FromCurrency: "USD", ToCurrency: "IDR", Rate: 16000, EffectiveFrom: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)})
+	in, err := loadPayslipInputs(employee, period)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if in.TaxTable.Currency != "USD" || in.TaxTable.PersonalAllowance != money.FromUnits(3375) || in.TaxTable.Brackets[1].LowerBound != money.FromUnits(3750) {
+		t.Errorf("Expected the tax table in USD at 16,000 IDR per USD, but got %s with allowance %s and second bracket from %s",
+			in.TaxTable.Currency, in.TaxTable.PersonalAllowance, in.TaxTable.Brackets[1].LowerBound)
+	}
+	if len(in.ContributionRules) != 1 || in.ContributionRules[0].BaseCap != money.FromUnits(750) {
+		t.Fatalf("Expected the pension cap of 12M IDR to be 750 USD, but got %v", in.ContributionRules)
+	}
+
+	// 12,000 USD a year - 3,375 = 8,625: 3,750*5% + 4,875*15% = 918.75 a year,
+	// the same tax as 16M IDR a month under the IDR table.
+	if got := computeTaxWithholding(in.TaxTable, employee, money.FromUnits(1000)); got != money.MustParse("76.56") {
+		t.Errorf("Expected withholding of 76.56 USD, but got %s", got)
+	}
+}
+
+func TestImportExchangeRates(t *testing.T) {
+	testDB.Exec("DELETE FROM exchange_rates")
+	defer testDB.Exec("DELETE FROM exchange_rates")
+
+	valid := "from_currency,to_currency,rate,effective_from\nUSD,IDR,16250.5,2025-06-01\neur,idr,17500,2025-06-01\n"
+	rates, err := ImportExchangeRates(strings.NewReader(valid), 1, "127.0.0.1")
+	if err != nil || len(rates) != 2 {
+		t.Fatalf("Expected 2 rates to be imported, got %d (err %v)", len(rates), err)
+	}
+	if rates[1].FromCurrency != "EUR" {
+		t.Errorf("Expected currency codes to be upper-cased, got %q", rates[1].FromCurrency)
+	}
+
+	invalid := "from_currency,to_currency,rate,effective_from\nSGD,IDR,12000,2025-06-01\nSGD,IDR,-1,2025-07-01\n"
+	if _, err := ImportExchangeRates(strings.NewReader(invalid), 1, "127.0.0.1"); !errors.Is(err, ErrInvalidExchangeRate) {
+		t.Fatalf("Expected a negative rate to be rejected, but got %v", err)
+	}
+	var count int64
+	testDB.Model(&models.ExchangeRate{}).Where("from_currency = ?", "SGD").Count(&count)
+	if count != 0 {
+		t.Errorf("Expected nothing from the rejected file to be saved, but found %d rates", count)
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Holiday import formats.
+const (
+	HolidayFormatICS = "ics"
+	HolidayFormatCSV = "csv"
+)
+
+// ErrInvalidHolidayFile is returned when an imported calendar file can't be read.
+var ErrInvalidHolidayFile = errors.New("invalid holiday file")
+
+// holidaySet maps the dates ("2006-01-02") of the holidays that apply to an employee to their names.
+type holidaySet map[string]string
+
+// on reports whether the day of t is a holiday.
+func (h holidaySet) on(t time.Time) bool {
+	_, ok := h[t.Format("2006-01-02")]
+	return ok
+}
+
+// HolidayCalendarForEmployee returns the calendar that applies to the employee:
+// the one assigned directly, otherwise the one for the employee's location.
+// It returns nil when neither exists.
+func HolidayCalendarForEmployee(emp models.Employee) (*models.HolidayCalendar, error) {
+	var calendar models.HolidayCalendar
+	var err error
+	switch {
+	case emp.HolidayCalendarID != nil:
+		err = database.DB.First(&calendar, *emp.HolidayCalendarID).Error
+	case emp.Location != "":
+		err = database.DB.Where("location = ?", emp.Location).Order("id").First(&calendar).Error
+	default:
+		return nil, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &calendar, nil
+}
+
+// holidaysBetween returns the calendar's holidays between start and end, both inclusive.
+func holidaysBetween(calendar *models.HolidayCalendar, start, end time.Time) (holidaySet, error) {
+	holidays := holidaySet{}
+	if calendar == nil {
+		return holidays, nil
+	}
+	var rows []models.Holiday
+	if err := database.DB.Where("holiday_calendar_id = ? AND date BETWEEN ? AND ?", calendar.ID, start, end).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, h := range rows {
+		holidays[h.Date.Format("2006-01-02")] = h.Name
+	}
+	return holidays, nil
+}
+
+// HolidayOn returns the holiday on the given day in the employee's calendar,
+// along with the calendar, or a nil holiday if the day is not a holiday.
+func HolidayOn(emp models.Employee, day time.Time) (*models.HolidayCalendar, *models.Holiday, error) {
+	calendar, err := HolidayCalendarForEmployee(emp)
+	if err != nil || calendar == nil {
+		return nil, nil, err
+	}
+	date := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	var holiday models.Holiday
+	err = database.DB.Where("holiday_calendar_id = ? AND date = ?", calendar.ID, date).First(&holiday).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return calendar, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return calendar, &holiday, nil
+}
+
+// ImportHolidays reads holidays from an iCalendar (.ics) or CSV file into a
+// calendar. Dates already in the calendar are skipped, so a file can be
+// imported again after it has been extended. It returns the number of holidays added.
+func ImportHolidays(calendarID uint, r io.Reader, format string) (int, error) {
+	var holidays []models.Holiday
+	var err error
+	switch format {
+	case HolidayFormatICS:
+		holidays, err = parseICSHolidays(r)
+	case HolidayFormatCSV:
+		holidays, err = parseCSVHolidays(r)
+	default:
+		return 0, fmt.Errorf("%w: format must be %q or %q", ErrInvalidHolidayFile, HolidayFormatICS, HolidayFormatCSV)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(holidays) == 0 {
+		return 0, fmt.Errorf("%w: the file has no holidays", ErrInvalidHolidayFile)
+	}
+
+	for i := range holidays {
+		holidays[i].HolidayCalendarID = calendarID
+	}
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&holidays)
+	return int(result.RowsAffected), result.Error
+}
+
+// parseCSVHolidays reads a "date,name" file with a header line.
+func parseCSVHolidays(r io.Reader) ([]models.Holiday, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	if _, err := reader.Read(); err != nil {
+		return nil, fmt.Errorf("%w: could not read header: %v", ErrInvalidHolidayFile, err)
+	}
+	var holidays []models.Holiday
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return holidays, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidHolidayFile, err)
+		}
+		date, err := time.Parse("2006-01-02", record[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: date %q is not YYYY-MM-DD", ErrInvalidHolidayFile, line, record[0])
+		}
+		holidays = append(holidays, models.Holiday{Date: date, Name: record[1]})
+	}
+}
+
+// parseICSHolidays reads the VEVENTs of an iCalendar file. Every day from
+// DTSTART up to (not including) DTEND becomes a holiday named after SUMMARY.
+// Recurrence rules are not expanded; export the calendar with its occurrences instead.
+func parseICSHolidays(r io.Reader) ([]models.Holiday, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Lines starting with a space or tab continue the previous one (RFC 5545 folding).
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHolidayFile, err)
+	}
+
+	var holidays []models.Holiday
+	var inEvent bool
+	var summary string
+	var start, end time.Time
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Drop parameters such as ";VALUE=DATE" from the property name.
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent, summary, start, end = true, "", time.Time{}, time.Time{}
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if start.IsZero() {
+				return nil, fmt.Errorf("%w: event %q has no DTSTART", ErrInvalidHolidayFile, summary)
+			}
+			if end.IsZero() || !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+			for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+				holidays = append(holidays, models.Holiday{Date: day, Name: summary})
+			}
+		case inEvent && name == "SUMMARY":
+			summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ").Replace(value)
+		case inEvent && (name == "DTSTART" || name == "DTEND"):
+			if len(value) < 8 {
+				return nil, fmt.Errorf("%w: bad %s %q", ErrInvalidHolidayFile, name, value)
+			}
+			date, err := time.Parse("20060102", value[:8])
+			if err != nil {
+				return nil, fmt.Errorf("%w: bad %s %q", ErrInvalidHolidayFile, name, value)
+			}
+			if name == "DTSTART" {
+				start = date
+			} else {
+				end = date
+			}
+		}
+	}
+	return holidays, nil
+}
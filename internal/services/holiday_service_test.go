@@ -0,0 +1,86 @@
+package services
+
+import (
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20250601\r\n" +
+	"SUMMARY:Pancasila\r\n" +
+	"  Day\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20250609\r\n" +
+	"DTEND;VALUE=DATE:20250611\r\n" +
+	"SUMMARY:Eid al-Adha\\, observed\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICSHolidays(t *testing.T) {
+	holidays, err := parseICSHolidays(strings.NewReader(sampleICS))
+	if err != nil {
+		t.Fatalf("Expected the file to parse, but got %v", err)
+	}
+	// DTEND is exclusive, so the second event covers 9 and 10 June.
+	if len(holidays) != 3 {
+		t.Fatalf("Expected 3 holidays, but got %+v", holidays)
+	}
+	if holidays[0].Name != "Pancasila Day" {
+		t.Errorf("Expected a folded SUMMARY to be unfolded, got %q", holidays[0].Name)
+	}
+	if holidays[2].Name != "Eid al-Adha, observed" || !holidays[2].Date.Equal(time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the second day of Eid al-Adha, got %+v", holidays[2])
+	}
+}
+
+func TestHolidaysReduceWorkingDaysAndPayHolidayOvertime(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	calendar := models.HolidayCalendar{Name: "Jakarta", Location: "Jakarta", OvertimeMultiplier: 3}
+	testDB.Create(&calendar)
+	if _, err := ImportHolidays(calendar.ID, strings.NewReader(sampleICS), HolidayFormatICS); err != nil {
+		t.Fatalf("Expected the holidays to import, but got %v", err)
+	}
+	// Importing the same file again adds nothing.
+	if added, _ := ImportHolidays(calendar.ID, strings.NewReader(sampleICS), HolidayFormatICS); added != 0 {
+		t.Errorf("Expected a re-import to skip existing dates, but %d were added", added)
+	}
+
+	// 21 weekdays in June 2025, less the holidays on Monday 9 and Tuesday 10 June (1 June is a Sunday).
+	employee := models.Employee{Username: "jakarta", Salary: money.FromUnits(19000000), Location: "Jakarta"}
+	testDB.Create(&employee)
+	testDB.Create(&models.Attendance{EmployeeID: employee.ID, CheckIn: time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)})
+	testDB.Create(&models.Attendance{EmployeeID: employee.ID, CheckIn: time.Date(2025, 6, 9, 9, 0, 0, 0, time.UTC), OnHoliday: true})
+	testDB.Create(&models.Overtime{EmployeeID: employee.ID, Hours: 2, Status: models.ClaimApproved, Date: time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)})
+	testDB.Create(&models.Overtime{EmployeeID: employee.ID, Hours: 1, Status: models.ClaimApproved, Date: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)})
+
+	calc, err := calculatePayslipForEmployee(employee, period, 1, 1, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	payslip := calc.Payslip
+	if payslip.WorkingDays != 19 || payslip.DaysAttended != 1 {
+		t.Errorf("Expected 1 of 19 working days attended, got %d of %d", payslip.DaysAttended, payslip.WorkingDays)
+	}
+	// Hourly rate is 1M / 8 = 125k: 1 regular hour at 2x and 2 holiday hours at 3x
+	amounts := map[string]money.Amount{}
+	for _, item := range payslip.LineItems {
+		amounts[item.Code] = item.Amount
+	}
+	if amounts[CodeOvertime] != money.FromUnits(250000) || amounts[CodeHolidayOvertime] != money.FromUnits(750000) {
+		t.Errorf("Expected 250000 regular and 750000 holiday overtime, got %+v", amounts)
+	}
+	if payslip.OvertimePay != money.FromUnits(1000000) {
+		t.Errorf("Expected overtime pay of 1000000, but got %s", payslip.OvertimePay)
+	}
+}
@@ -0,0 +1,410 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidLeaveType is returned when a leave type is inconsistent.
+	ErrInvalidLeaveType = errors.New("invalid leave type")
+	// ErrInvalidLeaveRequest is returned when a leave request can't be accepted as submitted.
+	ErrInvalidLeaveRequest = errors.New("invalid leave request")
+	// ErrInsufficientLeaveBalance is returned when a request exceeds the available balance.
+	ErrInsufficientLeaveBalance = errors.New("insufficient leave balance")
+	// ErrLeaveOverlap is returned when a request overlaps a pending or approved one.
+	ErrLeaveOverlap = errors.New("leave overlaps an existing request")
+	// ErrLeaveRequestNotFound is returned for an unknown request, or one of another employee.
+	ErrLeaveRequestNotFound = errors.New("leave request not found")
+	// ErrLeaveRequestNotPending is returned when deciding or cancelling a request that was already decided.
+	ErrLeaveRequestNotPending = errors.New("leave request is not pending")
+)
+
+var leaveTypeCode = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// defaultLeaveTypes are created by EnsureDefaultLeaveTypes if they don't exist yet.
+var defaultLeaveTypes = []models.LeaveType{
+	{Code: "ANNUAL", Name: "Annual leave", Paid: true, TracksBalance: true, AccrualDaysPerMonth: 1, MaxBalance: 12},
+	{Code: "SICK", Name: "Sick leave", Paid: true},
+	{Code: "UNPAID", Name: "Unpaid leave"},
+}
+
+// EnsureDefaultLeaveTypes creates the built-in leave types. Existing types are
+// left untouched so that edits made by admins are kept.
+func EnsureDefaultLeaveTypes() error {
+	for _, def := range defaultLeaveTypes {
+		leaveType := def
+		if err := database.DB.Where("code = ?", def.Code).FirstOrCreate(&leaveType).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateLeaveType checks the code and accrual rule of a leave type.
+func ValidateLeaveType(t models.LeaveType) error {
+	if !leaveTypeCode.MatchString(t.Code) {
+		return fmt.Errorf("%w: code must be upper case letters, digits and underscores", ErrInvalidLeaveType)
+	}
+	if t.AccrualDaysPerMonth < 0 || t.MaxBalance < 0 {
+		return fmt.Errorf("%w: accrualDaysPerMonth and maxBalance cannot be negative", ErrInvalidLeaveType)
+	}
+	if !t.TracksBalance && (t.AccrualDaysPerMonth > 0 || t.MaxBalance > 0) {
+		return fmt.Errorf("%w: only a type that tracks a balance can accrue", ErrInvalidLeaveType)
+	}
+	return nil
+}
+
+// roundDays keeps sums of fractional accruals to two decimals.
+func roundDays(days float64) float64 {
+	return math.Round(days*100) / 100
+}
+
+// leaveBalance sums an employee's ledger for one leave type.
+func leaveBalance(tx *gorm.DB, employeeID, leaveTypeID uint) (float64, error) {
+	var balance float64
+	err := tx.Model(&models.LeaveLedgerEntry{}).
+		Where("employee_id = ? AND leave_type_id = ?", employeeID, leaveTypeID).
+		Select("COALESCE(SUM(days), 0)").Scan(&balance).Error
+	return roundDays(balance), err
+}
+
+// pendingLeaveDays sums the days of an employee's pending requests for one leave type.
+func pendingLeaveDays(tx *gorm.DB, employeeID, leaveTypeID uint) (float64, error) {
+	var pending float64
+	err := tx.Model(&models.LeaveRequest{}).
+		Where("employee_id = ? AND leave_type_id = ? AND status = ?", employeeID, leaveTypeID, models.LeaveRequestPending).
+		Select("COALESCE(SUM(days), 0)").Scan(&pending).Error
+	return pending, err
+}
+
+// LeaveBalance is an employee's standing for one leave type.
+type LeaveBalance struct {
+	LeaveType models.LeaveType `json:"leaveType"`
+	Balance   float64          `json:"balance"`   // Days accrued and adjusted, less days taken
+	Pending   float64          `json:"pending"`   // Days requested and awaiting approval
+	Available float64          `json:"available"` // Balance less pending days
+}
+
+// LeaveBalances returns the employee's balance of every leave type. Types that
+// don't track a balance are listed with zeros so employees can see what they may request.
+func LeaveBalances(employeeID uint) ([]LeaveBalance, error) {
+	var types []models.LeaveType
+	if err := database.DB.Order("code").Find(&types).Error; err != nil {
+		return nil, err
+	}
+	balances := make([]LeaveBalance, 0, len(types))
+	for _, t := range types {
+		b := LeaveBalance{LeaveType: t}
+		if t.TracksBalance {
+			var err error
+			if b.Balance, err = leaveBalance(database.DB, employeeID, t.ID); err != nil {
+				return nil, err
+			}
+			if b.Pending, err = pendingLeaveDays(database.DB, employeeID, t.ID); err != nil {
+				return nil, err
+			}
+			b.Available = roundDays(b.Balance - b.Pending)
+		}
+		balances = append(balances, b)
+	}
+	return balances, nil
+}
+
+// RequestLeave records a pending request after counting the working days it
+// covers in the employee's schedule and holiday calendar. Requests for a type
+// that tracks a balance may not exceed the balance less other pending requests.
+func RequestLeave(emp models.Employee, leaveTypeID uint, start, end time.Time, reason, requestIP string) (models.LeaveRequest, error) {
+	if end.Before(start) {
+		return models.LeaveRequest{}, fmt.Errorf("%w: endDate is before startDate", ErrInvalidLeaveRequest)
+	}
+	var leaveType models.LeaveType
+	if err := database.DB.First(&leaveType, leaveTypeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.LeaveRequest{}, fmt.Errorf("%w: unknown leave type", ErrInvalidLeaveRequest)
+	} else if err != nil {
+		return models.LeaveRequest{}, err
+	}
+
+	schedule, err := WorkScheduleForEmployee(emp)
+	if err != nil {
+		return models.LeaveRequest{}, err
+	}
+	calendar, err := HolidayCalendarForEmployee(emp)
+	if err != nil {
+		return models.LeaveRequest{}, err
+	}
+	holidays, err := holidaysBetween(calendar, start, end)
+	if err != nil {
+		return models.LeaveRequest{}, err
+	}
+	days := countWorkingDays(start, end, schedule, holidays)
+	if days == 0 {
+		return models.LeaveRequest{}, fmt.Errorf("%w: the dates include no working days", ErrInvalidLeaveRequest)
+	}
+
+	var overlapping int64
+	if err := database.DB.Model(&models.LeaveRequest{}).
+		Where("employee_id = ? AND status IN ? AND start_date <= ? AND end_date >= ?",
+			emp.ID, []string{models.LeaveRequestPending, models.LeaveRequestApproved}, end, start).
+		Count(&overlapping).Error; err != nil {
+		return models.LeaveRequest{}, err
+	}
+	if overlapping > 0 {
+		return models.LeaveRequest{}, ErrLeaveOverlap
+	}
+
+	if leaveType.TracksBalance {
+		balance, err := leaveBalance(database.DB, emp.ID, leaveType.ID)
+		if err != nil {
+			return models.LeaveRequest{}, err
+		}
+		pending, err := pendingLeaveDays(database.DB, emp.ID, leaveType.ID)
+		if err != nil {
+			return models.LeaveRequest{}, err
+		}
+		if available := roundDays(balance - pending); float64(days) > available {
+			return models.LeaveRequest{}, fmt.Errorf("%w: %d days requested, %v available", ErrInsufficientLeaveBalance, days, available)
+		}
+	}
+
+	request := models.LeaveRequest{
+		EmployeeID:  emp.ID,
+		LeaveTypeID: leaveType.ID,
+		StartDate:   start,
+		EndDate:     end,
+		Days:        float64(days),
+		Reason:      reason,
+		Status:      models.LeaveRequestPending,
+		BaseModel: models.BaseModel{
+			CreatedByID: emp.ID,
+			UpdatedByID: emp.ID,
+			RequestIP:   requestIP,
+		},
+	}
+	if err := database.DB.Create(&request).Error; err != nil {
+		return models.LeaveRequest{}, err
+	}
+	return request, nil
+}
+
+// CancelLeaveRequest withdraws one of the employee's own pending requests.
+func CancelLeaveRequest(employeeID, requestID uint) (models.LeaveRequest, error) {
+	var request models.LeaveRequest
+	err := database.DB.Where("employee_id = ?", employeeID).First(&request, requestID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return request, ErrLeaveRequestNotFound
+	} else if err != nil {
+		return request, err
+	}
+
+	result := database.DB.Model(&request).Where("status = ?", models.LeaveRequestPending).
+		Updates(map[string]interface{}{"status": models.LeaveRequestCancelled, "updated_by_id": employeeID})
+	if result.Error != nil {
+		return request, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return request, ErrLeaveRequestNotPending
+	}
+	request.Status = models.LeaveRequestCancelled
+	return request, nil
+}
+
+// DecideLeaveRequest approves or rejects a pending request. Approving a type
+// that tracks a balance checks the balance again and debits it in the same transaction.
+func DecideLeaveRequest(requestID, adminID uint, approve bool, reason, requestIP string) (models.LeaveRequest, error) {
+	var request models.LeaveRequest
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("LeaveType").First(&request, requestID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrLeaveRequestNotFound
+		} else if err != nil {
+			return err
+		}
+		if request.Status != models.LeaveRequestPending {
+			return ErrLeaveRequestNotPending
+		}
+
+		status := models.LeaveRequestRejected
+		if approve {
+			status = models.LeaveRequestApproved
+		}
+		now := time.Now()
+		// The status condition guards against a concurrent decision.
+		result := tx.Model(&request).Where("status = ?", models.LeaveRequestPending).Updates(map[string]interface{}{
+			"status":          status,
+			"decided_by_id":   adminID,
+			"decided_at":      now,
+			"decision_reason": reason,
+			"updated_by_id":   adminID,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrLeaveRequestNotPending
+		}
+		request.Status, request.DecidedByID, request.DecidedAt, request.DecisionReason = status, &adminID, &now, reason
+
+		if !approve || !request.LeaveType.TracksBalance {
+			return nil
+		}
+		// The balance is a sum over the ledger, so lock the employee instead: concurrent
+		// approvals of their requests then check the balance one after the other.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Employee{}, request.EmployeeID).Error; err != nil {
+			return err
+		}
+		balance, err := leaveBalance(tx, request.EmployeeID, request.LeaveTypeID)
+		if err != nil {
+			return err
+		}
+		if request.Days > balance {
+			return fmt.Errorf("%w: %v days requested, %v in balance", ErrInsufficientLeaveBalance, request.Days, balance)
+		}
+		return tx.Create(&models.LeaveLedgerEntry{
+			EmployeeID:     request.EmployeeID,
+			LeaveTypeID:    request.LeaveTypeID,
+			Kind:           models.LeaveEntryTaken,
+			Days:           -request.Days,
+			LeaveRequestID: &request.ID,
+			BaseModel:      models.BaseModel{CreatedByID: adminID, UpdatedByID: adminID, RequestIP: requestIP},
+		}).Error
+	})
+	if err != nil {
+		return request, err
+	}
+
+	action := "REJECTED_LEAVE"
+	if approve {
+		action = "APPROVED_LEAVE"
+	}
+	details := fmt.Sprintf("Leave request ID %d of employee ID %d for %v days %s. Reason: %s", request.ID, request.EmployeeID, request.Days, request.Status, reason)
+	CreateAuditLog(adminID, UserTypeAdmin, action, details, requestIP)
+	return request, nil
+}
+
+// AccrueLeave credits every employee with a month's accrual of each leave type
+// that accrues, without going above the type's MaxBalance. A month can only be
+// accrued once per employee and type; running it again adds nothing. It returns
+// the number of accruals recorded.
+func AccrueLeave(month time.Time, adminID uint, requestIP string) (int, error) {
+	monthKey := month.Format("2006-01")
+
+	var types []models.LeaveType
+	if err := database.DB.Where("tracks_balance = ? AND accrual_days_per_month > 0", true).Find(&types).Error; err != nil {
+		return 0, err
+	}
+	var employeeIDs []uint
+	if err := database.DB.Model(&models.Employee{}).Pluck("id", &employeeIDs).Error; err != nil {
+		return 0, err
+	}
+
+	accrued := 0
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, t := range types {
+			for _, employeeID := range employeeIDs {
+				days := t.AccrualDaysPerMonth
+				if t.MaxBalance > 0 {
+					balance, err := leaveBalance(tx, employeeID, t.ID)
+					if err != nil {
+						return err
+					}
+					days = math.Max(0, math.Min(days, roundDays(t.MaxBalance-balance)))
+				}
+				// An entry is recorded even when the cap leaves nothing to credit, so the month counts as accrued.
+				entry := models.LeaveLedgerEntry{
+					EmployeeID:   employeeID,
+					LeaveTypeID:  t.ID,
+					Kind:         models.LeaveEntryAccrual,
+					Days:         days,
+					AccrualMonth: &monthKey,
+					BaseModel:    models.BaseModel{CreatedByID: adminID, UpdatedByID: adminID, RequestIP: requestIP},
+				}
+				result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry)
+				if result.Error != nil {
+					return result.Error
+				}
+				accrued += int(result.RowsAffected)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	details := fmt.Sprintf("Accrued leave for %s: %d entries recorded.", monthKey, accrued)
+	CreateAuditLog(adminID, UserTypeAdmin, "ACCRUED_LEAVE", details, requestIP)
+	return accrued, nil
+}
+
+// approvedLeaveDays counts the working days in the period covered by the
+// employee's approved leave, split into paid and unpaid. Days the employee
+// attended anyway are skipped, so a day is never counted twice.
+func approvedLeaveDays(emp models.Employee, period models.PayrollPeriod, schedule models.WorkSchedule, holidays holidaySet, attended map[string]bool) (paid, unpaid int, err error) {
+	var requests []models.LeaveRequest
+	if err := database.DB.Preload("LeaveType").
+		Where("employee_id = ? AND status = ? AND start_date <= ? AND end_date >= ?", emp.ID, models.LeaveRequestApproved, period.EndDate, period.StartDate).
+		Find(&requests).Error; err != nil {
+		return 0, 0, err
+	}
+
+	counted := map[string]bool{}
+	for _, r := range requests {
+		for day := r.StartDate; !day.After(r.EndDate); day = day.AddDate(0, 0, 1) {
+			date := day.Format("2006-01-02")
+			if day.Before(period.StartDate) || day.After(period.EndDate) || counted[date] || attended[date] {
+				continue
+			}
+			if !IsScheduledWorkDay(schedule, day) || holidays.on(day) {
+				continue
+			}
+			counted[date] = true
+			if r.LeaveType != nil && r.LeaveType.Paid {
+				paid++
+			} else {
+				unpaid++
+			}
+		}
+	}
+	return paid, unpaid, nil
+}
+
+// AdjustLeaveBalance credits (positive days) or debits (negative days) an
+// employee's balance of a leave type, e.g. for carry-over or corrections.
+func AdjustLeaveBalance(employeeID, leaveTypeID uint, days float64, note string, adminID uint, requestIP string) (models.LeaveLedgerEntry, error) {
+	var leaveType models.LeaveType
+	if err := database.DB.First(&leaveType, leaveTypeID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.LeaveLedgerEntry{}, fmt.Errorf("%w: unknown leave type", ErrInvalidLeaveType)
+	} else if err != nil {
+		return models.LeaveLedgerEntry{}, err
+	}
+	if !leaveType.TracksBalance {
+		return models.LeaveLedgerEntry{}, fmt.Errorf("%w: %s does not track a balance", ErrInvalidLeaveType, leaveType.Code)
+	}
+	if days == 0 {
+		return models.LeaveLedgerEntry{}, fmt.Errorf("%w: days cannot be zero", ErrInvalidLeaveType)
+	}
+
+	entry := models.LeaveLedgerEntry{
+		EmployeeID:  employeeID,
+		LeaveTypeID: leaveType.ID,
+		Kind:        models.LeaveEntryAdjustment,
+		Days:        days,
+		Note:        note,
+		BaseModel:   models.BaseModel{CreatedByID: adminID, UpdatedByID: adminID, RequestIP: requestIP},
+	}
+	if err := database.DB.Create(&entry).Error; err != nil {
+		return models.LeaveLedgerEntry{}, err
+	}
+
+	details := fmt.Sprintf("Adjusted %s balance of employee ID %d by %v days: %s", leaveType.Code, employeeID, days, note)
+	CreateAuditLog(adminID, UserTypeAdmin, "ADJUSTED_LEAVE_BALANCE", details, requestIP)
+	return entry, nil
+}
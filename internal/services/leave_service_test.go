@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"testing"
+	"time"
+)
+
+func TestLeaveAccrualRequestAndApproval(t *testing.T) {
+	cleanDB()
+	if err := EnsureDefaultLeaveTypes(); err != nil {
+		t.Fatalf("Expected the default leave types, but got %v", err)
+	}
+	var annual models.LeaveType
+	testDB.Where("code = ?", "ANNUAL").First(&annual)
+	testDB.Model(&annual).Update("max_balance", 2.5)
+
+	employee := models.Employee{Username: "leave", Salary: money.FromUnits(10000000)}
+	testDB.Create(&employee)
+
+	// Three months of 1 day are capped at 2.5; accruing a month twice adds nothing.
+	for _, month := range []string{"2025-04", "2025-05", "2025-06", "2025-06"} {
+		m, _ := time.Parse("2006-01", month)
+		if _, err := AccrueLeave(m, 1, "127.0.0.1"); err != nil {
+			t.Fatalf("Expected %s to accrue, but got %v", month, err)
+		}
+	}
+	if balance, _ := leaveBalance(testDB, employee.ID, annual.ID); balance != 2.5 {
+		t.Fatalf("Expected a capped balance of 2.5 days, but got %v", balance)
+	}
+
+	// Monday to Wednesday is 3 working days, more than the balance.
+	monday := time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC)
+	_, err := RequestLeave(employee, annual.ID, monday, monday.AddDate(0, 0, 2), "Trip", "127.0.0.1")
+	if !errors.Is(err, ErrInsufficientLeaveBalance) {
+		t.Fatalf("Expected a request over the balance to fail, but got %v", err)
+	}
+
+	// Friday to Monday is 2 working days over a weekend.
+	friday := time.Date(2025, 6, 20, 0, 0, 0, 0, time.UTC)
+	request, err := RequestLeave(employee, annual.ID, friday, friday.AddDate(0, 0, 3), "Trip", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected the request to be accepted, but got %v", err)
+	}
+	if request.Days != 2 {
+		t.Errorf("Expected 2 working days, but got %v", request.Days)
+	}
+	if _, err := RequestLeave(employee, annual.ID, friday, friday, "Again", "127.0.0.1"); !errors.Is(err, ErrLeaveOverlap) {
+		t.Errorf("Expected an overlapping request to fail, but got %v", err)
+	}
+
+	if _, err := DecideLeaveRequest(request.ID, 1, true, "", "127.0.0.1"); err != nil {
+		t.Fatalf("Expected the request to be approved, but got %v", err)
+	}
+	if balance, _ := leaveBalance(testDB, employee.ID, annual.ID); balance != 0.5 {
+		t.Errorf("Expected 0.5 days left after approval, but got %v", balance)
+	}
+	if _, err := DecideLeaveRequest(request.ID, 1, false, "Too late", "127.0.0.1"); !errors.Is(err, ErrLeaveRequestNotPending) {
+		t.Errorf("Expected a decided request to stay decided, but got %v", err)
+	}
+}
+
+func TestPayrollCountsPaidLeaveAsAttended(t *testing.T) {
+	cleanDB()
+	EnsureDefaultLeaveTypes()
+	var annual, unpaid models.LeaveType
+	testDB.Where("code = ?", "ANNUAL").First(&annual)
+	testDB.Where("code = ?", "UNPAID").First(&unpaid)
+
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	employee := models.Employee{Username: "leave", Salary: money.FromUnits(21000000)}
+	testDB.Create(&employee)
+
+	// Attended Monday 2 June, on annual leave 2-4 June and unpaid leave from Friday 27 to Monday 30 June.
+	testDB.Create(&models.Attendance{EmployeeID: employee.ID, CheckIn: time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)})
+	testDB.Create(&models.LeaveRequest{EmployeeID: employee.ID, LeaveTypeID: annual.ID, Status: models.LeaveRequestApproved,
+		StartDate: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2025, 6, 4, 0, 0, 0, 0, time.UTC), Days: 3})
+	testDB.Create(&models.LeaveRequest{EmployeeID: employee.ID, LeaveTypeID: unpaid.ID, Status: models.LeaveRequestApproved,
+		StartDate: time.Date(2025, 6, 27, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC), Days: 2})
+	// A pending request is ignored.
+	testDB.Create(&models.LeaveRequest{EmployeeID: employee.ID, LeaveTypeID: annual.ID, Status: models.LeaveRequestPending,
+		StartDate: time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC), Days: 1})
+
+	calc, err := calculatePayslipForEmployee(employee, period, 1, 1, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	payslip := calc.Payslip
+	if payslip.PaidLeaveDays != 2 || payslip.UnpaidLeaveDays != 2 || payslip.DaysAttended != 3 {
+		t.Errorf("Expected 2 paid and 2 unpaid leave days and 3 days attended, got %d, %d and %d",
+			payslip.PaidLeaveDays, payslip.UnpaidLeaveDays, payslip.DaysAttended)
+	}
+	// 3 of 21 working days are paid.
+	if payslip.ProratedSalary != money.FromUnits(3000000) {
+		t.Errorf("Expected a prorated salary of 3000000, but got %s", payslip.ProratedSalary)
+	}
+}
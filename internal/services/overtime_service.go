@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidOvertimePolicy is returned when an overtime policy's limits or tiers are inconsistent.
+	ErrInvalidOvertimePolicy = errors.New("invalid overtime policy")
+	// ErrOvertimeNotAllowed is returned for overtime claimed for a future date, or for today too early.
+	ErrOvertimeNotAllowed = errors.New("overtime cannot be claimed")
+	// ErrOvertimeDailyLimit is returned when a claim would take a date over the daily limit.
+	ErrOvertimeDailyLimit = errors.New("overtime over the daily limit")
+)
+
+// DefaultOvertimePolicy applies until a policy is created: up to 3 hours a
+// day, claimed from 5 PM, at twice the hourly rate. Holidays use the holiday
+// calendar's multiplier.
+var DefaultOvertimePolicy = models.OvertimePolicy{
+	Name:               "Default",
+	DailyLimitHours:    3,
+	EarliestSubmission: "17:00",
+	Tiers:              []models.OvertimeTier{{DayType: models.OvertimeWorkday, Multiplier: 2}},
+}
+
+// OvertimePolicyForDate returns the policy in effect on the given date, or
+// DefaultOvertimePolicy if none has taken effect yet.
+func OvertimePolicyForDate(date time.Time) (models.OvertimePolicy, error) {
+	var policy models.OvertimePolicy
+	err := database.DB.
+		Preload("Tiers", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("effective_from <= ?", date).
+		Order("effective_from desc").
+		First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultOvertimePolicy, nil
+	}
+	return policy, err
+}
+
+// ValidateOvertimePolicy checks the limits and that the tiers of each day type
+// are in ascending order with only the last one open-ended. Workday tiers are
+// required; rest days fall back to them and holidays to the calendar's multiplier.
+func ValidateOvertimePolicy(p models.OvertimePolicy) error {
+	if p.DailyLimitHours <= 0 || p.DailyLimitHours > 24 {
+		return fmt.Errorf("%w: dailyLimitHours must be more than 0 and at most 24", ErrInvalidOvertimePolicy)
+	}
+	if p.MonthlyCapHours < 0 {
+		return fmt.Errorf("%w: monthlyCapHours cannot be negative", ErrInvalidOvertimePolicy)
+	}
+	if p.EarliestSubmission != "" {
+		if _, err := time.Parse("15:04", p.EarliestSubmission); err != nil {
+			return fmt.Errorf("%w: earliestSubmission must be a time such as 17:00", ErrInvalidOvertimePolicy)
+		}
+	}
+
+	byType := map[string][]models.OvertimeTier{}
+	for _, t := range p.Tiers {
+		if t.DayType != models.OvertimeWorkday && t.DayType != models.OvertimeRestDay && t.DayType != models.OvertimeHoliday {
+			return fmt.Errorf("%w: dayType must be %q, %q or %q", ErrInvalidOvertimePolicy, models.OvertimeWorkday, models.OvertimeRestDay, models.OvertimeHoliday)
+		}
+		if t.Multiplier < 1 {
+			return fmt.Errorf("%w: multipliers must be at least 1", ErrInvalidOvertimePolicy)
+		}
+		if t.UpToHours < 0 {
+			return fmt.Errorf("%w: upToHours cannot be negative", ErrInvalidOvertimePolicy)
+		}
+		byType[t.DayType] = append(byType[t.DayType], t)
+	}
+	if len(byType[models.OvertimeWorkday]) == 0 {
+		return fmt.Errorf("%w: at least one %s tier is required", ErrInvalidOvertimePolicy, models.OvertimeWorkday)
+	}
+	for dayType, tiers := range byType {
+		tiers = sortedTiers(tiers)
+		for i, t := range tiers {
+			last := i == len(tiers)-1
+			if t.UpToHours == 0 && !last {
+				return fmt.Errorf("%w: only the last %s tier may be open-ended", ErrInvalidOvertimePolicy, dayType)
+			}
+			if t.UpToHours != 0 && last {
+				return fmt.Errorf("%w: the last %s tier must be open-ended", ErrInvalidOvertimePolicy, dayType)
+			}
+			if i > 0 && t.UpToHours != 0 && t.UpToHours == tiers[i-1].UpToHours {
+				return fmt.Errorf("%w: %s tiers cannot end at the same hour", ErrInvalidOvertimePolicy, dayType)
+			}
+		}
+	}
+	return nil
+}
+
+// sortedTiers orders tiers by the hour they end at, the open-ended tier last.
+func sortedTiers(tiers []models.OvertimeTier) []models.OvertimeTier {
+	sorted := append([]models.OvertimeTier(nil), tiers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].UpToHours, sorted[j].UpToHours
+		return a != 0 && (b == 0 || a < b)
+	})
+	return sorted
+}
+
+// overtimeTiers returns the tiers that pay overtime on a type of day: the
+// policy's own, the workday tiers for rest days without any, and the holiday
+// calendar's multiplier for holidays without any.
+func overtimeTiers(p models.OvertimePolicy, dayType string, calendar *models.HolidayCalendar) []models.OvertimeTier {
+	var tiers []models.OvertimeTier
+	for _, t := range p.Tiers {
+		if t.DayType == dayType {
+			tiers = append(tiers, t)
+		}
+	}
+	switch {
+	case len(tiers) > 0:
+		return sortedTiers(tiers)
+	case dayType == models.OvertimeRestDay:
+		return overtimeTiers(p, models.OvertimeWorkday, calendar)
+	case dayType == models.OvertimeHoliday && calendar != nil:
+		return []models.OvertimeTier{{DayType: dayType, Multiplier: calendar.OvertimeMultiplier}}
+	}
+	return nil
+}
+
+// SubmitOvertime records a pending overtime claim once it passes the policy in
+// effect on its date: no future dates, no claims for today before the
+// policy's earliest submission time, and no more than the daily limit for the
+// date across all pending and approved claims.
+func SubmitOvertime(employeeID uint, date time.Time, hours float64, now time.Time, requestIP string) (models.Overtime, error) {
+	overtime := models.Overtime{
+		EmployeeID: employeeID,
+		Hours:      hours,
+		Date:       date,
+		Status:     models.ClaimPending,
+		BaseModel: models.BaseModel{
+			CreatedByID: employeeID,
+			UpdatedByID: employeeID,
+			RequestIP:   requestIP,
+		},
+	}
+
+	policy, err := OvertimePolicyForDate(date)
+	if err != nil {
+		return overtime, err
+	}
+	today := now.Format("2006-01-02")
+	switch day := date.Format("2006-01-02"); {
+	case day > today:
+		return overtime, fmt.Errorf("%w: %s is in the future", ErrOvertimeNotAllowed, day)
+	case day == today && policy.EarliestSubmission != "" && now.Format("15:04") < policy.EarliestSubmission:
+		return overtime, fmt.Errorf("%w: overtime for today can only be claimed from %s", ErrOvertimeNotAllowed, policy.EarliestSubmission)
+	}
+
+	if overtime.RequiredApprovals, err = RequiredApprovalsForOvertime(hours); err != nil {
+		return overtime, err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the employee so concurrent claims for the same date are counted one after the other.
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Employee{}, employeeID).Error; err != nil {
+			return err
+		}
+		var claimed float64
+		if err := tx.Model(&models.Overtime{}).
+			Where("employee_id = ? AND date = ? AND status IN ?", employeeID, date, []string{models.ClaimPending, models.ClaimApproved}).
+			Select("COALESCE(SUM(hours), 0)").Scan(&claimed).Error; err != nil {
+			return err
+		}
+		if claimed+hours > policy.DailyLimitHours {
+			return fmt.Errorf("%w: %v of %v hours are already claimed for %s", ErrOvertimeDailyLimit, claimed, policy.DailyLimitHours, date.Format("2006-01-02"))
+		}
+		return tx.Create(&overtime).Error
+	})
+	return overtime, err
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"testing"
+	"time"
+)
+
+func TestValidateOvertimePolicy(t *testing.T) {
+	if err := ValidateOvertimePolicy(DefaultOvertimePolicy); err != nil {
+		t.Errorf("Expected the default policy to be valid, but got %v", err)
+	}
+	invalid := []models.OvertimePolicy{
+		{DailyLimitHours: 0, Tiers: []models.OvertimeTier{{DayType: models.OvertimeWorkday, Multiplier: 2}}},
+		{DailyLimitHours: 3, EarliestSubmission: "5 PM", Tiers: []models.OvertimeTier{{DayType: models.OvertimeWorkday, Multiplier: 2}}},
+		{DailyLimitHours: 3, Tiers: []models.OvertimeTier{{DayType: models.OvertimeRestDay, Multiplier: 2}}},
+		{DailyLimitHours: 3, Tiers: []models.OvertimeTier{{DayType: models.OvertimeWorkday, Multiplier: 0.5}}},
+		{DailyLimitHours: 3, Tiers: []models.OvertimeTier{{DayType: models.OvertimeWorkday, UpToHours: 1, Multiplier: 1.5}}},
+		{DailyLimitHours: 3, Tiers: []models.OvertimeTier{{DayType: models.OvertimeWorkday, Multiplier: 1.5}, {DayType: models.OvertimeWorkday, Multiplier: 2}}},
+	}
+	for i, p := range invalid {
+		if err := ValidateOvertimePolicy(p); !errors.Is(err, ErrInvalidOvertimePolicy) {
+			t.Errorf("Expected policy %d to be refused, but got %v", i, err)
+		}
+	}
+}
+
+func TestTieredOvertimeWithMonthlyCap(t *testing.T) {
+	policy := models.OvertimePolicy{
+		Name:            "Tiered",
+		DailyLimitHours: 4,
+		MonthlyCapHours: 6,
+		Tiers: []models.OvertimeTier{
+			{DayType: models.OvertimeWorkday, Multiplier: 2},
+			{DayType: models.OvertimeWorkday, UpToHours: 1, Multiplier: 1.5},
+			{DayType: models.OvertimeRestDay, Multiplier: 3},
+		},
+	}
+	in := payslipInputs{
+		Schedule:       DefaultWorkSchedule,
+		OvertimePolicy: policy,
+		Employee:       models.Employee{Salary: money.FromUnits(18000000)},
+		Period: models.PayrollPeriod{
+			StartDate: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2025, 6, 13, 0, 0, 0, 0, time.UTC),
+		},
+		DaysAttended: 9,
+		Holidays:     holidaySet{"2025-06-09": "Eid al-Adha"},
+		Overtimes: []models.Overtime{
+			{Date: time.Date(2025, 6, 12, 0, 0, 0, 0, time.UTC), Hours: 3}, // Over the cap
+			{Date: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), Hours: 3},  // 1 hour at 1.5x, 2 at 2x
+			{Date: time.Date(2025, 6, 7, 0, 0, 0, 0, time.UTC), Hours: 2},  // A Saturday, at 3x
+			{Date: time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC), Hours: 2},  // A holiday, 1 hour within the cap
+		},
+		HolidayCalendar: &models.HolidayCalendar{OvertimeMultiplier: 4},
+	}
+
+	payslip := computePayslip(in)
+
+	// 9 working days of 8 hours make an hourly rate of 18M / 72 = 250,000.
+	// 1.5 + 2*2 + 2*3 + 1*4 = 15.5 hourly rates.
+	if payslip.OvertimeHours != 6 {
+		t.Errorf("Expected 6 overtime hours within the cap, but got %v", payslip.OvertimeHours)
+	}
+	if payslip.OvertimePay != money.FromUnits(3875000) {
+		t.Errorf("Expected overtime pay of 3875000, but got %s", payslip.OvertimePay)
+	}
+
+	want := map[string]float64{
+		"Overtime at 1.5x":                  1,
+		"Overtime at 2x":                    2,
+		"Overtime on rest days at 3x":       2,
+		"Overtime on public holidays at 4x": 1,
+	}
+	got := map[string]float64{}
+	for _, item := range payslip.LineItems {
+		if item.Code == CodeOvertime || item.Code == CodeHolidayOvertime {
+			got[item.Description] = item.Quantity
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("Expected overtime line items %v, but got %v", want, got)
+	}
+	for description, hours := range want {
+		if got[description] != hours {
+			t.Errorf("Expected %v hours of %q, but got %v", hours, description, got[description])
+		}
+	}
+
+	var details struct {
+		Overtime struct {
+			CappedHours float64 `json:"cappedHours"`
+			Policy      string  `json:"policy"`
+		} `json:"overtime"`
+	}
+	if err := json.Unmarshal([]byte(payslip.PayslipDetails), &details); err != nil {
+		t.Fatalf("Expected valid details, but got %v", err)
+	}
+	if details.Overtime.CappedHours != 4 || details.Overtime.Policy != "Tiered" {
+		t.Errorf("Expected 4 capped hours under the Tiered policy, but got %+v", details.Overtime)
+	}
+}
+
+func TestSubmitOvertimeAppliesPolicyLimits(t *testing.T) {
+	cleanDB()
+	employee := models.Employee{Username: "nightowl", Salary: money.FromUnits(10000000)}
+	testDB.Create(&employee)
+	testDB.Create(&models.OvertimePolicy{
+		Name:               "Evenings",
+		EffectiveFrom:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		DailyLimitHours:    4,
+		EarliestSubmission: "18:00",
+		Tiers:              []models.OvertimeTier{{DayType: models.OvertimeWorkday, Multiplier: 2}},
+	})
+
+	today := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	afternoon := today.Add(17 * time.Hour)
+	evening := today.Add(19 * time.Hour)
+
+	if _, err := SubmitOvertime(employee.ID, today.AddDate(0, 0, 1), 1, evening, "127.0.0.1"); !errors.Is(err, ErrOvertimeNotAllowed) {
+		t.Errorf("Expected a claim for tomorrow to be refused, but got %v", err)
+	}
+	if _, err := SubmitOvertime(employee.ID, today, 1, afternoon, "127.0.0.1"); !errors.Is(err, ErrOvertimeNotAllowed) {
+		t.Errorf("Expected a claim before 18:00 to be refused, but got %v", err)
+	}
+	if _, err := SubmitOvertime(employee.ID, today.AddDate(0, 0, -1), 2, afternoon, "127.0.0.1"); err != nil {
+		t.Errorf("Expected a claim for yesterday to be accepted at any time, but got %v", err)
+	}
+
+	if _, err := SubmitOvertime(employee.ID, today, 3, evening, "127.0.0.1"); err != nil {
+		t.Fatalf("Expected the first claim to be accepted, but got %v", err)
+	}
+	if _, err := SubmitOvertime(employee.ID, today, 2, evening, "127.0.0.1"); !errors.Is(err, ErrOvertimeDailyLimit) {
+		t.Errorf("Expected a second claim over the 4 hour limit to be refused, but got %v", err)
+	}
+	second, err := SubmitOvertime(employee.ID, today, 1, evening, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected a second claim within the limit to be accepted, but got %v", err)
+	}
+	if second.Status != models.ClaimPending || second.RequiredApprovals != 1 {
+		t.Errorf("Expected a pending claim needing 1 approval, but got %+v", second)
+	}
+
+	// Rejected claims no longer count toward the limit.
+	testDB.Model(&second).Update("status", models.ClaimRejected)
+	if _, err := SubmitOvertime(employee.ID, today, 1, evening, "127.0.0.1"); err != nil {
+		t.Errorf("Expected the rejected hour to be claimable again, but got %v", err)
+	}
+}
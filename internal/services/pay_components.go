@@ -0,0 +1,235 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"sort"
+)
+
+// Stages order the pay components: earnings first, then deductions taken
+// before income tax, then income tax itself, then deductions taken after tax.
+const (
+	stageEarnings = 100
+	stagePreTax   = 200
+	stageTax      = 300
+	stagePostTax  = 400
+)
+
+// Line item codes produced by the built-in pay components.
+const (
+	CodeBasicSalary     = "BASIC_SALARY"
+	CodeOvertime        = "OVERTIME"
+	CodeHolidayOvertime = "OVERTIME_HOLIDAY"
+	CodeReimbursement   = "REIMBURSEMENT"
+	CodeIncomeTax       = "INCOME_TAX"
+)
+
+// builtInCodes cannot be used by recurring pay components or contribution rules.
+var builtInCodes = []string{CodeBasicSalary, CodeOvertime, CodeHolidayOvertime, CodeReimbursement, CodeIncomeTax}
+
+// payComponent produces the line items for one kind of pay on a payslip.
+type payComponent struct {
+	Code    string
+	Stage   int
+	Compute func(ctx *payslipContext) []models.PayslipLineItem
+}
+
+// payComponents is the registry the payroll calculation iterates over, in stage order.
+var payComponents []payComponent
+
+// registerPayComponent adds a component to the registry. Components of the same
+// stage run in registration order.
+func registerPayComponent(c payComponent) {
+	payComponents = append(payComponents, c)
+	sort.SliceStable(payComponents, func(i, j int) bool { return payComponents[i].Stage < payComponents[j].Stage })
+}
+
+func init() {
+	registerPayComponent(payComponent{Code: CodeBasicSalary, Stage: stageEarnings, Compute: basicSalaryComponent})
+	registerPayComponent(payComponent{Code: CodeOvertime, Stage: stageEarnings, Compute: overtimeComponent})
+	registerPayComponent(payComponent{Code: CodeReimbursement, Stage: stageEarnings, Compute: reimbursementComponent})
+	registerPayComponent(payComponent{Code: CodeIncomeTax, Stage: stageTax, Compute: incomeTaxComponent})
+}
+
+// payslipContext carries the inputs and the rates derived from them while the
+// components run, along with the line items produced so far. DailyRate and
+// HourlyRate are shown on line items; amounts are computed exactly from the salary.
+type payslipContext struct {
+	payslipInputs
+	WorkingDays             int
+	DailyRate               float64
+	HourlyRate              float64
+	OvertimeDays            []overtimeDay // Within the monthly cap
+	OvertimeHours           float64       // Paid, within the monthly cap
+	AttendanceOvertimeHours float64       // The part of OvertimeHours derived from attendance rather than claims
+	CappedOvertimeHours     float64       // Not paid, being over the monthly cap
+	Items                   []models.PayslipLineItem
+}
+
+// daysPay is the salary for a number of days, rounded once as a line item.
+func (ctx *payslipContext) daysPay(days int) money.Amount {
+	return ctx.Employee.Salary.MulFrac(int64(days), int64(ctx.WorkingDays), roundingRules.LineItems)
+}
+
+// hoursPay is the pay for a number of hours at a multiple of the hourly rate, rounded once as a line item.
+func (ctx *payslipContext) hoursPay(hours, multiplier float64) money.Amount {
+	pay := new(big.Rat).Mul(ctx.Employee.Salary.Rat(), money.Decimal(hours))
+	pay.Mul(pay, money.Decimal(multiplier))
+	scheduledHours := new(big.Rat).Mul(big.NewRat(int64(ctx.WorkingDays), 1), money.Decimal(ctx.Schedule.HoursPerDay))
+	pay.Quo(pay, scheduledHours)
+	return roundingRules.LineItems.Round(pay)
+}
+
+// sum adds up the amounts of all line items of a type, optionally only those with a code.
+func (ctx *payslipContext) sum(itemType, code string) money.Amount {
+	var total money.Amount
+	for _, item := range ctx.Items {
+		if item.Type == itemType && (code == "" || item.Code == code) {
+			total += item.Amount
+		}
+	}
+	return total
+}
+
+// taxableIncome is the taxable earnings less the deductions that reduce taxable income.
+func (ctx *payslipContext) taxableIncome() money.Amount {
+	var total money.Amount
+	for _, item := range ctx.Items {
+		if !item.Taxable {
+			continue
+		}
+		switch item.Type {
+		case models.LineItemEarning:
+			total += item.Amount
+		case models.LineItemDeduction:
+			total -= item.Amount
+		}
+	}
+	return total
+}
+
+// basicSalaryComponent pays the daily rate for each day attended.
+func basicSalaryComponent(ctx *payslipContext) []models.PayslipLineItem {
+	return []models.PayslipLineItem{{
+		Code:        CodeBasicSalary,
+		Description: "Basic salary (prorated by attendance)",
+		Type:        models.LineItemEarning,
+		Quantity:    float64(ctx.DaysAttended),
+		Rate:        ctx.DailyRate,
+		Amount:      ctx.daysPay(ctx.DaysAttended),
+		Taxable:     true,
+	}}
+}
+
+// overtimeDescriptions name the overtime line items by day type.
+var overtimeDescriptions = map[string]string{
+	models.OvertimeWorkday: "Overtime",
+	models.OvertimeRestDay: "Overtime on rest days",
+	models.OvertimeHoliday: "Overtime on public holidays",
+}
+
+// overtimeComponent pays each day's overtime through the policy's tiers for
+// its day type, so that e.g. the first hour is paid at 1.5x and the rest at 2x.
+// Hours are grouped into one line item per day type and multiplier; holiday
+// overtime has its own code.
+func overtimeComponent(ctx *payslipContext) []models.PayslipLineItem {
+	type rate struct {
+		dayType    string
+		multiplier float64
+	}
+	hours := map[rate]float64{}
+	for _, d := range ctx.OvertimeDays {
+		from := 0.0
+		for _, tier := range overtimeTiers(ctx.OvertimePolicy, d.DayType, ctx.HolidayCalendar) {
+			upTo := d.Hours
+			if tier.UpToHours != 0 && tier.UpToHours < upTo {
+				upTo = tier.UpToHours
+			}
+			if upTo > from {
+				hours[rate{d.DayType, tier.Multiplier}] += upTo - from
+			}
+			if from = upTo; from >= d.Hours {
+				break
+			}
+		}
+	}
+
+	var items []models.PayslipLineItem
+	for _, dayType := range []string{models.OvertimeWorkday, models.OvertimeRestDay, models.OvertimeHoliday} {
+		var multipliers []float64
+		for r := range hours {
+			if r.dayType == dayType {
+				multipliers = append(multipliers, r.multiplier)
+			}
+		}
+		sort.Float64s(multipliers)
+		for _, m := range multipliers {
+			h := hours[rate{dayType, m}]
+			code := CodeOvertime
+			if dayType == models.OvertimeHoliday {
+				code = CodeHolidayOvertime
+			}
+			items = append(items, models.PayslipLineItem{
+				Code:        code,
+				Description: fmt.Sprintf("%s at %gx", overtimeDescriptions[dayType], m),
+				Type:        models.LineItemEarning,
+				Quantity:    h,
+				Rate:        ctx.HourlyRate * m,
+				Amount:      ctx.hoursPay(h, m),
+				Taxable:     true,
+			})
+		}
+	}
+	return items
+}
+
+// reimbursementComponent pays back each expense claim as a non-taxable earning.
+// A claim in another currency shows its original amount as the quantity and
+// the exchange rate as the rate.
+func reimbursementComponent(ctx *payslipContext) []models.PayslipLineItem {
+	var items []models.PayslipLineItem
+	for _, r := range ctx.Reimbursements {
+		item := models.PayslipLineItem{
+			Code:        CodeReimbursement,
+			Description: r.Description,
+			Type:        models.LineItemEarning,
+			Quantity:    1,
+			Rate:        r.Amount.Float(),
+			Amount:      reimbursementPay(ctx, r),
+		}
+		if r.Category != nil {
+			item.Description = r.Category.Name + ": " + r.Description
+		}
+		if rate, ok := ctx.ReimbursementRates[r.ID]; ok {
+			item.Description = fmt.Sprintf("%s (%s %s)", item.Description, r.Currency, r.Amount)
+			item.Quantity = r.Amount.Float()
+			item.Rate, _ = rate.Float64()
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// reimbursementPay is the amount paid for a claim, in the employee's pay currency.
+func reimbursementPay(ctx *payslipContext, r models.Reimbursement) money.Amount {
+	if rate, ok := ctx.ReimbursementRates[r.ID]; ok {
+		return r.Amount.MulRat(rate, roundingRules.LineItems)
+	}
+	return r.Amount
+}
+
+// incomeTaxComponent withholds income tax on the taxable income accumulated so far.
+func incomeTaxComponent(ctx *payslipContext) []models.PayslipLineItem {
+	if ctx.TaxTable == nil {
+		return nil
+	}
+	return []models.PayslipLineItem{{
+		Code:        CodeIncomeTax,
+		Description: "Income tax withholding (" + ctx.TaxTable.Name + ")",
+		Type:        models.LineItemDeduction,
+		Quantity:    1,
+		Amount:      computeTaxWithholding(ctx.TaxTable, ctx.Employee, ctx.taxableIncome()),
+	}}
+}
@@ -1,149 +1,758 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"payslip-generator/internal/database"
+	"payslip-generator/internal/mail"
 	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"sort"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// Errors returned when a payroll run cannot be queued.
+var (
+	ErrPeriodNotFound     = errors.New("payroll period not found")
+	ErrPayrollAlreadyRun  = errors.New("payroll for this period has already been run")
+	ErrPayrollRunInFlight = errors.New("a payroll run for this period is already in progress")
+	ErrPayrollNotRun      = errors.New("payroll for this period has not been run")
+	ErrClaimsAlreadyPaid  = errors.New("overtime or reimbursements were paid by another payroll run")
+	ErrNegativePay        = errors.New("deductions exceed earnings")
 )
 
-// RunPayrollService orchestrates the entire payroll calculation process.
-func RunPayrollService(periodID, adminID uint, requestIP string) {
-	log.Printf("[Payroll Service] Starting payroll run for Period ID: %d by Admin ID: %d", periodID, adminID)
+// QueuePayrollRun validates the period and records a queued run for it.
+// The returned run is executed by RunPayrollService.
+func QueuePayrollRun(periodID, adminID uint, requestIP string) (models.PayrollRun, error) {
+	var period models.PayrollPeriod
+	if err := database.DB.First(&period, periodID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.PayrollRun{}, ErrPeriodNotFound
+	} else if err != nil {
+		return models.PayrollRun{}, err
+	}
+	if period.IsRun {
+		return models.PayrollRun{}, ErrPayrollAlreadyRun
+	}
+
+	var inFlight int64
+	database.DB.Model(&models.PayrollRun{}).
+		Where("payroll_period_id = ? AND status IN ?", periodID, []string{models.PayrollRunQueued, models.PayrollRunRunning}).
+		Count(&inFlight)
+	if inFlight > 0 {
+		return models.PayrollRun{}, ErrPayrollRunInFlight
+	}
+
+	run := models.PayrollRun{
+		PayrollPeriodID: periodID,
+		Status:          models.PayrollRunQueued,
+		BaseModel: models.BaseModel{
+			CreatedByID: adminID,
+			UpdatedByID: adminID,
+			RequestIP:   requestIP,
+		},
+	}
+	if err := database.DB.Create(&run).Error; err != nil {
+		return models.PayrollRun{}, err
+	}
+	return run, nil
+}
+
+// RunPayrollService orchestrates the entire payroll calculation process for a queued run.
+func RunPayrollService(runID uint) {
+	var run models.PayrollRun
+	if err := database.DB.First(&run, runID).Error; err != nil {
+		log.Printf("[Payroll Service] Error: Payroll Run %d not found.", runID)
+		return
+	}
+	periodID, adminID, requestIP := run.PayrollPeriodID, run.CreatedByID, run.RequestIP
+	log.Printf("[Payroll Service] Starting payroll run %d for Period ID: %d by Admin ID: %d", run.ID, periodID, adminID)
+
+	startedAt := time.Now()
+	run.Status = models.PayrollRunRunning
+	run.StartedAt = &startedAt
+	database.DB.Save(&run)
 
+	// Phase 1: compute every payslip without writing anything. A failure for any
+	// employee aborts the run before the period or any claim is touched.
 	var period models.PayrollPeriod
 	if err := database.DB.First(&period, periodID).Error; err != nil {
-		log.Printf("[Payroll Service] Error: Payroll Period %d not found.", periodID)
+		failPayrollRun(&run, fmt.Sprintf("Payroll Period %d not found.", periodID))
 		return
 	}
 
-	if period.IsRun {
-		log.Printf("[Payroll Service] Error: Payroll for Period %d has already been run.", periodID)
+	var employees []models.Employee
+	if err := database.DB.Find(&employees).Error; err != nil {
+		failPayrollRun(&run, "Could not load employees: "+err.Error())
 		return
 	}
+	run.TotalEmployees = len(employees)
+	database.DB.Model(&run).Update("total_employees", run.TotalEmployees)
 
-	database.DB.Model(&period).Update("is_run", true)
+	calculations := make([]payslipCalculation, 0, len(employees))
+	for _, emp := range employees {
+		calc, err := calculatePayslipForEmployee(emp, period, run.ID, adminID, requestIP)
+		switch {
+		case errors.Is(err, ErrNegativePay):
+			// Only this employee is left out; their claims stay unpaid for the next run.
+			log.Printf("[Payroll Service] Skipping Employee ID %d: %v", emp.ID, err)
+			run.SkippedCount++
+			database.DB.Create(&models.PayrollRunError{PayrollRunID: run.ID, EmployeeID: emp.ID, Message: err.Error(), Skipped: true})
+		case err != nil:
+			log.Printf("[Payroll Service] Error calculating payslip for Employee ID %d: %v", emp.ID, err)
+			run.FailedCount++
+			database.DB.Create(&models.PayrollRunError{PayrollRunID: run.ID, EmployeeID: emp.ID, Message: err.Error()})
+		default:
+			calculations = append(calculations, calc)
+		}
+		run.ProcessedCount++
+		database.DB.Model(&run).Updates(map[string]interface{}{"processed_count": run.ProcessedCount, "failed_count": run.FailedCount, "skipped_count": run.SkippedCount})
+	}
+	if run.FailedCount > 0 {
+		failPayrollRun(&run, fmt.Sprintf("%d payslip(s) could not be calculated; nothing was saved.", run.FailedCount))
+		return
+	}
+
+	// Phase 2: persist payslips, stamp the consumed claims and close the period
+	// in a single transaction, so a failure leaves no partial state behind.
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Claim the period; the condition guards against a concurrent run.
+		claim := tx.Model(&period).Where("is_run = ?", false).Update("is_run", true)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return ErrPayrollAlreadyRun
+		}
+
+		for _, calc := range calculations {
+			if err := savePayslipCalculation(tx, calc); err != nil {
+				return fmt.Errorf("saving payslip for employee ID %d: %w", calc.Payslip.EmployeeID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		failPayrollRun(&run, "Payroll run rolled back: "+err.Error())
+		return
+	}
+
+	// Email the payslips if email is configured, before the run is published as
+	// succeeded so pollers find the deliveries queued; the payslip mailer retries
+	// failed attempts.
+	if mail.Outgoing != nil {
+		if _, err := QueuePayslipDeliveries(periodID, adminID, requestIP); err != nil {
+			log.Printf("[Payroll Service] Error queueing payslip emails for Period ID %d: %v", periodID, err)
+		}
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = models.PayrollRunSucceeded
+
+	// Add an audit log entry before publishing the final status so pollers see both together
+	details := fmt.Sprintf("Successfully ran payroll for period ID %d (run ID %d): %d payslips generated.", periodID, run.ID, len(calculations))
+	if run.SkippedCount > 0 {
+		details += fmt.Sprintf(" %d employee(s) skipped because their deductions exceed their earnings.", run.SkippedCount)
+	}
+	CreateAuditLog(adminID, UserTypeAdmin, "RAN_PAYROLL", details, requestIP)
+
+	database.DB.Save(&run)
+	log.Printf("[Payroll Service] Finished payroll run %d for Period ID: %d", run.ID, periodID)
+}
+
+// failPayrollRun marks a run as failed. Nothing it computed has been persisted.
+func failPayrollRun(run *models.PayrollRun, reason string) {
+	log.Printf("[Payroll Service] Error: %s", reason)
+	finishedAt := time.Now()
+	run.Status = models.PayrollRunFailed
+	run.Error = reason
+	run.FinishedAt = &finishedAt
+	database.DB.Save(run)
+	CreateAuditLog(run.CreatedByID, UserTypeAdmin, "PAYROLL_RUN_FAILED",
+		fmt.Sprintf("Payroll run ID %d for period ID %d failed: %s", run.ID, run.PayrollPeriodID, reason), run.RequestIP)
+}
+
+// RecoverInterruptedPayrollRuns marks runs left queued or running by a previous
+// process as failed. Their transactions never committed, so the periods can be run again.
+func RecoverInterruptedPayrollRuns() {
+	now := time.Now()
+	result := database.DB.Model(&models.PayrollRun{}).
+		Where("status IN ?", []string{models.PayrollRunQueued, models.PayrollRunRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.PayrollRunFailed,
+			"error":       "Interrupted by a server restart; no changes were persisted.",
+			"finished_at": now,
+		})
+	if result.Error != nil {
+		log.Printf("[Payroll Service] Error recovering interrupted payroll runs: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("[Payroll Service] Marked %d interrupted payroll run(s) as failed.", result.RowsAffected)
+	}
+}
+
+// ReversePayroll undoes the payroll of a period so that it can be run again.
+// Payslips are voided rather than deleted, the claims they paid are released
+// and the runs that produced them are marked as reversed.
+func ReversePayroll(periodID, adminID uint, reason, requestIP string) error {
+	var period models.PayrollPeriod
+	if err := database.DB.First(&period, periodID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrPeriodNotFound
+	} else if err != nil {
+		return err
+	}
+
+	var voided int64
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Reopen the period; the condition guards against a concurrent reversal.
+		reopen := tx.Model(&period).Where("is_run = ?", true).Updates(map[string]interface{}{"is_run": false, "updated_by_id": adminID})
+		if reopen.Error != nil {
+			return reopen.Error
+		}
+		if reopen.RowsAffected == 0 {
+			return ErrPayrollNotRun
+		}
+
+		// Legacy runs stand in for payroll made before runs were tracked; the claims
+		// they hold stay paid, so a re-run doesn't pay them a second time.
+		var runIDs []uint
+		if err := tx.Model(&models.PayrollRun{}).
+			Where("payroll_period_id = ? AND status = ? AND legacy = ?", periodID, models.PayrollRunSucceeded, false).
+			Pluck("id", &runIDs).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.Payslip{}).
+			Where("payroll_period_id = ? AND voided_at IS NULL", periodID).
+			Updates(map[string]interface{}{"voided_at": time.Now(), "void_reason": reason, "updated_by_id": adminID})
+		if result.Error != nil {
+			return result.Error
+		}
+		voided = result.RowsAffected
+
+		if len(runIDs) == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.Overtime{}).Where("payroll_run_id IN ?", runIDs).Update("payroll_run_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Reimbursement{}).Where("payroll_run_id IN ?", runIDs).Update("payroll_run_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.PayrollRun{}).Where("id IN ?", runIDs).Update("status", models.PayrollRunReversed).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	details := fmt.Sprintf("Reversed payroll for period ID %d: %d payslips voided. Reason: %s", periodID, voided, reason)
+	CreateAuditLog(adminID, UserTypeAdmin, "REVERSED_PAYROLL", details, requestIP)
+	return nil
+}
+
+// PayrollPreview is the result of calculating a period's payroll without saving it.
+type PayrollPreview struct {
+	PayrollPeriodID uint                       `json:"payrollPeriodId"`
+	EmployeeCount   int                        `json:"employeeCount"`
+	Totals          map[string]*CurrencyTotals `json:"totalsByCurrency"`
+	Payslips        []models.Payslip           `json:"payslips"`
+	Errors          []models.PayrollRunError   `json:"errors,omitempty"`
+}
+
+// CurrencyTotals adds up the payslips paid in one currency. Amounts in
+// different currencies are never summed together.
+type CurrencyTotals struct {
+	EmployeeCount              int          `json:"employeeCount"`
+	TotalProratedSalary        money.Amount `json:"totalProratedSalary"`
+	TotalOvertimePay           money.Amount `json:"totalOvertimePay"`
+	TotalReimbursement         money.Amount `json:"totalReimbursement"`
+	TotalTaxWithheld           money.Amount `json:"totalTaxWithheld"`
+	TotalEmployerContributions money.Amount `json:"totalEmployerContributions"`
+	TotalPayout                money.Amount `json:"totalPayout"`
+}
+
+// TotalsByCurrency groups payslips by their pay currency and adds them up.
+func TotalsByCurrency(payslips []models.Payslip) map[string]*CurrencyTotals {
+	totals := map[string]*CurrencyTotals{}
+	for _, p := range payslips {
+		t, ok := totals[p.Currency]
+		if !ok {
+			t = &CurrencyTotals{}
+			totals[p.Currency] = t
+		}
+		t.EmployeeCount++
+		t.TotalProratedSalary += p.ProratedSalary
+		t.TotalOvertimePay += p.OvertimePay
+		t.TotalReimbursement += p.Reimbursement
+		t.TotalTaxWithheld += p.TaxWithheld
+		t.TotalEmployerContributions += p.EmployerContributions
+		t.TotalPayout += p.TakeHomePay
+	}
+	return totals
+}
+
+// PreviewPayroll computes every employee's payslip for an open period exactly
+// as a run would, without marking the period, creating payslips or stamping claims.
+func PreviewPayroll(periodID uint) (PayrollPreview, error) {
+	var period models.PayrollPeriod
+	if err := database.DB.First(&period, periodID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return PayrollPreview{}, ErrPeriodNotFound
+	} else if err != nil {
+		return PayrollPreview{}, err
+	}
+	if period.IsRun {
+		return PayrollPreview{}, ErrPayrollAlreadyRun
+	}
 
 	var employees []models.Employee
-	database.DB.Find(&employees)
+	if err := database.DB.Find(&employees).Error; err != nil {
+		return PayrollPreview{}, err
+	}
 
+	preview := PayrollPreview{PayrollPeriodID: period.ID, Payslips: []models.Payslip{}}
 	for _, emp := range employees {
-		payslip, err := calculatePayslipForEmployee(emp, period, adminID, requestIP)
+		in, err := loadPayslipInputs(emp, period)
 		if err != nil {
-			log.Printf("[Payroll Service] Error calculating payslip for Employee ID %d: %v", emp.ID, err)
+			preview.Errors = append(preview.Errors, models.PayrollRunError{EmployeeID: emp.ID, Message: err.Error()})
 			continue
 		}
-		if err := database.DB.Create(&payslip).Error; err != nil {
-			log.Printf("[Payroll Service] Error saving payslip for Employee ID %d: %v", emp.ID, err)
-		} else {
-			log.Printf("[Payroll Service] Successfully generated payslip for Employee ID %d.", emp.ID)
+		payslip := computePayslip(in)
+		if err := checkTakeHomePay(payslip); err != nil {
+			preview.Errors = append(preview.Errors, models.PayrollRunError{EmployeeID: emp.ID, Message: err.Error(), Skipped: true})
+			continue
 		}
+		preview.Payslips = append(preview.Payslips, payslip)
 	}
+	preview.EmployeeCount = len(preview.Payslips)
+	preview.Totals = TotalsByCurrency(preview.Payslips)
+	return preview, nil
+}
 
-	log.Printf("[Payroll Service] Finished payroll run for Period ID: %d", periodID)
+// payslipCalculation is a computed payslip together with the claims it consumes.
+type payslipCalculation struct {
+	Payslip          models.Payslip
+	OvertimeIDs      []uint
+	ReimbursementIDs []uint
+}
 
-	// Add an audit log entry
-	details := fmt.Sprintf("Successfully ran payroll for period ID %d.", periodID)
-	CreateAuditLog(adminID, "admin", "RAN_PAYROLL", details, requestIP)
+// payslipInputs holds everything the calculation needs for one employee and period.
+type payslipInputs struct {
+	Employee            models.Employee
+	Period              models.PayrollPeriod
+	DaysAttended        int
+	Overtimes           []models.Overtime
+	Reimbursements      []models.Reimbursement
+	TaxTable            *models.TaxTable // nil when no table is in effect for the period
+	RecurringComponents []models.RecurringPayComponent
+	ContributionRules   []models.ContributionRule
+	ReimbursementRates  map[uint]*big.Rat       // Factor into the pay currency, by ID of each claim in another currency
+	HolidayCalendar     *models.HolidayCalendar // nil when no calendar applies to the employee
+	Holidays            holidaySet              // The calendar's holidays within the period and on the dates of late overtime
+	Schedule            models.WorkSchedule
+	OvertimePolicy      models.OvertimePolicy
+	WorkedMinutes       map[string]int // Minutes worked by date ("2006-01-02") on checked-out attendance
+	PaidLeaveDays       int            // Approved paid leave, already included in DaysAttended
+	UnpaidLeaveDays     int
 }
 
 // calculatePayslipForEmployee contains the specific calculation logic for one employee.
-func calculatePayslipForEmployee(emp models.Employee, period models.PayrollPeriod, adminID uint, requestIP string) (models.Payslip, error) {
-	// 1. Calculate working days
+// It only reads from the database; savePayslipCalculation persists the result.
+func calculatePayslipForEmployee(emp models.Employee, period models.PayrollPeriod, runID, adminID uint, requestIP string) (payslipCalculation, error) {
+	in, err := loadPayslipInputs(emp, period)
+	if err != nil {
+		return payslipCalculation{}, err
+	}
+
+	payslip := computePayslip(in)
+	if err := checkTakeHomePay(payslip); err != nil {
+		return payslipCalculation{}, err
+	}
+	payslip.PayrollRunID = runID
+	payslip.BaseModel = models.BaseModel{
+		CreatedByID: adminID,
+		UpdatedByID: adminID,
+		RequestIP:   requestIP,
+	}
+
+	calc := payslipCalculation{Payslip: payslip}
+	for _, o := range in.Overtimes {
+		calc.OvertimeIDs = append(calc.OvertimeIDs, o.ID)
+	}
+	for _, r := range in.Reimbursements {
+		calc.ReimbursementIDs = append(calc.ReimbursementIDs, r.ID)
+	}
+	return calc, nil
+}
+
+// loadPayslipInputs reads the attendance and unprocessed claims for an employee.
+func loadPayslipInputs(emp models.Employee, period models.PayrollPeriod) (payslipInputs, error) {
+	in := payslipInputs{Employee: emp, Period: period}
+
+	// The period's end date is inclusive, so compare against the start of the following day.
+	periodEnd := period.EndDate.AddDate(0, 0, 1)
+
+	schedule, err := WorkScheduleForEmployee(emp)
+	if err != nil {
+		return in, err
+	}
+	in.Schedule = schedule
+
+	calendar, err := HolidayCalendarForEmployee(emp)
+	if err != nil {
+		return in, err
+	}
+	in.HolidayCalendar = calendar
+	if in.Holidays, err = holidaysBetween(calendar, period.StartDate, period.EndDate); err != nil {
+		return in, err
+	}
+
+	// Work on a holiday is paid as overtime, so it doesn't count towards the prorated days.
+	var attendances []models.Attendance
+	if err := database.DB.Select("check_in", "worked_minutes").
+		Where("employee_id = ? AND check_in >= ? AND check_in < ?", emp.ID, period.StartDate, periodEnd).
+		Find(&attendances).Error; err != nil {
+		return in, err
+	}
+	in.WorkedMinutes = map[string]int{}
+	attended := map[string]bool{}
+	for _, a := range attendances {
+		if !in.Holidays.on(a.CheckIn) {
+			in.DaysAttended++
+		}
+		attended[a.CheckIn.Format("2006-01-02")] = true
+		if a.WorkedMinutes > 0 {
+			in.WorkedMinutes[a.CheckIn.Format("2006-01-02")] += a.WorkedMinutes
+		}
+	}
+
+	// Approved paid leave counts as attended; unpaid leave is simply not paid.
+	if in.PaidLeaveDays, in.UnpaidLeaveDays, err = approvedLeaveDays(emp, period, schedule, in.Holidays, attended); err != nil {
+		return in, err
+	}
+	in.DaysAttended += in.PaidLeaveDays
+
+	if in.OvertimePolicy, err = OvertimePolicyForDate(period.EndDate); err != nil {
+		return in, err
+	}
+
+	// Only approved claims are paid. Overtime approved after its period was run is paid by the next run.
+	if err := database.DB.Where("employee_id = ? AND date <= ? AND status = ? AND payroll_run_id IS NULL", emp.ID, period.EndDate, models.ClaimApproved).
+		Find(&in.Overtimes).Error; err != nil {
+		return in, err
+	}
+	earliest := period.StartDate
+	for _, ot := range in.Overtimes {
+		if ot.Date.Before(earliest) {
+			earliest = ot.Date
+		}
+	}
+	if earliest.Before(period.StartDate) {
+		// Late overtime on an earlier holiday is still paid at the holiday rate.
+		earlier, err := holidaysBetween(calendar, earliest, period.StartDate.AddDate(0, 0, -1))
+		if err != nil {
+			return in, err
+		}
+		for date, name := range earlier {
+			in.Holidays[date] = name
+		}
+	}
+
+	// Reimbursements submitted by the end of the period are paid once approved; like
+	// overtime, those approved after their own period was run are paid by the next run.
+	if err := database.DB.Preload("Category").
+		Where("employee_id = ? AND created_at < ? AND status = ? AND payroll_run_id IS NULL", emp.ID, periodEnd, models.ClaimApproved).
+		Order("id").Find(&in.Reimbursements).Error; err != nil {
+		return in, err
+	}
+
+	// Foreign-currency claims are converted at the rate in effect on the day they were submitted.
+	for _, r := range in.Reimbursements {
+		if r.Currency == emp.Currency {
+			continue
+		}
+		submitted := time.Date(r.CreatedAt.Year(), r.CreatedAt.Month(), r.CreatedAt.Day(), 0, 0, 0, 0, time.UTC)
+		rate, err := exchangeRateForDate(r.Currency, emp.Currency, submitted)
+		if err != nil {
+			return in, fmt.Errorf("reimbursement ID %d: %w", r.ID, err)
+		}
+		if in.ReimbursementRates == nil {
+			in.ReimbursementRates = map[uint]*big.Rat{}
+		}
+		in.ReimbursementRates[r.ID] = rate
+	}
+
+	components, err := activeRecurringPayComponents(emp.ID, period)
+	if err != nil {
+		return in, err
+	}
+	in.RecurringComponents = components
+
+	// The tax table is chosen by the period's end date, so re-running a period uses the same version.
+	table, err := TaxTableForDate(period.EndDate)
+	if err != nil {
+		return in, err
+	}
+	// Its amounts are converted for employees paid in another currency at the
+	// rate in effect on the same date.
+	if table != nil && table.Currency != emp.Currency {
+		rate, err := exchangeRateForDate(table.Currency, emp.Currency, period.EndDate)
+		if err != nil {
+			return in, fmt.Errorf("tax table ID %d: %w", table.ID, err)
+		}
+		table = convertTaxTable(table, emp.Currency, rate)
+	}
+	in.TaxTable = table
+
+	// Contribution rules follow the same end-date versioning and conversion as the tax table.
+	rules, err := ContributionRulesForDate(period.EndDate)
+	if err != nil {
+		return in, err
+	}
+	in.ContributionRules, err = convertContributionRules(rules, emp.Currency, period.EndDate)
+	return in, err
+}
+
+// countWorkingDays counts the days between start and end, both inclusive,
+// that the schedule expects work on and that are not holidays.
+func countWorkingDays(start, end time.Time, schedule models.WorkSchedule, holidays holidaySet) int {
 	workingDays := 0
-	currentDay := period.StartDate
-	for !currentDay.After(period.EndDate) {
-		if currentDay.Weekday() != time.Saturday && currentDay.Weekday() != time.Sunday {
+	currentDay := start
+	for !currentDay.After(end) {
+		if IsScheduledWorkDay(schedule, currentDay) && !holidays.on(currentDay) {
 			workingDays++
 		}
 		currentDay = currentDay.AddDate(0, 0, 1)
 	}
-	if workingDays == 0 {
-		workingDays = 1 // Avoid division by zero
+	return workingDays
+}
+
+// overtimeDay is the overtime worked on one date, from claims or attendance.
+type overtimeDay struct {
+	Date           string // "2006-01-02"
+	DayType        string // models.OvertimeWorkday, OvertimeRestDay or OvertimeHoliday
+	Hours          float64
+	FromAttendance bool
+}
+
+// overtimeDays gathers the overtime to pay by date, in date order. Claims for
+// the same date are added up. With OvertimeFromAttendance, the time checked in
+// beyond the scheduled day, or all of it on a holiday, is overtime too, up to
+// the policy's daily limit as for claims, except on days with a claim so the
+// same hours are never paid twice.
+func overtimeDays(in payslipInputs) []overtimeDay {
+	hours := map[string]float64{}
+	fromAttendance := map[string]bool{}
+	for _, ot := range in.Overtimes {
+		hours[ot.Date.Format("2006-01-02")] += ot.Hours
+	}
+	if in.Schedule.OvertimeFromAttendance {
+		scheduledMinutes := int(in.Schedule.HoursPerDay * 60)
+		for date, worked := range in.WorkedMinutes {
+			if _, claimed := hours[date]; claimed {
+				continue
+			}
+			if _, ok := in.Holidays[date]; !ok {
+				worked -= scheduledMinutes
+			}
+			if worked > 0 {
+				hours[date] = float64(worked) / 60
+				if limit := in.OvertimePolicy.DailyLimitHours; limit > 0 && hours[date] > limit {
+					hours[date] = limit
+				}
+				fromAttendance[date] = true
+			}
+		}
 	}
 
-	// 2. Count attendance
-	var daysAttended int64
-	database.DB.Model(&models.Attendance{}).
-		Where("employee_id = ? AND check_in BETWEEN ? AND ?", emp.ID, period.StartDate, period.EndDate).
-		Count(&daysAttended)
+	days := make([]overtimeDay, 0, len(hours))
+	for date, h := range hours {
+		day := overtimeDay{Date: date, DayType: models.OvertimeWorkday, Hours: h, FromAttendance: fromAttendance[date]}
+		if _, ok := in.Holidays[date]; ok {
+			day.DayType = models.OvertimeHoliday
+		} else if t, _ := time.Parse("2006-01-02", date); !IsScheduledWorkDay(in.Schedule, t) {
+			day.DayType = models.OvertimeRestDay
+		}
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days
+}
 
-	// 3. Calculate Prorated Salary
-	dailyRate := emp.Salary / float64(workingDays)
-	proratedSalary := dailyRate * float64(daysAttended)
+// capOvertime drops the hours beyond the monthly cap, latest dates first, and
+// returns the hours dropped. A cap of zero means no cap.
+func capOvertime(days []overtimeDay, capHours float64) float64 {
+	if capHours <= 0 {
+		return 0
+	}
+	var paid, dropped float64
+	for i := range days {
+		if paid+days[i].Hours > capHours {
+			over := paid + days[i].Hours - capHours
+			dropped += over
+			days[i].Hours -= over
+		}
+		paid += days[i].Hours
+	}
+	return dropped
+}
+
+// reimbursementCategoryTotal is one line of the reimbursement breakdown in the payslip details.
+type reimbursementCategoryTotal struct {
+	Category string       `json:"category"` // The category code, empty for claims without a category
+	Name     string       `json:"name"`
+	Claims   int          `json:"claims"`
+	Total    money.Amount `json:"total"` // In the pay currency
+}
+
+// reimbursementsByCategory totals the claims paid by category code, with
+// claims without a category last.
+func reimbursementsByCategory(ctx *payslipContext) []reimbursementCategoryTotal {
+	totals := []reimbursementCategoryTotal{}
+	index := map[string]int{}
+	for _, r := range ctx.Reimbursements {
+		line := reimbursementCategoryTotal{Name: "Uncategorized"}
+		if r.Category != nil {
+			line = reimbursementCategoryTotal{Category: r.Category.Code, Name: r.Category.Name}
+		}
+		i, ok := index[line.Category]
+		if !ok {
+			i = len(totals)
+			index[line.Category] = i
+			totals = append(totals, line)
+		}
+		totals[i].Claims++
+		totals[i].Total += reimbursementPay(ctx, r)
+	}
+	sort.SliceStable(totals, func(i, j int) bool {
+		if (totals[i].Category == "") != (totals[j].Category == "") {
+			return totals[j].Category == ""
+		}
+		return totals[i].Category < totals[j].Category
+	})
+	return totals
+}
+
+// computePayslip runs every registered pay component over the inputs and
+// summarizes the resulting line items. It has no side effects.
+func computePayslip(in payslipInputs) models.Payslip {
+	emp := in.Employee
+
+	// 1. Calculate working days and rates
+	workingDays := countWorkingDays(in.Period.StartDate, in.Period.EndDate, in.Schedule, in.Holidays)
+	if workingDays == 0 {
+		workingDays = 1 // Avoid division by zero
+	}
+	dailyRate := emp.Salary.Float() / float64(workingDays)
 
-	// 4. Calculate Overtime
-	var overtimes []models.Overtime
-	database.DB.Where("employee_id = ? AND date BETWEEN ? AND ? AND payroll_run_id IS NULL", emp.ID, period.StartDate, period.EndDate).
-		Find(&overtimes)
-	totalOvertimeHours := 0.0
-	for _, ot := range overtimes {
-		totalOvertimeHours += ot.Hours
+	ctx := &payslipContext{
+		payslipInputs: in,
+		WorkingDays:   workingDays,
+		DailyRate:     dailyRate,
+		HourlyRate:    dailyRate / in.Schedule.HoursPerDay,
+	}
+	ctx.OvertimeDays = overtimeDays(in)
+	ctx.CappedOvertimeHours = capOvertime(ctx.OvertimeDays, in.OvertimePolicy.MonthlyCapHours)
+	for _, d := range ctx.OvertimeDays {
+		ctx.OvertimeHours += d.Hours
+		if d.FromAttendance {
+			ctx.AttendanceOvertimeHours += d.Hours
+		}
 	}
-	hourlyRate := dailyRate / 8
-	overtimePay := totalOvertimeHours * (hourlyRate * 2)
 
-	// 5. Calculate Reimbursements
-	var reimbursements []models.Reimbursement
-	database.DB.Where("employee_id = ? AND created_at BETWEEN ? AND ? AND payroll_run_id IS NULL", emp.ID, period.StartDate, period.EndDate).
-		Find(&reimbursements)
-	totalReimbursement := 0.0
-	for _, r := range reimbursements {
-		totalReimbursement += r.Amount
+	// 2. Produce the line items, component by component
+	for _, component := range payComponents {
+		ctx.Items = append(ctx.Items, component.Compute(ctx)...)
 	}
 
-	// 6. Calculate Take Home Pay
-	takeHomePay := proratedSalary + overtimePay + totalReimbursement
+	// 3. Summarize
+	proratedSalary := ctx.sum(models.LineItemEarning, CodeBasicSalary)
+	overtimePay := ctx.sum(models.LineItemEarning, CodeOvertime) + ctx.sum(models.LineItemEarning, CodeHolidayOvertime)
+	totalReimbursement := ctx.sum(models.LineItemEarning, CodeReimbursement)
+	taxWithheld := ctx.sum(models.LineItemDeduction, CodeIncomeTax)
+	taxableIncome := ctx.taxableIncome()
+	grossEarnings := ctx.sum(models.LineItemEarning, "")
+	totalDeductions := ctx.sum(models.LineItemDeduction, "")
+	takeHomePay := grossEarnings - totalDeductions
+	employerContributions := ctx.sum(models.LineItemEmployerContribution, "")
+
+	var taxTableID *uint
+	if in.TaxTable != nil {
+		taxTableID = &in.TaxTable.ID
+	}
 
-	// 7. Assemble Details
+	// 4. Assemble Details
+	byCategory, _ := json.Marshal(reimbursementsByCategory(ctx))
 	details := fmt.Sprintf(
-		`{"attendance":{"daysAttended":%d,"totalWorkingDays":%d},"salary":{"base":%.2f,"prorated":%.2f},"overtime":{"hours":%.2f,"pay":%.2f},"reimbursements":{"total":%.2f}}`,
-		daysAttended, workingDays, emp.Salary, proratedSalary, totalOvertimeHours, overtimePay, totalReimbursement,
+		`{"attendance":{"daysAttended":%d,"paidLeaveDays":%d,"unpaidLeaveDays":%d,"totalWorkingDays":%d,"workSchedule":%q,"hoursPerDay":%v},"salary":{"base":%s,"prorated":%s},"overtime":{"hours":%.2f,"fromAttendance":%.2f,"cappedHours":%.2f,"policy":%q,"pay":%s},"reimbursements":{"total":%s,"byCategory":%s},"tax":{"taxableIncome":%s,"withheld":%s,"maritalStatus":%q,"dependents":%d},"totals":{"grossEarnings":%s,"deductions":%s,"employerContributions":%s}}`,
+		in.DaysAttended, in.PaidLeaveDays, in.UnpaidLeaveDays, workingDays, in.Schedule.Name, in.Schedule.HoursPerDay, emp.Salary, proratedSalary, ctx.OvertimeHours, ctx.AttendanceOvertimeHours, ctx.CappedOvertimeHours, in.OvertimePolicy.Name, overtimePay, totalReimbursement, byCategory,
+		taxableIncome, taxWithheld, emp.TaxMaritalStatus, emp.TaxDependents, grossEarnings, totalDeductions, employerContributions,
 	)
 
-	payslip := models.Payslip{
-		EmployeeID:      emp.ID,
-		PayrollPeriodID: period.ID,
-		BaseSalary:      emp.Salary,
-		DaysAttended:    int(daysAttended),
-		WorkingDays:     workingDays,
-		ProratedSalary:  proratedSalary,
-		OvertimeHours:   totalOvertimeHours,
-		OvertimePay:     overtimePay,
-		Reimbursement:   totalReimbursement,
-		TakeHomePay:     takeHomePay,
-		PayslipDetails:  details,
-		BaseModel: models.BaseModel{
-			CreatedByID: adminID,
-			UpdatedByID: adminID,
-			RequestIP:   requestIP,
-		},
+	return models.Payslip{
+		EmployeeID:            emp.ID,
+		PayrollPeriodID:       in.Period.ID,
+		Currency:              emp.Currency,
+		BaseSalary:            emp.Salary,
+		DaysAttended:          in.DaysAttended,
+		PaidLeaveDays:         in.PaidLeaveDays,
+		UnpaidLeaveDays:       in.UnpaidLeaveDays,
+		WorkingDays:           workingDays,
+		ProratedSalary:        proratedSalary,
+		OvertimeHours:         ctx.OvertimeHours,
+		OvertimePay:           overtimePay,
+		Reimbursement:         totalReimbursement,
+		TaxableIncome:         taxableIncome,
+		TaxWithheld:           taxWithheld,
+		TaxTableID:            taxTableID,
+		GrossEarnings:         grossEarnings,
+		TotalDeductions:       totalDeductions,
+		TakeHomePay:           takeHomePay,
+		EmployerContributions: employerContributions,
+		PayslipDetails:        details,
+		LineItems:             ctx.Items,
 	}
+}
 
-	// Mark overtime and reimbursements as processed
-	tx := database.DB.Begin()
-	if len(overtimes) > 0 {
-		var ids []uint
-		for _, o := range overtimes {
-			ids = append(ids, o.ID)
-		}
-		if err := tx.Model(&models.Overtime{}).Where("id IN ?", ids).Update("payroll_run_id", period.ID).Error; err != nil {
-			tx.Rollback()
-			return models.Payslip{}, err
-		}
+// checkTakeHomePay rejects a payslip whose recurring deductions and employee
+// contributions add up to more than its earnings, so a negative payslip is
+// never saved or sent. A run skips such an employee rather than failing.
+func checkTakeHomePay(payslip models.Payslip) error {
+	if payslip.TakeHomePay < 0 {
+		return fmt.Errorf("%w by %s (earnings %s, deductions %s)", ErrNegativePay, -payslip.TakeHomePay, payslip.GrossEarnings, payslip.TotalDeductions)
 	}
-	if len(reimbursements) > 0 {
-		var ids []uint
-		for _, r := range reimbursements {
-			ids = append(ids, r.ID)
-		}
-		if err := tx.Model(&models.Reimbursement{}).Where("id IN ?", ids).Update("payroll_run_id", period.ID).Error; err != nil {
-			tx.Rollback()
-			return models.Payslip{}, err
-		}
+	return nil
+}
+
+// savePayslipCalculation creates the payslip and marks its overtime and
+// reimbursements as processed by the run. It must be called inside a transaction.
+// Claims already stamped by another run are never taken over; ErrClaimsAlreadyPaid
+// rolls the transaction back instead of paying them twice.
+func savePayslipCalculation(tx *gorm.DB, calc payslipCalculation) error {
+	if err := tx.Create(&calc.Payslip).Error; err != nil {
+		return err
 	}
-	tx.Commit()
+	runID := calc.Payslip.PayrollRunID
+	if err := stampClaims(tx, &models.Overtime{}, calc.OvertimeIDs, runID); err != nil {
+		return err
+	}
+	return stampClaims(tx, &models.Reimbursement{}, calc.ReimbursementIDs, runID)
+}
 
-	return payslip, nil
+// stampClaims marks unpaid claims of the given model as paid by the run.
+func stampClaims(tx *gorm.DB, model interface{}, ids []uint, runID uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	result := tx.Model(model).Where("id IN ? AND payroll_run_id IS NULL", ids).Update("payroll_run_id", runID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(ids)) {
+		return ErrClaimsAlreadyPaid
+	}
+	return nil
 }
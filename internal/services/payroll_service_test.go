@@ -1,10 +1,12 @@
 package services
 
 import (
+	"errors"
 	"log"
 	"os"
 	"payslip-generator/internal/database"
 	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
 	"testing"
 	"time"
 
@@ -31,6 +33,14 @@ func TestMain(m *testing.M) {
 	err = testDB.AutoMigrate(
 		&models.Employee{}, &models.Admin{}, &models.Attendance{},
 		&models.Overtime{}, &models.Reimbursement{}, &models.PayrollPeriod{}, &models.Payslip{},
+		&models.PayrollRun{}, &models.PayrollRunError{}, &models.AuditLog{},
+		&models.TaxTable{}, &models.TaxBracket{},
+		&models.PayslipLineItem{}, &models.RecurringPayComponent{}, &models.ContributionRule{}, &models.ExchangeRate{},
+		&models.HolidayCalendar{}, &models.Holiday{}, &models.WorkSchedule{},
+		&models.LeaveType{}, &models.LeaveRequest{}, &models.LeaveLedgerEntry{},
+		&models.ClaimApproval{}, &models.ApprovalRule{}, &models.ReimbursementReceipt{}, &models.ReimbursementCategory{},
+		&models.OvertimePolicy{}, &models.OvertimeTier{}, &models.PayslipTemplate{}, &models.PayslipTemplateVersion{},
+		&models.PayslipProtection{}, &models.PayslipDelivery{}, &models.Role{}, &models.RolePermission{}, &models.SeededPermission{}, &models.SetupStep{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate test database: %v", err)
@@ -48,9 +58,30 @@ func TestMain(m *testing.M) {
 
 // cleanDB is a helper function to reset the database tables between tests.
 func cleanDB() {
+	testDB.Exec("DELETE FROM payroll_run_errors")
+	testDB.Exec("DELETE FROM payroll_runs")
+	testDB.Exec("DELETE FROM payslip_line_items")
+	testDB.Exec("DELETE FROM recurring_pay_components")
+	testDB.Exec("DELETE FROM exchange_rates")
+	testDB.Exec("DELETE FROM holidays")
+	testDB.Exec("DELETE FROM holiday_calendars")
+	testDB.Exec("DELETE FROM work_schedules")
+	testDB.Exec("DELETE FROM leave_ledger_entries")
+	testDB.Exec("DELETE FROM leave_requests")
+	testDB.Exec("DELETE FROM leave_types")
+	testDB.Exec("DELETE FROM claim_approvals")
+	testDB.Exec("DELETE FROM approval_rules")
+	testDB.Exec("DELETE FROM reimbursement_receipts")
 	testDB.Exec("DELETE FROM payslips")
 	testDB.Exec("DELETE FROM reimbursements")
 	testDB.Exec("DELETE FROM overtimes")
+	testDB.Exec("DELETE FROM reimbursement_categories")
+	testDB.Exec("DELETE FROM overtime_tiers")
+	testDB.Exec("DELETE FROM overtime_policies")
+	testDB.Exec("DELETE FROM payslip_template_versions")
+	testDB.Exec("DELETE FROM payslip_templates")
+	testDB.Exec("DELETE FROM payslip_deliveries")
+	testDB.Exec("DELETE FROM payslip_protections")
 	testDB.Exec("DELETE FROM attendances")
 	testDB.Exec("DELETE FROM payroll_periods")
 	testDB.Exec("DELETE FROM employees")
@@ -60,7 +91,7 @@ func TestCalculatePayslipForEmployee(t *testing.T) {
 	// Define a reusable employee and payroll period for our tests.
 	employee := models.Employee{
 		Username:  "testuser",
-		Salary:    10500000, // 10.5M for easy calculation (500k/day for 21 working days)
+		Salary:    money.FromUnits(10500000), // 10.5M for easy calculation (500k/day for 21 working days)
 		BaseModel: models.BaseModel{ID: 1},
 	}
 	// A period with 21 working days (e.g., June 2025)
@@ -81,12 +112,12 @@ func TestCalculatePayslipForEmployee(t *testing.T) {
 			}
 		}
 
-		payslip, err := calculatePayslipForEmployee(employee, period, 1, "127.0.0.1")
+		calc, err := calculatePayslipForEmployee(employee, period, 1, 1, "127.0.0.1")
 		if err != nil {
 			t.Fatalf("Expected no error, but got %v", err)
 		}
-		if payslip.TakeHomePay != 10500000 {
-			t.Errorf("Expected takeHomePay of 10500000, but got %f", payslip.TakeHomePay)
+		if calc.Payslip.TakeHomePay != money.FromUnits(10500000) {
+			t.Errorf("Expected takeHomePay of 10500000, but got %s", calc.Payslip.TakeHomePay)
 		}
 	})
 
@@ -100,17 +131,388 @@ func TestCalculatePayslipForEmployee(t *testing.T) {
 				testDB.Create(&models.Attendance{EmployeeID: employee.ID, CheckIn: day})
 			}
 		}
-		testDB.Create(&models.Overtime{EmployeeID: employee.ID, Hours: 3, Date: time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC)})
-		testDB.Create(&models.Reimbursement{EmployeeID: employee.ID, Amount: 50000, Description: "Test"})
+		testDB.Create(&models.Overtime{EmployeeID: employee.ID, Hours: 3, Status: models.ClaimApproved, Date: time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC)})
+		testDB.Create(&models.Reimbursement{EmployeeID: employee.ID, Status: models.ClaimApproved, Amount: money.FromUnits(50000), Description: "Test", BaseModel: models.BaseModel{CreatedAt: time.Date(2025, 6, 10, 9, 0, 0, 0, time.UTC)}})
 
-		payslip, err := calculatePayslipForEmployee(employee, period, 1, "127.0.0.1")
+		calc, err := calculatePayslipForEmployee(employee, period, 1, 1, "127.0.0.1")
 
-		expectedPay := 7925000.0
+		expectedPay := money.FromUnits(7925000)
 		if err != nil {
 			t.Fatalf("Expected no error, but got %v", err)
 		}
-		if payslip.TakeHomePay != expectedPay {
-			t.Errorf("Expected takeHomePay of %f, but got %f", expectedPay, payslip.TakeHomePay)
+		if calc.Payslip.TakeHomePay != expectedPay {
+			t.Errorf("Expected takeHomePay of %s, but got %s", expectedPay, calc.Payslip.TakeHomePay)
 		}
 	})
+
+	t.Run("pays reimbursements submitted by the end of the period, including late approvals", func(t *testing.T) {
+		cleanDB()
+		testDB.Create(&employee)
+		testDB.Create(&period)
+		for _, submitted := range []time.Time{
+			time.Date(2025, 5, 20, 9, 0, 0, 0, time.UTC),  // Approved after May was run
+			time.Date(2025, 6, 30, 18, 0, 0, 0, time.UTC), // Last day of the period
+			time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC),   // Left to the next period
+		} {
+			testDB.Create(&models.Reimbursement{EmployeeID: employee.ID, Status: models.ClaimApproved, Amount: money.FromUnits(50000), Description: "Test", BaseModel: models.BaseModel{CreatedAt: submitted}})
+		}
+
+		calc, err := calculatePayslipForEmployee(employee, period, 1, 1, "127.0.0.1")
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if len(calc.ReimbursementIDs) != 2 || calc.Payslip.Reimbursement != money.FromUnits(100000) {
+			t.Errorf("Expected the two reimbursements submitted by the end of the period, but got %d claims totalling %s", len(calc.ReimbursementIDs), calc.Payslip.Reimbursement)
+		}
+	})
+}
+
+func TestRunPayrollServiceIsAtomic(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	first := models.Employee{Username: "first", Salary: money.FromUnits(1000000)}
+	second := models.Employee{Username: "second", Salary: money.FromUnits(2000000)}
+	testDB.Create(&first)
+	testDB.Create(&second)
+	overtime := models.Overtime{EmployeeID: first.ID, Hours: 2, Status: models.ClaimApproved, Date: time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC)}
+	testDB.Create(&overtime)
+
+	// Make saving the second employee's payslip fail after the first one was written.
+	failSecond := func(db *gorm.DB) {
+		if p, ok := db.Statement.Dest.(*models.Payslip); ok && p.EmployeeID == second.ID {
+			db.AddError(errors.New("simulated failure"))
+		}
+	}
+	testDB.Callback().Create().Before("gorm:create").Register("test:fail_second", failSecond)
+
+	run, err := QueuePayrollRun(period.ID, 1, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected run to be queued, but got %v", err)
+	}
+	RunPayrollService(run.ID)
+	testDB.Callback().Create().Remove("test:fail_second")
+
+	testDB.First(&run, run.ID)
+	if run.Status != models.PayrollRunFailed {
+		t.Errorf("Expected run status %q, but got %q", models.PayrollRunFailed, run.Status)
+	}
+	testDB.First(&period, period.ID)
+	if period.IsRun {
+		t.Error("Expected period to remain open after a failed run")
+	}
+	var payslipCount int64
+	testDB.Model(&models.Payslip{}).Count(&payslipCount)
+	if payslipCount != 0 {
+		t.Errorf("Expected no payslips after rollback, but found %d", payslipCount)
+	}
+	testDB.First(&overtime, overtime.ID)
+	if overtime.PayrollRunID != nil {
+		t.Errorf("Expected overtime to stay unprocessed, but it was stamped with run %d", *overtime.PayrollRunID)
+	}
+
+	// Retrying the period now succeeds cleanly.
+	retry, err := QueuePayrollRun(period.ID, 1, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected retry to be queued, but got %v", err)
+	}
+	RunPayrollService(retry.ID)
+
+	testDB.First(&retry, retry.ID)
+	if retry.Status != models.PayrollRunSucceeded {
+		t.Fatalf("Expected retry status %q, but got %q (%s)", models.PayrollRunSucceeded, retry.Status, retry.Error)
+	}
+	testDB.Model(&models.Payslip{}).Count(&payslipCount)
+	if payslipCount != 2 {
+		t.Errorf("Expected 2 payslips after retry, but found %d", payslipCount)
+	}
+	testDB.First(&overtime, overtime.ID)
+	if overtime.PayrollRunID == nil || *overtime.PayrollRunID != retry.ID {
+		t.Errorf("Expected overtime to be stamped with run %d", retry.ID)
+	}
+}
+
+func TestPreviewPayrollHasNoSideEffects(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	employee := models.Employee{Username: "previewed", Salary: money.FromUnits(10500000)}
+	testDB.Create(&employee)
+	testDB.Create(&models.Attendance{EmployeeID: employee.ID, CheckIn: time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)})
+	overtime := models.Overtime{EmployeeID: employee.ID, Hours: 1, Status: models.ClaimApproved, Date: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)}
+	testDB.Create(&overtime)
+	reimbursement := models.Reimbursement{EmployeeID: employee.ID, Status: models.ClaimApproved, Amount: money.FromUnits(25000), Description: "Test", BaseModel: models.BaseModel{CreatedAt: time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)}}
+	testDB.Create(&reimbursement)
+
+	preview, err := PreviewPayroll(period.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if len(preview.Payslips) != 1 {
+		t.Fatalf("Expected 1 previewed payslip, but got %d", len(preview.Payslips))
+	}
+	// 1 day at 500k + 1 overtime hour at 125k + 25k reimbursement
+	if totals := preview.Totals[models.DefaultCurrency]; totals == nil || totals.TotalPayout != money.FromUnits(650000) {
+		t.Errorf("Expected total payout of 650000, but got %+v", preview.Totals)
+	}
+
+	testDB.First(&period, period.ID)
+	if period.IsRun {
+		t.Error("Expected preview to leave the period open")
+	}
+	var payslipCount int64
+	testDB.Model(&models.Payslip{}).Count(&payslipCount)
+	if payslipCount != 0 {
+		t.Errorf("Expected preview to create no payslips, but found %d", payslipCount)
+	}
+	testDB.First(&overtime, overtime.ID)
+	testDB.First(&reimbursement, reimbursement.ID)
+	if overtime.PayrollRunID != nil || reimbursement.PayrollRunID != nil {
+		t.Error("Expected preview to leave overtime and reimbursements unprocessed")
+	}
+}
+
+func TestReversePayroll(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	employee := models.Employee{Username: "reversed", Salary: money.FromUnits(1000000)}
+	testDB.Create(&employee)
+	reimbursement := models.Reimbursement{EmployeeID: employee.ID, Status: models.ClaimApproved, Amount: money.FromUnits(10000), Description: "Test", BaseModel: models.BaseModel{CreatedAt: time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)}}
+	testDB.Create(&reimbursement)
+
+	if err := ReversePayroll(period.ID, 1, "Not run yet", "127.0.0.1"); !errors.Is(err, ErrPayrollNotRun) {
+		t.Fatalf("Expected ErrPayrollNotRun for an open period, but got %v", err)
+	}
+
+	run, _ := QueuePayrollRun(period.ID, 1, "127.0.0.1")
+	RunPayrollService(run.ID)
+
+	if err := ReversePayroll(period.ID, 1, "Wrong salary", "127.0.0.1"); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	testDB.First(&period, period.ID)
+	if period.IsRun {
+		t.Error("Expected period to be reopened")
+	}
+	testDB.First(&run, run.ID)
+	if run.Status != models.PayrollRunReversed {
+		t.Errorf("Expected run status %q, but got %q", models.PayrollRunReversed, run.Status)
+	}
+	testDB.First(&reimbursement, reimbursement.ID)
+	if reimbursement.PayrollRunID != nil {
+		t.Error("Expected reimbursement to be released by the reversal")
+	}
+	var payslip models.Payslip
+	testDB.Where("employee_id = ?", employee.ID).First(&payslip)
+	if payslip.VoidedAt == nil || payslip.VoidReason != "Wrong salary" {
+		t.Errorf("Expected payslip to be voided with the reason, got voidedAt=%v reason=%q", payslip.VoidedAt, payslip.VoidReason)
+	}
+
+	// The period can be run again and pays the released reimbursement once more.
+	rerun, err := QueuePayrollRun(period.ID, 1, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected re-run to be queued, but got %v", err)
+	}
+	RunPayrollService(rerun.ID)
+	var active []models.Payslip
+	testDB.Where("employee_id = ? AND voided_at IS NULL", employee.ID).Find(&active)
+	if len(active) != 1 || active[0].Reimbursement != money.FromUnits(10000) {
+		t.Errorf("Expected one active payslip including the reimbursement, got %+v", active)
+	}
+}
+
+func TestComputePayslipItemizesEarningsAndDeductions(t *testing.T) {
+	table := sampleTaxTable(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	in := payslipInputs{
+		Schedule: DefaultWorkSchedule,
+		Employee: models.Employee{Salary: money.FromUnits(10500000), TaxMaritalStatus: models.TaxStatusSingle},
+		Period: models.PayrollPeriod{
+			StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+		},
+		DaysAttended:   20,
+		Reimbursements: []models.Reimbursement{{Amount: money.FromUnits(50000), Description: "Taxi"}},
+		TaxTable:       &table,
+	}
+
+	payslip := computePayslip(in)
+
+	codes := map[string]money.Amount{}
+	for _, item := range payslip.LineItems {
+		codes[item.Code] += item.Amount
+	}
+	if codes[CodeBasicSalary] != money.FromUnits(10000000) || codes[CodeReimbursement] != money.FromUnits(50000) {
+		t.Errorf("Expected basic salary and reimbursement line items, got %+v", payslip.LineItems)
+	}
+	// Only the 10M salary is taxable: (120M - 54M) gives 3.9M/year, 325k/month
+	if codes[CodeIncomeTax] != money.FromUnits(325000) {
+		t.Errorf("Expected income tax of 325000, got %s", codes[CodeIncomeTax])
+	}
+	if payslip.GrossEarnings != money.FromUnits(10050000) || payslip.TakeHomePay != money.FromUnits(9725000) {
+		t.Errorf("Expected gross 10050000 and take-home 9725000, got %s and %s", payslip.GrossEarnings, payslip.TakeHomePay)
+	}
+}
+
+func TestRecurringPayComponentsArePickedUp(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	employee := models.Employee{Username: "recurring", Salary: money.FromUnits(10000000)}
+	testDB.Create(&employee)
+
+	ended := time.Date(2025, 5, 31, 0, 0, 0, 0, time.UTC)
+	components := []models.RecurringPayComponent{
+		{EmployeeID: employee.ID, Code: "TRANSPORT", Type: models.LineItemEarning, Calculation: models.CalculationFixed, Amount: money.FromUnits(500000), Taxable: true, StartDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{EmployeeID: employee.ID, Code: "PENSION_PLAN", Type: models.LineItemDeduction, Calculation: models.CalculationPercentage, Percentage: 2, Taxable: true, StartDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{EmployeeID: employee.ID, Code: "LOAN", Type: models.LineItemDeduction, Calculation: models.CalculationFixed, Amount: money.FromUnits(300000), StartDate: time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)},
+		{EmployeeID: employee.ID, Code: "MEAL", Type: models.LineItemEarning, Calculation: models.CalculationFixed, Amount: money.FromUnits(400000), StartDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: &ended},
+	}
+	for i := range components {
+		testDB.Create(&components[i])
+	}
+
+	calc, err := calculatePayslipForEmployee(employee, period, 1, 1, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	amounts := map[string]money.Amount{}
+	for _, item := range calc.Payslip.LineItems {
+		amounts[item.Code] = item.Amount
+	}
+	if amounts["TRANSPORT"] != money.FromUnits(500000) || amounts["PENSION_PLAN"] != money.FromUnits(200000) || amounts["LOAN"] != money.FromUnits(300000) {
+		t.Errorf("Expected active recurring items on the payslip, got %+v", amounts)
+	}
+	if _, ok := amounts["MEAL"]; ok {
+		t.Error("Expected the ended MEAL allowance to be left out")
+	}
+	// No attendance: 500k taxable allowance less the 200k pre-tax pension deduction
+	if calc.Payslip.TaxableIncome != money.FromUnits(300000) {
+		t.Errorf("Expected taxable income of 300000, but got %s", calc.Payslip.TaxableIncome)
+	}
+	if calc.Payslip.TakeHomePay != 0 {
+		t.Errorf("Expected take-home pay of 0, but got %s", calc.Payslip.TakeHomePay)
+	}
+}
+
+func TestReversePayrollKeepsLegacyClaimsPaid(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+		IsRun:     true,
+	}
+	testDB.Create(&period)
+	employee := models.Employee{Username: "legacy", Salary: money.FromUnits(1000000)}
+	testDB.Create(&employee)
+
+	// A period paid before runs were tracked, as left by the database migration.
+	legacy := models.PayrollRun{PayrollPeriodID: period.ID, Status: models.PayrollRunSucceeded, Legacy: true}
+	testDB.Create(&legacy)
+	reimbursement := models.Reimbursement{EmployeeID: employee.ID, Status: models.ClaimApproved, Amount: money.FromUnits(10000), Description: "Test", PayrollRunID: &legacy.ID}
+	testDB.Create(&reimbursement)
+	testDB.Create(&models.Payslip{EmployeeID: employee.ID, PayrollPeriodID: period.ID, PayrollRunID: legacy.ID})
+
+	if err := ReversePayroll(period.ID, 1, "Wrong salary", "127.0.0.1"); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	testDB.First(&reimbursement, reimbursement.ID)
+	if reimbursement.PayrollRunID == nil || *reimbursement.PayrollRunID != legacy.ID {
+		t.Error("Expected the legacy claim to stay paid")
+	}
+	testDB.First(&legacy, legacy.ID)
+	if legacy.Status != models.PayrollRunSucceeded {
+		t.Errorf("Expected the legacy run to keep status %q, but got %q", models.PayrollRunSucceeded, legacy.Status)
+	}
+	var voided int64
+	testDB.Model(&models.Payslip{}).Where("voided_at IS NOT NULL").Count(&voided)
+	if voided != 1 {
+		t.Errorf("Expected the legacy payslip to be voided, but %d were", voided)
+	}
+}
+
+func TestSavePayslipCalculationNeverPaysAClaimTwice(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	employee := models.Employee{Username: "raced", Salary: money.FromUnits(1000000)}
+	testDB.Create(&employee)
+	overtime := models.Overtime{EmployeeID: employee.ID, Hours: 2, Status: models.ClaimApproved, Date: time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC)}
+	testDB.Create(&overtime)
+
+	calc, err := calculatePayslipForEmployee(employee, period, 2, 1, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	// Another run, for a later period, pays the same overtime between the two phases.
+	otherRun := uint(1)
+	testDB.Model(&overtime).Update("payroll_run_id", otherRun)
+
+	err = testDB.Transaction(func(tx *gorm.DB) error { return savePayslipCalculation(tx, calc) })
+	if !errors.Is(err, ErrClaimsAlreadyPaid) {
+		t.Fatalf("Expected ErrClaimsAlreadyPaid, but got %v", err)
+	}
+	var payslipCount int64
+	testDB.Model(&models.Payslip{}).Count(&payslipCount)
+	if payslipCount != 0 {
+		t.Errorf("Expected the payslip to be rolled back, but found %d", payslipCount)
+	}
+	testDB.First(&overtime, overtime.ID)
+	if overtime.PayrollRunID == nil || *overtime.PayrollRunID != otherRun {
+		t.Error("Expected the overtime to stay with the run that paid it first")
+	}
+}
+
+func TestPayrollRunSkipsEmployeesWithNegativeTakeHomePay(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	// No attendance, so nothing is earned, but the loan repayment is still due.
+	indebted := models.Employee{Username: "indebted", Salary: money.FromUnits(10000000)}
+	testDB.Create(&indebted)
+	testDB.Create(&models.RecurringPayComponent{EmployeeID: indebted.ID, Code: "LOAN", Type: models.LineItemDeduction, Calculation: models.CalculationFixed, Amount: money.FromUnits(300000), StartDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+	colleague := models.Employee{Username: "colleague", Salary: money.FromUnits(10500000)}
+	testDB.Create(&colleague)
+	testDB.Create(&models.Attendance{EmployeeID: colleague.ID, CheckIn: time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)})
+
+	preview, err := PreviewPayroll(period.ID)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if len(preview.Payslips) != 1 || len(preview.Errors) != 1 || preview.Errors[0].EmployeeID != indebted.ID || !preview.Errors[0].Skipped {
+		t.Errorf("Expected the preview to report the indebted employee as skipped, got %+v", preview)
+	}
+
+	run, _ := QueuePayrollRun(period.ID, 1, "127.0.0.1")
+	RunPayrollService(run.ID)
+	testDB.Preload("Errors").First(&run, run.ID)
+	if run.Status != models.PayrollRunSucceeded || run.SkippedCount != 1 || len(run.Errors) != 1 || !run.Errors[0].Skipped {
+		t.Fatalf("Expected the run to succeed with one skipped employee, got status %q, %d skipped and errors %+v", run.Status, run.SkippedCount, run.Errors)
+	}
+	var payslips []models.Payslip
+	testDB.Find(&payslips)
+	if len(payslips) != 1 || payslips[0].EmployeeID != colleague.ID {
+		t.Errorf("Expected only the colleague's payslip to be saved, got %+v", payslips)
+	}
 }
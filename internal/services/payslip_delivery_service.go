@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/mail"
+	"payslip-generator/internal/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrDeliveryNotFound is returned for an unknown payslip delivery.
+var ErrDeliveryNotFound = errors.New("payslip delivery not found")
+
+// MaxDeliveryAttempts is how many times a payslip is sent before its delivery fails.
+const MaxDeliveryAttempts = 5
+
+// deliveryRetryDelay is the wait before the first retry. It doubles with each
+// further attempt: 1, 2, 4 and 8 minutes.
+const deliveryRetryDelay = time.Minute
+
+// deliveryBatchSize is how many due deliveries are loaded at a time.
+const deliveryBatchSize = 100
+
+// deliveryMu keeps concurrent deliveries in the process from sending a payslip twice.
+var deliveryMu sync.Mutex
+
+// QueuePayslipDeliveries queues the emailing of a period's payslips to their
+// employees, skipping those already sent or queued. Employees without an email
+// address get a failed delivery. The deliveries are sent in the background if
+// email is configured. It returns ErrPeriodNotFound or ErrPayrollNotRun if the
+// period has no payslips to send.
+func QueuePayslipDeliveries(periodID, adminID uint, requestIP string) ([]models.PayslipDelivery, error) {
+	var period models.PayrollPeriod
+	if err := database.DB.First(&period, periodID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPeriodNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !period.IsRun {
+		return nil, ErrPayrollNotRun
+	}
+
+	var payslips []models.Payslip
+	err := database.DB.
+		Where("payroll_period_id = ? AND voided_at IS NULL", periodID).
+		Where("id NOT IN (?)", database.DB.Model(&models.PayslipDelivery{}).Select("payslip_id").
+			Where("status IN ?", []string{models.DeliveryQueued, models.DeliverySent})).
+		Order("id").
+		Find(&payslips).Error
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]models.PayslipDelivery, 0, len(payslips))
+	for _, p := range payslips {
+		delivery, err := queuePayslipDelivery(p, adminID, requestIP)
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	deliverInBackground()
+	return deliveries, nil
+}
+
+// ResendPayslipDelivery queues the payslip of a delivery again, to the
+// employee's current email address, and sends it in the background if email
+// is configured. It returns ErrPayslipNotFound if the payslip has since been voided.
+func ResendPayslipDelivery(deliveryID, adminID uint, requestIP string) (models.PayslipDelivery, error) {
+	var previous models.PayslipDelivery
+	if err := database.DB.First(&previous, deliveryID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return previous, ErrDeliveryNotFound
+	} else if err != nil {
+		return previous, err
+	}
+	var payslip models.Payslip
+	if err := database.DB.Where("voided_at IS NULL").First(&payslip, previous.PayslipID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return previous, ErrPayslipNotFound
+	} else if err != nil {
+		return previous, err
+	}
+
+	delivery, err := queuePayslipDelivery(payslip, adminID, requestIP)
+	if err == nil {
+		deliverInBackground()
+	}
+	return delivery, err
+}
+
+// queuePayslipDelivery records a queued delivery of the payslip to its
+// employee's email address, or a failed one if they have none.
+func queuePayslipDelivery(p models.Payslip, adminID uint, requestIP string) (models.PayslipDelivery, error) {
+	var employee models.Employee
+	if err := database.DB.First(&employee, p.EmployeeID).Error; err != nil {
+		return models.PayslipDelivery{}, err
+	}
+	delivery := models.PayslipDelivery{
+		PayslipID:       p.ID,
+		EmployeeID:      p.EmployeeID,
+		PayrollPeriodID: p.PayrollPeriodID,
+		Recipient:       employee.Email,
+		Status:          models.DeliveryQueued,
+		CreatedByID:     adminID,
+		RequestIP:       requestIP,
+	}
+	if employee.Email == "" {
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = "The employee has no email address."
+	} else {
+		now := time.Now()
+		delivery.NextAttemptAt = &now
+	}
+	err := database.DB.Create(&delivery).Error
+	return delivery, err
+}
+
+// deliverInBackground sends the due deliveries without waiting for the
+// payslip mailer, if email is configured.
+func deliverInBackground() {
+	if sender := mail.Outgoing; sender != nil {
+		go DeliverQueuedPayslips(context.Background(), sender, time.Now())
+	}
+}
+
+// DeliverQueuedPayslips sends every queued delivery due by now and returns how
+// many it attempted. Deliveries the server refuses bounce; those that fail
+// otherwise are retried later, up to MaxDeliveryAttempts.
+func DeliverQueuedPayslips(ctx context.Context, sender mail.Sender, now time.Time) (int, error) {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+
+	attempted := 0
+	for {
+		// Attempted deliveries are no longer due, so each batch is new.
+		var due []models.PayslipDelivery
+		err := database.DB.Where("status = ? AND next_attempt_at <= ?", models.DeliveryQueued, now).
+			Order("id").Limit(deliveryBatchSize).Find(&due).Error
+		if err != nil {
+			return attempted, err
+		}
+		for i := range due {
+			if err := deliverPayslip(ctx, sender, &due[i], now); err != nil {
+				return attempted, err
+			}
+			attempted++
+		}
+		if len(due) < deliveryBatchSize {
+			return attempted, nil
+		}
+	}
+}
+
+// deliverPayslip makes an attempt at a delivery and records its outcome.
+func deliverPayslip(ctx context.Context, sender mail.Sender, d *models.PayslipDelivery, now time.Time) error {
+	d.Attempts++
+	err := sendPayslip(ctx, sender, *d)
+	switch {
+	case err == nil:
+		sentAt := time.Now()
+		d.Status, d.SentAt, d.NextAttemptAt, d.Error = models.DeliverySent, &sentAt, nil, ""
+	case errors.Is(err, mail.ErrRejected):
+		d.Status, d.NextAttemptAt = models.DeliveryBounced, nil
+	case errors.Is(err, ErrPayslipNotFound), errors.Is(err, ErrNoDocumentPassword), d.Attempts >= MaxDeliveryAttempts:
+		// Trying again won't help, or has been tried enough.
+		d.Status, d.NextAttemptAt = models.DeliveryFailed, nil
+	default:
+		next := now.Add(deliveryRetryDelay << (d.Attempts - 1))
+		d.NextAttemptAt = &next
+	}
+	if err != nil {
+		d.Error = err.Error()
+		log.Printf("[Payslip Mailer] Attempt %d of delivery ID %d to %s: %v", d.Attempts, d.ID, d.Recipient, err)
+	}
+	return database.DB.Save(d).Error
+}
+
+// sendPayslip emails the delivery's payslip as a PDF attachment, encrypted if
+// the employee's group protects its payslips.
+func sendPayslip(ctx context.Context, sender mail.Sender, d models.PayslipDelivery) error {
+	doc, err := LoadPayslipDocument(d.EmployeeID, d.PayrollPeriodID)
+	if err != nil {
+		return err
+	}
+	if doc.Payslip.ID != d.PayslipID {
+		return fmt.Errorf("%w: payslip ID %d has been voided", ErrPayslipNotFound, d.PayslipID)
+	}
+	var pdf bytes.Buffer
+	if err := RenderPayslipPDF(doc, &pdf); err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("Dear %s,\n\nYour payslip from %s for %s is attached.\n", doc.Employee.Username, doc.Company.Name, periodLabel(doc.Period))
+	if doc.password != "" {
+		text += "Open it with your payslip document password.\n"
+	}
+	text += "\nThis message was sent automatically; please contact HR with any questions.\n"
+	return sender.Send(ctx, mail.Message{
+		To:          d.Recipient,
+		Subject:     "Your payslip for " + periodLabel(doc.Period),
+		Text:        text,
+		Attachments: []mail.Attachment{{FileName: doc.FileName("pdf"), ContentType: "application/pdf", Data: pdf.Bytes()}},
+	})
+}
+
+// RunPayslipMailer sends the due payslip deliveries through mail.Outgoing
+// every interval, retrying failed attempts, until the process exits.
+func RunPayslipMailer(interval time.Duration) {
+	for {
+		if _, err := DeliverQueuedPayslips(context.Background(), mail.Outgoing, time.Now()); err != nil {
+			log.Printf("[Payslip Mailer] Error: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
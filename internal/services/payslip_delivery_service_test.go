@@ -0,0 +1,109 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"payslip-generator/internal/mail"
+	"payslip-generator/internal/mail/mailtest"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"testing"
+	"time"
+)
+
+// unreachableSender fails like a mail server that can't be reached.
+type unreachableSender struct{}
+
+func (unreachableSender) Send(context.Context, mail.Message) error {
+	return errors.New("dial tcp 127.0.0.1:25: connection refused")
+}
+
+func TestDeliverQueuedPayslips(t *testing.T) {
+	cleanDB()
+	server := mailtest.NewServer()
+	defer server.Close()
+	server.Reject("bob@example.com")
+	sender, err := mail.NewSMTPSender(mail.SMTPConfig{Host: server.Host, Port: server.Port, From: "payroll@example.com", TLS: mail.TLSNone})
+	if err != nil {
+		t.Fatalf("Expected a sender, but got %v", err)
+	}
+
+	period := models.PayrollPeriod{StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)}
+	testDB.Create(&period)
+	if _, err := QueuePayslipDeliveries(period.ID, 1, "127.0.0.1"); !errors.Is(err, ErrPayrollNotRun) {
+		t.Errorf("Expected ErrPayrollNotRun before the payroll is run, but got %v", err)
+	}
+	testDB.Model(&period).Update("is_run", true)
+	employees := map[string]models.Employee{}
+	for _, e := range [][2]string{{"alice", "alice@example.com"}, {"bob", "bob@example.com"}, {"carol", ""}} {
+		employee := models.Employee{Username: e[0], Email: e[1], Salary: money.FromUnits(10000000)}
+		testDB.Create(&employee)
+		testDB.Create(&models.Payslip{EmployeeID: employee.ID, PayrollPeriodID: period.ID, Currency: "IDR", TakeHomePay: money.FromUnits(10000000)})
+		employees[e[0]] = employee
+	}
+
+	deliveries, err := QueuePayslipDeliveries(period.ID, 1, "127.0.0.1")
+	if err != nil || len(deliveries) != 3 {
+		t.Fatalf("Expected three deliveries, but got %d (%v)", len(deliveries), err)
+	}
+	if deliveries[2].Status != models.DeliveryFailed || deliveries[2].Error == "" {
+		t.Errorf("Expected the delivery to an employee without an address to fail, but got %+v", deliveries[2])
+	}
+	if again, _ := QueuePayslipDeliveries(period.ID, 1, "127.0.0.1"); len(again) != 1 || again[0].EmployeeID != employees["carol"].ID {
+		t.Errorf("Expected only the failed payslip to be queued again, but got %+v", again)
+	}
+
+	now := time.Now()
+	if n, err := DeliverQueuedPayslips(context.Background(), sender, now); err != nil || n != 2 {
+		t.Fatalf("Expected two deliveries to be attempted, but got %d (%v)", n, err)
+	}
+	status := func(id uint) models.PayslipDelivery {
+		var d models.PayslipDelivery
+		testDB.First(&d, id)
+		return d
+	}
+	if d := status(deliveries[0].ID); d.Status != models.DeliverySent || d.SentAt == nil || d.Attempts != 1 {
+		t.Errorf("Expected alice's payslip to be sent, but got %+v", d)
+	}
+	if d := status(deliveries[1].ID); d.Status != models.DeliveryBounced || d.Error == "" {
+		t.Errorf("Expected bob's payslip to bounce, but got %+v", d)
+	}
+	messages := server.Messages()
+	if len(messages) != 1 || messages[0].To[0] != "alice@example.com" ||
+		!bytes.Contains(messages[0].Data, []byte("Subject: Your payslip for 1 Jun 2025 - 30 Jun 2025")) ||
+		!bytes.Contains(messages[0].Data, []byte(`filename=payslip-alice-2025-06-01_2025-06-30.pdf`)) {
+		t.Fatalf("Expected alice to get the payslip as an attachment, but got %+v", messages)
+	}
+
+	// Once the address is fixed, the bounced payslip can be sent again.
+	testDB.Model(&models.Employee{}).Where("id = ?", employees["bob"].ID).Update("email", "robert@example.com")
+	resent, err := ResendPayslipDelivery(deliveries[1].ID, 1, "127.0.0.1")
+	if err != nil || resent.Status != models.DeliveryQueued || resent.Recipient != "robert@example.com" {
+		t.Fatalf("Expected a new delivery to the new address, but got %+v (%v)", resent, err)
+	}
+	if _, err := ResendPayslipDelivery(9999, 1, "127.0.0.1"); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("Expected ErrDeliveryNotFound, but got %v", err)
+	}
+
+	// Temporary failures are retried with growing delays until the last attempt.
+	delays := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute}
+	now = time.Now()
+	for attempt := 1; attempt <= MaxDeliveryAttempts; attempt++ {
+		if n, _ := DeliverQueuedPayslips(context.Background(), unreachableSender{}, now); n != 1 {
+			t.Fatalf("Expected attempt %d to be made, but %d were", attempt, n)
+		}
+		d := status(resent.ID)
+		if attempt < MaxDeliveryAttempts {
+			if d.Status != models.DeliveryQueued || d.Attempts != attempt || d.NextAttemptAt == nil || !d.NextAttemptAt.Equal(now.Add(delays[attempt-1])) {
+				t.Fatalf("Expected attempt %d to be retried after %v, but got %+v", attempt, delays[attempt-1], d)
+			}
+			if n, _ := DeliverQueuedPayslips(context.Background(), unreachableSender{}, now); n != 0 {
+				t.Fatalf("Expected no attempt before the retry is due")
+			}
+			now = *d.NextAttemptAt
+		} else if d.Status != models.DeliveryFailed {
+			t.Errorf("Expected the delivery to fail after %d attempts, but got %+v", MaxDeliveryAttempts, d)
+		}
+	}
+}
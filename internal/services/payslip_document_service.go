@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrPayslipNotFound is returned when an employee has no payslip for a period,
+// or only a voided one.
+var ErrPayslipNotFound = errors.New("payslip not found")
+
+// CompanyProfile is the employer shown at the top of payslip documents.
+type CompanyProfile struct {
+	Name    string
+	Address string
+}
+
+// companyProfile is the profile in use, read by LoadCompanyProfile.
+var companyProfile = CompanyProfile{Name: "Payslip Generator"}
+
+// LoadCompanyProfile reads the employer shown on payslip documents from
+// COMPANY_NAME and COMPANY_ADDRESS. An unset name keeps the default.
+func LoadCompanyProfile() {
+	profile := CompanyProfile{Name: os.Getenv("COMPANY_NAME"), Address: os.Getenv("COMPANY_ADDRESS")}
+	if profile.Name == "" {
+		profile.Name = "Payslip Generator"
+	}
+	companyProfile = profile
+}
+
+// PayslipDocument is everything printed on an employee's payslip for a period.
+type PayslipDocument struct {
+	Company    CompanyProfile
+	Employee   models.Employee
+	Period     models.PayrollPeriod
+	Payslip    models.Payslip // With its line items in the order they were calculated
+	YearToDate YearToDateTotals
+
+	// password opens the PDF document; empty if it isn't encrypted.
+	// passwordErr is why the password couldn't be derived.
+	password    string
+	passwordErr error
+}
+
+// YearToDateTotals add up an employee's payslips from the start of the
+// calendar year up to and including the document's period. Only payslips in
+// the document's currency are counted.
+type YearToDateTotals struct {
+	Year            int          `json:"year"`
+	Payslips        int          `json:"payslips"`
+	GrossEarnings   money.Amount `json:"grossEarnings"`
+	TotalDeductions money.Amount `json:"totalDeductions"`
+	TaxWithheld     money.Amount `json:"taxWithheld"`
+	TakeHomePay     money.Amount `json:"takeHomePay"`
+}
+
+// FileName names the document's file by employee username and period, e.g.
+// "payslip-jdoe-2025-06-01_2025-06-30.pdf" for the extension "pdf".
+func (d PayslipDocument) FileName(extension string) string {
+	return fmt.Sprintf("payslip-%s-%s_%s.%s", d.Employee.Username,
+		d.Period.StartDate.Format("2006-01-02"), d.Period.EndDate.Format("2006-01-02"), extension)
+}
+
+// LoadPayslipDocument gathers an employee's payslip for a period, with the
+// period, the employee and the year-to-date totals. It returns
+// ErrPayslipNotFound if the employee has no payslip for the period.
+func LoadPayslipDocument(employeeID, periodID uint) (PayslipDocument, error) {
+	doc := PayslipDocument{Company: companyProfile}
+
+	err := database.DB.Preload("LineItems", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("employee_id = ? AND payroll_period_id = ? AND voided_at IS NULL", employeeID, periodID).
+		First(&doc.Payslip).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return doc, ErrPayslipNotFound
+	} else if err != nil {
+		return doc, err
+	}
+	if err := database.DB.First(&doc.Employee, employeeID).Error; err != nil {
+		return doc, err
+	}
+	doc.password, doc.passwordErr = DocumentPassword(doc.Employee)
+	doc.Employee.Password = "" // Documents are rendered with admin-defined templates
+	doc.Employee.DocumentPassword = ""
+	if err := database.DB.First(&doc.Period, periodID).Error; err != nil {
+		return doc, err
+	}
+
+	doc.YearToDate, err = yearToDateTotals(employeeID, doc.Payslip.Currency, doc.Period.EndDate)
+	return doc, err
+}
+
+// yearToDateTotals adds up the employee's payslips in a currency for periods
+// ending between the start of the year and the given date.
+func yearToDateTotals(employeeID uint, currency string, upTo time.Time) (YearToDateTotals, error) {
+	totals := YearToDateTotals{Year: upTo.Year()}
+	yearStart := time.Date(upTo.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var payslips []models.Payslip
+	err := database.DB.Joins("JOIN payroll_periods ON payroll_periods.id = payslips.payroll_period_id").
+		Where("payslips.employee_id = ? AND payslips.currency = ? AND payslips.voided_at IS NULL", employeeID, currency).
+		Where("payroll_periods.end_date >= ? AND payroll_periods.end_date <= ?", yearStart, upTo).
+		Find(&payslips).Error
+	if err != nil {
+		return totals, err
+	}
+	for _, p := range payslips {
+		totals.Payslips++
+		totals.GrossEarnings += p.GrossEarnings
+		totals.TotalDeductions += p.TotalDeductions
+		totals.TaxWithheld += p.TaxWithheld
+		totals.TakeHomePay += p.TakeHomePay
+	}
+	return totals, nil
+}
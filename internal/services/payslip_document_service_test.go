@@ -0,0 +1,88 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"testing"
+	"time"
+)
+
+func TestLoadPayslipDocumentAddsUpYearToDate(t *testing.T) {
+	cleanDB()
+	employee := models.Employee{Username: "ytd", Salary: money.FromUnits(10000000)}
+	testDB.Create(&employee)
+
+	// December of the previous year, two months of this year and a voided
+	// payslip; only the two months count.
+	months := []struct {
+		start, end time.Time
+		voided     bool
+	}{
+		{time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), false},
+		{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), false},
+		{time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), true},
+		{time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), false},
+	}
+	var last models.PayrollPeriod
+	for _, m := range months {
+		period := models.PayrollPeriod{StartDate: m.start, EndDate: m.end, IsRun: true}
+		testDB.Create(&period)
+		payslip := models.Payslip{EmployeeID: employee.ID, PayrollPeriodID: period.ID, Currency: "IDR",
+			GrossEarnings: money.FromUnits(10000000), TaxWithheld: money.FromUnits(500000),
+			TotalDeductions: money.FromUnits(800000), TakeHomePay: money.FromUnits(9200000)}
+		if m.voided {
+			now := time.Now()
+			payslip.VoidedAt = &now
+		}
+		testDB.Create(&payslip)
+		last = period
+	}
+
+	doc, err := LoadPayslipDocument(employee.ID, last.ID)
+	if err != nil {
+		t.Fatalf("Expected the document to load, but got %v", err)
+	}
+	want := YearToDateTotals{Year: 2025, Payslips: 2, GrossEarnings: money.FromUnits(20000000), TaxWithheld: money.FromUnits(1000000),
+		TotalDeductions: money.FromUnits(1600000), TakeHomePay: money.FromUnits(18400000)}
+	if doc.YearToDate != want {
+		t.Errorf("Expected year-to-date totals %+v, but got %+v", want, doc.YearToDate)
+	}
+	if name := doc.FileName("pdf"); name != "payslip-ytd-2025-02-01_2025-02-28.pdf" {
+		t.Errorf("Expected the file to be named by username and period, but got %q", name)
+	}
+
+	if _, err := LoadPayslipDocument(employee.ID, 9999); !errors.Is(err, ErrPayslipNotFound) {
+		t.Errorf("Expected ErrPayslipNotFound, but got %v", err)
+	}
+}
+
+func TestRenderPayslipPDFBreaksLongPayslipsIntoPages(t *testing.T) {
+	doc := PayslipDocument{
+		Company:  CompanyProfile{Name: "Example Corp", Address: "Jakarta"},
+		Employee: models.Employee{Username: "jdoe"},
+		Period: models.PayrollPeriod{
+			StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+		},
+		Payslip: models.Payslip{Currency: "IDR", GrossEarnings: money.FromUnits(6000000), TakeHomePay: money.FromUnits(6000000)},
+	}
+	for i := 0; i < 60; i++ {
+		doc.Payslip.LineItems = append(doc.Payslip.LineItems, models.PayslipLineItem{
+			Code: "ALLOWANCE", Description: fmt.Sprintf("Allowance %d", i), Type: models.LineItemEarning,
+			Quantity: 1, Rate: 100000, Amount: money.FromUnits(100000),
+		})
+	}
+
+	var out bytes.Buffer
+	if err := RenderPayslipPDF(doc, &out); err != nil {
+		t.Fatalf("Expected the payslip to render, but got %v", err)
+	}
+	for _, expected := range []string{"%PDF-1.4", "/Count 2", "(Example Corp) Tj", "(Allowance 59) Tj", "(IDR 6,000,000.00) Tj", "(Page 2 of 2) Tj"} {
+		if !bytes.Contains(out.Bytes(), []byte(expected)) {
+			t.Errorf("Expected the PDF to contain %q", expected)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PayslipManifestFile is the name of the manifest in payslip exports.
+const PayslipManifestFile = "manifest.csv"
+
+// PayslipExport is the ZIP archive of the PDF payslips of a payroll period.
+type PayslipExport struct {
+	Period    models.PayrollPeriod
+	employees []uint // In username order
+}
+
+// payslipManifestEntry is a line of the export's manifest.
+type payslipManifestEntry struct {
+	file       string
+	employeeID uint
+	username   string
+	size       int64
+	sha256     string
+	err        string // Why the employee's payslip is missing from the archive
+}
+
+// PreparePayslipExport checks that the period's payroll has been run and
+// lists the employees with a payslip for it. It returns ErrPeriodNotFound or
+// ErrPayrollNotRun before anything is written.
+func PreparePayslipExport(periodID uint) (PayslipExport, error) {
+	var export PayslipExport
+	if err := database.DB.First(&export.Period, periodID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return export, ErrPeriodNotFound
+	} else if err != nil {
+		return export, err
+	}
+	if !export.Period.IsRun {
+		return export, ErrPayrollNotRun
+	}
+	err := database.DB.Model(&models.Payslip{}).
+		Joins("JOIN employees ON employees.id = payslips.employee_id").
+		Where("payslips.payroll_period_id = ? AND payslips.voided_at IS NULL", periodID).
+		Order("employees.username").
+		Pluck("payslips.employee_id", &export.employees).Error
+	return export, err
+}
+
+// FileName names the archive by period, e.g. "payslips-2025-06-01_2025-06-30.zip".
+func (e PayslipExport) FileName() string {
+	return fmt.Sprintf("payslips-%s_%s.zip", e.Period.StartDate.Format("2006-01-02"), e.Period.EndDate.Format("2006-01-02"))
+}
+
+// Write streams the archive: each employee's PDF payslip, named as they
+// download it, followed by PayslipManifestFile listing every file with its
+// size and SHA-256 checksum. Payslips are rendered one at a time straight into
+// the archive. An employee whose payslip can't be encrypted for lack of the
+// details of their password is listed in the manifest with the reason instead.
+func (e PayslipExport) Write(w io.Writer) error {
+	archive := zip.NewWriter(w)
+	manifest := make([]payslipManifestEntry, 0, len(e.employees))
+	for _, employeeID := range e.employees {
+		doc, err := LoadPayslipDocument(employeeID, e.Period.ID)
+		if err != nil {
+			return fmt.Errorf("loading payslip of employee ID %d: %w", employeeID, err)
+		}
+		entry := payslipManifestEntry{file: doc.FileName("pdf"), employeeID: employeeID, username: doc.Employee.Username}
+		if errors.Is(doc.passwordErr, ErrNoDocumentPassword) {
+			entry.file, entry.err = "", doc.passwordErr.Error()
+			manifest = append(manifest, entry)
+			continue
+		}
+
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: entry.file, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		counter := &byteCounter{}
+		if err := RenderPayslipPDF(doc, io.MultiWriter(f, hash, counter)); err != nil {
+			return fmt.Errorf("rendering payslip of employee ID %d: %w", employeeID, err)
+		}
+		entry.size, entry.sha256 = counter.n, hex.EncodeToString(hash.Sum(nil))
+		manifest = append(manifest, entry)
+	}
+
+	f, err := archive.CreateHeader(&zip.FileHeader{Name: PayslipManifestFile, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	out := csv.NewWriter(f)
+	out.Write([]string{"file", "employee_id", "username", "bytes", "sha256", "error"})
+	for _, m := range manifest {
+		size := ""
+		if m.file != "" {
+			size = strconv.FormatInt(m.size, 10)
+		}
+		out.Write([]string{m.file, strconv.FormatUint(uint64(m.employeeID), 10), m.username, size, m.sha256, m.err})
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// byteCounter counts the bytes written to it.
+type byteCounter struct{ n int64 }
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
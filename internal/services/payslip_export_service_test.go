@@ -0,0 +1,88 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"io"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPayslipExportWritesPayslipsAndManifest(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)}
+	testDB.Create(&period)
+	if _, err := PreparePayslipExport(period.ID); !errors.Is(err, ErrPayrollNotRun) {
+		t.Errorf("Expected ErrPayrollNotRun before the payroll is run, but got %v", err)
+	}
+	if _, err := PreparePayslipExport(9999); !errors.Is(err, ErrPeriodNotFound) {
+		t.Errorf("Expected ErrPeriodNotFound, but got %v", err)
+	}
+	testDB.Model(&period).Update("is_run", true)
+
+	// Two payslips, a voided one and an employee whose password can't be derived.
+	testDB.Create(&models.PayslipProtection{Group: "protected", Scheme: models.ProtectionRule, PasswordRule: "{employeeNumber}"})
+	for _, e := range []struct {
+		username, group string
+		voided          bool
+	}{{"bob", "", false}, {"alice", "", false}, {"carol", "", true}, {"dave", "protected", false}} {
+		employee := models.Employee{Username: e.username, Group: e.group, Salary: money.FromUnits(10000000)}
+		testDB.Create(&employee)
+		payslip := models.Payslip{EmployeeID: employee.ID, PayrollPeriodID: period.ID, Currency: "IDR", TakeHomePay: money.FromUnits(10000000)}
+		if e.voided {
+			now := time.Now()
+			payslip.VoidedAt = &now
+		}
+		testDB.Create(&payslip)
+	}
+
+	export, err := PreparePayslipExport(period.ID)
+	if err != nil {
+		t.Fatalf("Expected the export to be prepared, but got %v", err)
+	}
+	if name := export.FileName(); name != "payslips-2025-06-01_2025-06-30.zip" {
+		t.Errorf("Expected the archive to be named by period, but got %q", name)
+	}
+	var out bytes.Buffer
+	if err := export.Write(&out); err != nil {
+		t.Fatalf("Expected the export to be written, but got %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("Expected a ZIP archive, but got %v", err)
+	}
+	files := map[string][]byte{}
+	var names []string
+	for _, f := range archive.File {
+		r, _ := f.Open()
+		files[f.Name], _ = io.ReadAll(r)
+		names = append(names, f.Name)
+	}
+	want := []string{"payslip-alice-2025-06-01_2025-06-30.pdf", "payslip-bob-2025-06-01_2025-06-30.pdf", PayslipManifestFile}
+	if len(names) != len(want) || names[0] != want[0] || names[1] != want[1] || names[2] != want[2] {
+		t.Fatalf("Expected files %v, but got %v", want, names)
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(files[PayslipManifestFile])).ReadAll()
+	if err != nil || len(rows) != 4 {
+		t.Fatalf("Expected a header and three manifest rows, but got %v (%v)", rows, err)
+	}
+	for _, row := range rows[1:3] {
+		data := files[row[0]]
+		sum := sha256.Sum256(data)
+		if !bytes.HasPrefix(data, []byte("%PDF-")) || row[3] != strconv.Itoa(len(data)) || row[4] != hex.EncodeToString(sum[:]) {
+			t.Errorf("Expected the manifest to list the size and checksum of %s, but got %v", row[0], row)
+		}
+	}
+	if rows[3][0] != "" || rows[3][2] != "dave" || rows[3][5] == "" {
+		t.Errorf("Expected the payslip without a password to be listed with the reason, but got %v", rows[3])
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"payslip-generator/internal/pdf"
+	"strconv"
+)
+
+// Layout of the PDF payslip, in points.
+const (
+	pdfMargin    = 50.0
+	pdfRowHeight = 16.0
+	pdfBodySize  = 9.5
+	pdfQtyRight  = 330.0 // Right edge of the quantity column
+	pdfRateRight = 430.0 // Right edge of the rate column
+	pdfFooterTop = 70.0  // Space kept free at the bottom of every page
+)
+
+// pdfLayout draws the payslip top to bottom, starting new pages as needed.
+type pdfLayout struct {
+	doc  *pdf.Document
+	page *pdf.Page
+	y    float64 // Top of the next row
+}
+
+// right is the right edge of the printable area.
+func (l *pdfLayout) right() float64 { return l.doc.Width() - pdfMargin }
+
+// need starts a new page unless height points fit on the current one.
+func (l *pdfLayout) need(height float64) {
+	if l.y+height > l.doc.Height()-pdfFooterTop {
+		l.page = l.doc.AddPage()
+		l.y = pdfMargin
+	}
+}
+
+// heading draws a section title over a shaded band with optional column titles.
+func (l *pdfLayout) heading(title string, columns bool) {
+	l.need(3 * pdfRowHeight)
+	l.y += 8
+	l.page.FillRect(pdfMargin, l.y, l.right()-pdfMargin, pdfRowHeight+2, 0.92)
+	l.page.Text(pdfMargin+4, l.y+12, pdf.HelveticaBold, 10, title)
+	if columns {
+		l.page.TextRight(pdfQtyRight, l.y+12, pdf.HelveticaBold, 8, "Quantity")
+		l.page.TextRight(pdfRateRight, l.y+12, pdf.HelveticaBold, 8, "Rate")
+		l.page.TextRight(l.right()-4, l.y+12, pdf.HelveticaBold, 8, "Amount")
+	}
+	l.y += pdfRowHeight + 4
+}
+
+// row draws a line item with its quantity and rate, if any, and amount.
+func (l *pdfLayout) row(description, quantity, rate, amount string) {
+	l.need(pdfRowHeight)
+	baseline := l.y + 11
+	width := pdfQtyRight - 60 - pdfMargin
+	if quantity == "" && rate == "" {
+		width = l.right() - 120 - pdfMargin
+	}
+	l.page.Text(pdfMargin+4, baseline, pdf.Helvetica, pdfBodySize, pdf.Truncate(pdf.Helvetica, pdfBodySize, width, description))
+	l.page.TextRight(pdfQtyRight, baseline, pdf.Helvetica, pdfBodySize, quantity)
+	l.page.TextRight(pdfRateRight, baseline, pdf.Helvetica, pdfBodySize, rate)
+	l.page.TextRight(l.right()-4, baseline, pdf.Helvetica, pdfBodySize, amount)
+	l.y += pdfRowHeight
+}
+
+// total draws a bold total under a rule.
+func (l *pdfLayout) total(label, amount string) {
+	l.need(pdfRowHeight + 4)
+	l.page.Line(pdfMargin, l.y+2, l.right(), l.y+2, 0.5, 0.6)
+	l.page.Text(pdfMargin+4, l.y+14, pdf.HelveticaBold, pdfBodySize, label)
+	l.page.TextRight(l.right()-4, l.y+14, pdf.HelveticaBold, pdfBodySize, amount)
+	l.y += pdfRowHeight + 4
+}
+
+// RenderPayslipPDF writes the payslip as an A4 PDF document: the company
+// header, the employee and period, the itemized earnings and deductions, the
+// take-home pay, any employer contributions and the year-to-date totals.
+// The document is encrypted with the employee's document password if their
+// group's payslips are protected.
+func RenderPayslipPDF(d PayslipDocument, w io.Writer) error {
+	if d.passwordErr != nil {
+		return d.passwordErr
+	}
+	doc := pdf.New(pdf.A4Width, pdf.A4Height)
+	if d.password != "" {
+		doc.Encrypt(d.password, "")
+	}
+	doc.SetInfo("Title", fmt.Sprintf("Payslip %s %s", d.Employee.Username, periodLabel(d.Period)))
+	doc.SetInfo("Author", d.Company.Name)
+	doc.SetInfo("Producer", "payslip-generator")
+	l := &pdfLayout{doc: doc, page: doc.AddPage()}
+	p := d.Payslip
+
+	// Company header
+	l.page.FillRect(0, 0, doc.Width(), 90, 0.93)
+	l.page.Text(pdfMargin, 45, pdf.HelveticaBold, 18, d.Company.Name)
+	l.page.Text(pdfMargin, 62, pdf.Helvetica, 9, d.Company.Address)
+	l.page.TextRight(l.right(), 45, pdf.HelveticaBold, 16, "PAYSLIP")
+	l.page.TextRight(l.right(), 62, pdf.Helvetica, 9, periodLabel(d.Period))
+	l.page.FillRect(0, 90, doc.Width(), 3, 0.3)
+
+	// Employee and period
+	l.y = 115
+	info := [][2]string{
+		{"Employee", d.Employee.Username},
+		{"Employee ID", strconv.FormatUint(uint64(d.Employee.ID), 10)},
+		{"Pay period", periodLabel(d.Period)},
+		{"Currency", p.Currency},
+		{"Days attended", fmt.Sprintf("%d of %d", p.DaysAttended, p.WorkingDays)},
+		{"Leave", fmt.Sprintf("%d paid, %d unpaid", p.PaidLeaveDays, p.UnpaidLeaveDays)},
+		{"Payslip no.", strconv.FormatUint(uint64(p.ID), 10)},
+		{"Issued", p.CreatedAt.Format("2 Jan 2006")},
+	}
+	for i := 0; i < len(info); i += 2 {
+		for col, field := range info[i : i+2] {
+			x := pdfMargin + float64(col)*250
+			l.page.Text(x, l.y, pdf.Helvetica, 8, field[0])
+			l.page.Text(x+80, l.y, pdf.HelveticaBold, pdfBodySize, field[1])
+		}
+		l.y += 14
+	}
+
+	// Earnings and deductions
+	earnings, deductions, contributions := lineItemsByType(p.LineItems)
+
+	l.heading("Earnings", true)
+	for _, item := range earnings {
+		l.row(item.Description, quantityLabel(item), rateLabel(item), item.Amount.Grouped())
+	}
+	l.total("Total earnings", p.GrossEarnings.Grouped())
+
+	l.heading("Deductions", true)
+	if len(deductions) == 0 {
+		l.row("No deductions", "", "", money.Zero.Grouped())
+	}
+	for _, item := range deductions {
+		l.row(item.Description, quantityLabel(item), rateLabel(item), item.Amount.Grouped())
+	}
+	l.total("Total deductions", p.TotalDeductions.Grouped())
+
+	// Take-home pay
+	l.need(34)
+	l.y += 10
+	l.page.FillRect(pdfMargin, l.y, l.right()-pdfMargin, 24, 0.85)
+	l.page.Text(pdfMargin+4, l.y+16, pdf.HelveticaBold, 12, "Take-home pay")
+	l.page.TextRight(l.right()-4, l.y+16, pdf.HelveticaBold, 12, p.Currency+" "+p.TakeHomePay.Grouped())
+	l.y += 24
+
+	if len(contributions) > 0 {
+		l.heading("Employer contributions (not deducted from your pay)", false)
+		for _, item := range contributions {
+			l.row(item.Description, "", "", item.Amount.Grouped())
+		}
+		l.total("Total employer contributions", p.EmployerContributions.Grouped())
+	}
+
+	ytd := d.YearToDate
+	l.heading(fmt.Sprintf("Year to date %d (%d payslips)", ytd.Year, ytd.Payslips), false)
+	l.row("Gross earnings", "", "", ytd.GrossEarnings.Grouped())
+	l.row("Income tax withheld", "", "", ytd.TaxWithheld.Grouped())
+	l.row("Total deductions", "", "", ytd.TotalDeductions.Grouped())
+	l.total("Take-home pay", ytd.TakeHomePay.Grouped())
+
+	// Footer on every page, now that the number of pages is known.
+	for i := 1; i <= doc.Pages(); i++ {
+		page := doc.Page(i)
+		footer := fmt.Sprintf("%s - payslip for %s, %s", d.Company.Name, d.Employee.Username, periodLabel(d.Period))
+		page.Line(pdfMargin, doc.Height()-45, doc.Width()-pdfMargin, doc.Height()-45, 0.5, 0.6)
+		page.Text(pdfMargin, doc.Height()-32, pdf.Helvetica, 7.5, footer)
+		page.TextRight(doc.Width()-pdfMargin, doc.Height()-32, pdf.Helvetica, 7.5, fmt.Sprintf("Page %d of %d", i, doc.Pages()))
+	}
+
+	_, err := doc.WriteTo(w)
+	return err
+}
+
+// periodLabel formats a payroll period such as "1 Jun 2025 - 30 Jun 2025".
+func periodLabel(period models.PayrollPeriod) string {
+	return period.StartDate.Format("2 Jan 2006") + " - " + period.EndDate.Format("2 Jan 2006")
+}
+
+// quantityLabel prints a line item's quantity, or nothing if it has none.
+// Large quantities are amounts, such as the base of a percentage, and are
+// grouped in thousands.
+func quantityLabel(item models.PayslipLineItem) string {
+	switch {
+	case item.Quantity == 0:
+		return ""
+	case item.Quantity >= 1000:
+		return money.FromFloat(item.Quantity).Grouped()
+	}
+	return strconv.FormatFloat(math.Round(item.Quantity*100)/100, 'f', -1, 64)
+}
+
+// rateLabel prints a line item's rate, or nothing if it has none. Rates below
+// 1, such as percentages and some exchange rates, are printed in full.
+func rateLabel(item models.PayslipLineItem) string {
+	switch {
+	case item.Rate == 0:
+		return ""
+	case item.Rate < 1:
+		return strconv.FormatFloat(math.Round(item.Rate*1e6)/1e6, 'f', -1, 64)
+	}
+	return money.FromFloat(item.Rate).Grouped()
+}
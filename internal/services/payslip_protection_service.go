@@ -0,0 +1,259 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidPayslipProtection is returned for an unknown scheme or a password rule that can't derive passwords.
+	ErrInvalidPayslipProtection = errors.New("invalid payslip protection")
+	// ErrNoDocumentPassword is returned when an employee's payslip documents
+	// must be encrypted but the details their password is derived from are missing.
+	ErrNoDocumentPassword = errors.New("no document password")
+	// ErrInvalidDocumentPassword is returned for a document password that is too short.
+	ErrInvalidDocumentPassword = errors.New("invalid document password")
+	// ErrDocumentPasswordNotAllowed is returned when an employee sets a
+	// document password while their group's scheme doesn't use it.
+	ErrDocumentPasswordNotAllowed = errors.New("document passwords are not set by employees")
+)
+
+// MinDocumentPasswordLength is the shortest password an employee may set for their payslip documents.
+const MinDocumentPasswordLength = 8
+
+// passwordRuleToken matches the placeholders of a password rule, e.g. "{dob}".
+var passwordRuleToken = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// passwordRuleValues returns the value of each password rule placeholder for
+// an employee, or "" if the employee lacks it.
+func passwordRuleValues(e models.Employee) map[string]string {
+	values := map[string]string{
+		"employeeNumber": e.EmployeeNumber,
+		"username":       e.Username,
+		"employeeId":     strconv.FormatUint(uint64(e.ID), 10),
+		"dob":            "",
+	}
+	if e.DateOfBirth != nil {
+		values["dob"] = e.DateOfBirth.Format("02012006")
+	}
+	return values
+}
+
+// ValidatePayslipProtection checks the scheme and, for the schemes that use
+// one, that the password rule has at least one placeholder and only known ones.
+func ValidatePayslipProtection(p models.PayslipProtection) error {
+	switch p.Scheme {
+	case models.ProtectionNone:
+		return nil
+	case models.ProtectionRule, models.ProtectionEmployee:
+	default:
+		return fmt.Errorf("%w: unknown scheme %q", ErrInvalidPayslipProtection, p.Scheme)
+	}
+	tokens := passwordRuleToken.FindAllStringSubmatch(p.PasswordRule, -1)
+	if len(tokens) == 0 {
+		return fmt.Errorf("%w: the password rule must contain a placeholder such as {dob}", ErrInvalidPayslipProtection)
+	}
+	known := passwordRuleValues(models.Employee{})
+	for _, token := range tokens {
+		if _, ok := known[token[1]]; !ok {
+			return fmt.Errorf("%w: unknown placeholder %s", ErrInvalidPayslipProtection, token[0])
+		}
+	}
+	return nil
+}
+
+// derivePassword replaces the placeholders of a password rule with the
+// employee's details. It returns ErrNoDocumentPassword if one is missing.
+func derivePassword(rule string, e models.Employee) (string, error) {
+	values := passwordRuleValues(e)
+	var missing string
+	password := passwordRuleToken.ReplaceAllStringFunc(rule, func(token string) string {
+		value := values[token[1:len(token)-1]]
+		if value == "" && missing == "" {
+			missing = token
+		}
+		return value
+	})
+	if missing != "" {
+		return "", fmt.Errorf("%w: employee ID %d has no value for %s", ErrNoDocumentPassword, e.ID, missing)
+	}
+	return password, nil
+}
+
+// PayslipProtectionFor returns the protection of an employee group: its own,
+// else the one without a group, else no protection.
+func PayslipProtectionFor(group string) (models.PayslipProtection, error) {
+	groups := []string{group}
+	if group != "" {
+		groups = append(groups, "")
+	}
+	for _, g := range groups {
+		var protection models.PayslipProtection
+		err := database.DB.Where("\"group\" = ?", g).First(&protection).Error
+		if err == nil {
+			return protection, nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return protection, err
+		}
+	}
+	return models.PayslipProtection{Group: group, Scheme: models.ProtectionNone}, nil
+}
+
+// DocumentPassword returns the password that opens an employee's payslip
+// documents, or "" if their group's documents are not encrypted.
+func DocumentPassword(employee models.Employee) (string, error) {
+	protection, err := PayslipProtectionFor(employee.Group)
+	if err != nil {
+		return "", err
+	}
+	switch protection.Scheme {
+	case models.ProtectionRule:
+		return derivePassword(protection.PasswordRule, employee)
+	case models.ProtectionEmployee:
+		if employee.DocumentPassword != "" {
+			return openDocumentPassword(employee.DocumentPassword)
+		}
+		return derivePassword(protection.PasswordRule, employee)
+	}
+	return "", nil
+}
+
+// SetEmployeeDocumentPassword stores the password an employee chose for their
+// payslip documents, or clears it for "", so that the password rule applies again.
+func SetEmployeeDocumentPassword(employeeID uint, password string) error {
+	var employee models.Employee
+	if err := database.DB.First(&employee, employeeID).Error; err != nil {
+		return err
+	}
+	sealed := ""
+	if password != "" {
+		if utf8.RuneCountInString(password) < MinDocumentPasswordLength {
+			return fmt.Errorf("%w: it must be at least %d characters long", ErrInvalidDocumentPassword, MinDocumentPasswordLength)
+		}
+		protection, err := PayslipProtectionFor(employee.Group)
+		if err != nil {
+			return err
+		}
+		if protection.Scheme != models.ProtectionEmployee {
+			return ErrDocumentPasswordNotAllowed
+		}
+		if sealed, err = sealDocumentPassword(password); err != nil {
+			return err
+		}
+	}
+	return database.DB.Model(&employee).Update("document_password", sealed).Error
+}
+
+// CheckDocumentPasswordKey checks at startup that DOCUMENT_PASSWORD_KEY is set,
+// and that it is not JWT_SECRET: one leaked secret must not both sign tokens
+// and reveal the passwords employees chose.
+func CheckDocumentPasswordKey() error {
+	key := os.Getenv("DOCUMENT_PASSWORD_KEY")
+	if key == "" {
+		return errors.New("DOCUMENT_PASSWORD_KEY is not configured")
+	}
+	if key == os.Getenv("JWT_SECRET") {
+		return errors.New("DOCUMENT_PASSWORD_KEY must differ from JWT_SECRET")
+	}
+	return nil
+}
+
+// documentPasswordCipher returns the AES-GCM cipher that seals the passwords
+// employees set, with a key derived from secret. Unlike login passwords they
+// can't be hashed, as documents are encrypted with them.
+func documentPasswordCipher(secret string) (cipher.AEAD, error) {
+	if secret == "" {
+		return nil, errors.New("DOCUMENT_PASSWORD_KEY is not configured")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ResealDocumentPasswords re-seals with DOCUMENT_PASSWORD_KEY the stored
+// passwords that only DOCUMENT_PASSWORD_KEY_PREVIOUS opens, after the key was
+// rotated. It returns the number of passwords re-sealed.
+func ResealDocumentPasswords() (int, error) {
+	previous := os.Getenv("DOCUMENT_PASSWORD_KEY_PREVIOUS")
+	if previous == "" {
+		return 0, nil
+	}
+	var employees []models.Employee
+	if err := database.DB.Select("id", "document_password").Where("document_password <> ?", "").Find(&employees).Error; err != nil {
+		return 0, err
+	}
+	resealed := 0
+	for _, e := range employees {
+		if _, err := openSealed(os.Getenv("DOCUMENT_PASSWORD_KEY"), e.DocumentPassword); err == nil {
+			continue
+		}
+		password, err := openSealed(previous, e.DocumentPassword)
+		if err != nil {
+			return resealed, fmt.Errorf("employee ID %d: %w", e.ID, err)
+		}
+		sealed, err := sealDocumentPassword(password)
+		if err != nil {
+			return resealed, err
+		}
+		if err := database.DB.Model(&e).Update("document_password", sealed).Error; err != nil {
+			return resealed, err
+		}
+		resealed++
+	}
+	return resealed, nil
+}
+
+func sealDocumentPassword(password string) (string, error) {
+	aead, err := documentPasswordCipher(os.Getenv("DOCUMENT_PASSWORD_KEY"))
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(password), nil)), nil
+}
+
+// openDocumentPassword opens a password sealed with DOCUMENT_PASSWORD_KEY or,
+// until ResealDocumentPasswords has run, DOCUMENT_PASSWORD_KEY_PREVIOUS.
+func openDocumentPassword(sealed string) (string, error) {
+	password, err := openSealed(os.Getenv("DOCUMENT_PASSWORD_KEY"), sealed)
+	if previous := os.Getenv("DOCUMENT_PASSWORD_KEY_PREVIOUS"); err != nil && previous != "" {
+		password, err = openSealed(previous, sealed)
+	}
+	return password, err
+}
+
+func openSealed(secret, sealed string) (string, error) {
+	aead, err := documentPasswordCipher(secret)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errors.New("malformed document password")
+	}
+	password, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("opening document password: %w", err)
+	}
+	return string(password), nil
+}
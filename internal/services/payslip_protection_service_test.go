@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"testing"
+	"time"
+)
+
+func TestValidatePayslipProtection(t *testing.T) {
+	valid := []models.PayslipProtection{
+		{Scheme: models.ProtectionNone},
+		{Scheme: models.ProtectionRule, PasswordRule: "{dob}{employeeNumber}"},
+		{Scheme: models.ProtectionEmployee, PasswordRule: "PAY-{employeeId}"},
+	}
+	for _, p := range valid {
+		if err := ValidatePayslipProtection(p); err != nil {
+			t.Errorf("Expected %+v to be valid, but got %v", p, err)
+		}
+	}
+	invalid := []models.PayslipProtection{
+		{Scheme: "zip"},
+		{Scheme: models.ProtectionRule, PasswordRule: "secret"},
+		{Scheme: models.ProtectionRule, PasswordRule: "{dob}{salary}"},
+		{Scheme: models.ProtectionEmployee},
+	}
+	for _, p := range invalid {
+		if err := ValidatePayslipProtection(p); !errors.Is(err, ErrInvalidPayslipProtection) {
+			t.Errorf("Expected %+v to be refused, but got %v", p, err)
+		}
+	}
+}
+
+func TestDocumentPasswordFollowsTheGroupScheme(t *testing.T) {
+	cleanDB()
+	t.Setenv("DOCUMENT_PASSWORD_KEY", "test-document-key")
+	testDB.Create(&models.PayslipProtection{Group: "", Scheme: models.ProtectionRule, PasswordRule: "{dob}{employeeNumber}"})
+	testDB.Create(&models.PayslipProtection{Group: "sg", Scheme: models.ProtectionEmployee, PasswordRule: "{dob}"})
+	testDB.Create(&models.PayslipProtection{Group: "us", Scheme: models.ProtectionNone})
+
+	dob := time.Date(1990, 2, 1, 0, 0, 0, 0, time.UTC)
+	password := func(e models.Employee) string {
+		t.Helper()
+		testDB.First(&e, e.ID)
+		p, err := DocumentPassword(e)
+		if err != nil {
+			t.Fatalf("Expected a document password, but got %v", err)
+		}
+		return p
+	}
+
+	indonesia := models.Employee{Username: "id", Group: "id", EmployeeNumber: "E042", DateOfBirth: &dob}
+	testDB.Create(&indonesia)
+	if p := password(indonesia); p != "01021990E042" {
+		t.Errorf("Expected the rule of the employees without a group, but got %q", p)
+	}
+	if p := password(models.Employee{Username: "us", Group: "us"}); p != "" {
+		t.Errorf("Expected no password for an unprotected group, but got %q", p)
+	}
+	if _, err := DocumentPassword(models.Employee{Username: "nodob", EmployeeNumber: "E043"}); !errors.Is(err, ErrNoDocumentPassword) {
+		t.Errorf("Expected ErrNoDocumentPassword without a date of birth, but got %v", err)
+	}
+
+	// Employees of a group with the employee scheme may set their own password.
+	singapore := models.Employee{Username: "sg", Group: "sg", DateOfBirth: &dob}
+	testDB.Create(&singapore)
+	if p := password(singapore); p != "01021990" {
+		t.Errorf("Expected the rule until the employee sets a password, but got %q", p)
+	}
+	if err := SetEmployeeDocumentPassword(singapore.ID, "short"); !errors.Is(err, ErrInvalidDocumentPassword) {
+		t.Errorf("Expected a short password to be refused, but got %v", err)
+	}
+	if err := SetEmployeeDocumentPassword(singapore.ID, "correct horse"); err != nil {
+		t.Fatalf("Expected the password to be set, but got %v", err)
+	}
+	var stored models.Employee
+	testDB.First(&stored, singapore.ID)
+	if stored.DocumentPassword == "" || bytes.Contains([]byte(stored.DocumentPassword), []byte("correct horse")) {
+		t.Errorf("Expected the password to be stored sealed, but got %q", stored.DocumentPassword)
+	}
+	if p := password(singapore); p != "correct horse" {
+		t.Errorf("Expected the employee's password, but got %q", p)
+	}
+	if err := SetEmployeeDocumentPassword(singapore.ID, ""); err != nil {
+		t.Fatalf("Expected the password to be cleared, but got %v", err)
+	}
+	if p := password(singapore); p != "01021990" {
+		t.Errorf("Expected the rule once the password is cleared, but got %q", p)
+	}
+	if err := SetEmployeeDocumentPassword(indonesia.ID, "correct horse"); !errors.Is(err, ErrDocumentPasswordNotAllowed) {
+		t.Errorf("Expected the password to be refused under the rule scheme, but got %v", err)
+	}
+}
+
+func TestDocumentPasswordKeyRotation(t *testing.T) {
+	cleanDB()
+	t.Setenv("JWT_SECRET", "test-jwt-secret")
+	t.Setenv("DOCUMENT_PASSWORD_KEY", "")
+	if err := CheckDocumentPasswordKey(); err == nil {
+		t.Error("Expected a missing key to be refused")
+	}
+	t.Setenv("DOCUMENT_PASSWORD_KEY", "test-jwt-secret")
+	if err := CheckDocumentPasswordKey(); err == nil {
+		t.Error("Expected the JWT secret to be refused as the document password key")
+	}
+
+	t.Setenv("DOCUMENT_PASSWORD_KEY", "old-document-key")
+	testDB.Create(&models.PayslipProtection{Scheme: models.ProtectionEmployee, PasswordRule: "{employeeNumber}"})
+	employee := models.Employee{Username: "rotating", EmployeeNumber: "E042"}
+	testDB.Create(&employee)
+	if err := SetEmployeeDocumentPassword(employee.ID, "correct horse"); err != nil {
+		t.Fatalf("Expected the password to be set, but got %v", err)
+	}
+
+	// The previous key keeps the password readable until it has been re-sealed.
+	t.Setenv("DOCUMENT_PASSWORD_KEY", "new-document-key")
+	t.Setenv("DOCUMENT_PASSWORD_KEY_PREVIOUS", "old-document-key")
+	testDB.First(&employee, employee.ID)
+	if p, err := DocumentPassword(employee); err != nil || p != "correct horse" {
+		t.Errorf("Expected the password to open with the previous key, but got %q, %v", p, err)
+	}
+	if n, err := ResealDocumentPasswords(); err != nil || n != 1 {
+		t.Fatalf("Expected one password to be re-sealed, but got %d, %v", n, err)
+	}
+	if n, err := ResealDocumentPasswords(); err != nil || n != 0 {
+		t.Errorf("Expected nothing left to re-seal, but got %d, %v", n, err)
+	}
+
+	t.Setenv("DOCUMENT_PASSWORD_KEY_PREVIOUS", "")
+	testDB.First(&employee, employee.ID)
+	if p, err := DocumentPassword(employee); err != nil || p != "correct horse" {
+		t.Errorf("Expected the password to open with the new key alone, but got %q, %v", p, err)
+	}
+}
+
+func TestLoadPayslipDocumentEncryptsProtectedPDFs(t *testing.T) {
+	cleanDB()
+	testDB.Create(&models.PayslipProtection{Scheme: models.ProtectionRule, PasswordRule: "{employeeNumber}"})
+	employee := models.Employee{Username: "protected", Salary: money.FromUnits(10000000), EmployeeNumber: "E042"}
+	testDB.Create(&employee)
+	period := models.PayrollPeriod{StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC), IsRun: true}
+	testDB.Create(&period)
+	testDB.Create(&models.Payslip{EmployeeID: employee.ID, PayrollPeriodID: period.ID, Currency: "IDR", TakeHomePay: money.FromUnits(10000000)})
+
+	doc, err := LoadPayslipDocument(employee.ID, period.ID)
+	if err != nil {
+		t.Fatalf("Expected the document to load, but got %v", err)
+	}
+	var out bytes.Buffer
+	if err := RenderPayslipPDF(doc, &out); err != nil {
+		t.Fatalf("Expected the payslip to render, but got %v", err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("/Filter /Standard /V 5 /R 6")) || bytes.Contains(out.Bytes(), []byte("(protected)")) {
+		t.Errorf("Expected the PDF to be encrypted")
+	}
+
+	// Without the details the password is derived from, the PDF isn't rendered unprotected.
+	testDB.Model(&employee).Update("employee_number", "")
+	doc, _ = LoadPayslipDocument(employee.ID, period.ID)
+	if err := RenderPayslipPDF(doc, &out); !errors.Is(err, ErrNoDocumentPassword) {
+		t.Errorf("Expected ErrNoDocumentPassword, but got %v", err)
+	}
+}
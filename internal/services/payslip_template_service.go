@@ -0,0 +1,245 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidPayslipTemplate is returned for a template that does not parse or
+// fails to render the sample payslip.
+var ErrInvalidPayslipTemplate = errors.New("invalid payslip template")
+
+// MaxPayslipTemplateSize is the largest template source accepted, in bytes.
+const MaxPayslipTemplateSize = 64 << 10
+
+// PayslipTemplateData is what payslip templates are executed with: the
+// document, with its line items split by type.
+type PayslipTemplateData struct {
+	PayslipDocument
+	Earnings              []models.PayslipLineItem
+	Deductions            []models.PayslipLineItem
+	EmployerContributions []models.PayslipLineItem
+}
+
+// payslipTemplateFuncs are the functions available to payslip templates.
+var payslipTemplateFuncs = template.FuncMap{
+	"money":    func(a money.Amount) string { return a.Grouped() },
+	"date":     func(layout string, t time.Time) string { return t.Format(layout) },
+	"period":   periodLabel,
+	"quantity": quantityLabel,
+	"rate":     rateLabel,
+}
+
+// DefaultPayslipTemplate is used for employees whose group has no template
+// and when no template has been created without a group.
+const DefaultPayslipTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Payslip {{.Employee.Username}} {{period .Period}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 13px; color: #222; max-width: 800px; margin: 24px auto; }
+header { display: flex; justify-content: space-between; background: #eee; border-bottom: 3px solid #555; padding: 16px 20px; }
+h1 { margin: 0; font-size: 22px; } h2 { font-size: 15px; background: #eee; padding: 4px 6px; margin: 20px 0 4px; }
+table { width: 100%; border-collapse: collapse; } td, th { padding: 4px 6px; } th { text-align: left; font-size: 11px; }
+.num { text-align: right; } .total td { font-weight: bold; border-top: 1px solid #999; }
+.net { background: #ddd; font-size: 16px; font-weight: bold; padding: 8px 6px; display: flex; justify-content: space-between; margin-top: 12px; }
+</style>
+</head>
+<body>
+<header>
+  <div><h1>{{.Company.Name}}</h1><div>{{.Company.Address}}</div></div>
+  <div class="num"><h1>PAYSLIP</h1><div>{{period .Period}}</div></div>
+</header>
+<table>
+  <tr><th>Employee</th><td>{{.Employee.Username}}</td><th>Employee ID</th><td>{{.Employee.ID}}</td></tr>
+  <tr><th>Pay period</th><td>{{period .Period}}</td><th>Currency</th><td>{{.Payslip.Currency}}</td></tr>
+  <tr><th>Days attended</th><td>{{.Payslip.DaysAttended}} of {{.Payslip.WorkingDays}}</td><th>Leave</th><td>{{.Payslip.PaidLeaveDays}} paid, {{.Payslip.UnpaidLeaveDays}} unpaid</td></tr>
+</table>
+<h2>Earnings</h2>
+<table>
+  <tr><th>Description</th><th class="num">Quantity</th><th class="num">Rate</th><th class="num">Amount</th></tr>
+  {{range .Earnings}}<tr><td>{{.Description}}</td><td class="num">{{quantity .}}</td><td class="num">{{rate .}}</td><td class="num">{{money .Amount}}</td></tr>
+  {{end}}<tr class="total"><td colspan="3">Total earnings</td><td class="num">{{money .Payslip.GrossEarnings}}</td></tr>
+</table>
+<h2>Deductions</h2>
+<table>
+  <tr><th>Description</th><th class="num">Quantity</th><th class="num">Rate</th><th class="num">Amount</th></tr>
+  {{range .Deductions}}<tr><td>{{.Description}}</td><td class="num">{{quantity .}}</td><td class="num">{{rate .}}</td><td class="num">{{money .Amount}}</td></tr>
+  {{end}}<tr class="total"><td colspan="3">Total deductions</td><td class="num">{{money .Payslip.TotalDeductions}}</td></tr>
+</table>
+<div class="net"><span>Take-home pay</span><span>{{.Payslip.Currency}} {{money .Payslip.TakeHomePay}}</span></div>
+{{if .EmployerContributions}}<h2>Employer contributions (not deducted from your pay)</h2>
+<table>
+  {{range .EmployerContributions}}<tr><td>{{.Description}}</td><td class="num">{{money .Amount}}</td></tr>
+  {{end}}<tr class="total"><td>Total employer contributions</td><td class="num">{{money .Payslip.EmployerContributions}}</td></tr>
+</table>
+{{end}}<h2>Year to date {{.YearToDate.Year}} ({{.YearToDate.Payslips}} payslips)</h2>
+<table>
+  <tr><td>Gross earnings</td><td class="num">{{money .YearToDate.GrossEarnings}}</td></tr>
+  <tr><td>Income tax withheld</td><td class="num">{{money .YearToDate.TaxWithheld}}</td></tr>
+  <tr><td>Total deductions</td><td class="num">{{money .YearToDate.TotalDeductions}}</td></tr>
+  <tr class="total"><td>Take-home pay</td><td class="num">{{money .YearToDate.TakeHomePay}}</td></tr>
+</table>
+</body>
+</html>
+`
+
+// lineItemsByType splits a payslip's line items into earnings, deductions and
+// employer contributions, keeping their order.
+func lineItemsByType(items []models.PayslipLineItem) (earnings, deductions, contributions []models.PayslipLineItem) {
+	for _, item := range items {
+		switch item.Type {
+		case models.LineItemEarning:
+			earnings = append(earnings, item)
+		case models.LineItemDeduction:
+			deductions = append(deductions, item)
+		case models.LineItemEmployerContribution:
+			contributions = append(contributions, item)
+		}
+	}
+	return earnings, deductions, contributions
+}
+
+// parsePayslipTemplate parses a template's source with the payslip functions.
+func parsePayslipTemplate(body string) (*template.Template, error) {
+	if len(body) > MaxPayslipTemplateSize {
+		return nil, fmt.Errorf("%w: the template is larger than %d bytes", ErrInvalidPayslipTemplate, MaxPayslipTemplateSize)
+	}
+	tmpl, err := template.New("payslip").Funcs(payslipTemplateFuncs).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayslipTemplate, err)
+	}
+	return tmpl, nil
+}
+
+// RenderPayslipTemplate executes a template's source for a payslip document.
+// Errors in the template are reported as ErrInvalidPayslipTemplate.
+func RenderPayslipTemplate(body string, doc PayslipDocument, w io.Writer) error {
+	tmpl, err := parsePayslipTemplate(body)
+	if err != nil {
+		return err
+	}
+	data := PayslipTemplateData{PayslipDocument: doc}
+	data.Earnings, data.Deductions, data.EmployerContributions = lineItemsByType(doc.Payslip.LineItems)
+
+	// Render in full first so that a failing template doesn't leave half a page.
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPayslipTemplate, err)
+	}
+	_, err = out.WriteTo(w)
+	return err
+}
+
+// ValidatePayslipTemplate checks that a template's source parses and renders
+// the sample payslip.
+func ValidatePayslipTemplate(body string) error {
+	return RenderPayslipTemplate(body, SamplePayslipDocument(), io.Discard)
+}
+
+// RenderPayslipHTML renders a payslip document with the latest version of the
+// template for the employee's group, falling back to the template without a
+// group and then to DefaultPayslipTemplate.
+func RenderPayslipHTML(doc PayslipDocument, w io.Writer) error {
+	body, err := payslipTemplateBody(doc.Employee.Group)
+	if err != nil {
+		return err
+	}
+	return RenderPayslipTemplate(body, doc, w)
+}
+
+// payslipTemplateBody returns the source of the template used for an employee group.
+func payslipTemplateBody(group string) (string, error) {
+	groups := []string{group}
+	if group != "" {
+		groups = append(groups, "")
+	}
+	for _, g := range groups {
+		var version models.PayslipTemplateVersion
+		err := database.DB.Joins("JOIN payslip_templates ON payslip_templates.id = payslip_template_versions.payslip_template_id").
+			Where("payslip_templates.\"group\" = ? AND payslip_templates.deleted_at IS NULL", g).
+			Order("payslip_template_versions.version desc").
+			First(&version).Error
+		if err == nil {
+			return version.Body, nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+	}
+	return DefaultPayslipTemplate, nil
+}
+
+// AddPayslipTemplateVersion validates a template's new source and saves it as
+// the template's next version.
+func AddPayslipTemplateVersion(tx *gorm.DB, templateID uint, body string, adminID uint, requestIP string) (models.PayslipTemplateVersion, error) {
+	version := models.PayslipTemplateVersion{PayslipTemplateID: templateID, Body: body, CreatedByID: adminID, RequestIP: requestIP}
+	if err := ValidatePayslipTemplate(body); err != nil {
+		return version, err
+	}
+	if err := tx.Model(&models.PayslipTemplateVersion{}).
+		Where("payslip_template_id = ?", templateID).
+		Select("COALESCE(MAX(version), 0) + 1").Scan(&version.Version).Error; err != nil {
+		return version, err
+	}
+	err := tx.Create(&version).Error
+	return version, err
+}
+
+// SamplePayslipDocument is a made-up payslip for previewing templates.
+func SamplePayslipDocument() PayslipDocument {
+	issued := time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC)
+	return PayslipDocument{
+		Company:  companyProfile,
+		Employee: models.Employee{BaseModel: models.BaseModel{ID: 42}, Username: "jdoe", Currency: models.DefaultCurrency, Location: "Jakarta", Group: "sample"},
+		Period: models.PayrollPeriod{
+			BaseModel: models.BaseModel{ID: 7},
+			StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+			IsRun:     true,
+		},
+		Payslip: models.Payslip{
+			BaseModel:             models.BaseModel{ID: 1234, CreatedAt: issued},
+			Currency:              models.DefaultCurrency,
+			BaseSalary:            money.FromUnits(10500000),
+			DaysAttended:          20,
+			PaidLeaveDays:         1,
+			WorkingDays:           21,
+			ProratedSalary:        money.FromUnits(10000000),
+			OvertimeHours:         3,
+			OvertimePay:           money.MustParse("375000.00"),
+			Reimbursement:         money.FromUnits(150000),
+			TaxableIncome:         money.MustParse("10375000.00"),
+			TaxWithheld:           money.FromUnits(518750),
+			GrossEarnings:         money.MustParse("10525000.00"),
+			TotalDeductions:       money.FromUnits(618750),
+			TakeHomePay:           money.MustParse("9906250.00"),
+			EmployerContributions: money.FromUnits(400000),
+			LineItems: []models.PayslipLineItem{
+				{Code: CodeBasicSalary, Description: "Basic salary", Type: models.LineItemEarning, Quantity: 20, Rate: 500000, Amount: money.FromUnits(10000000), Taxable: true},
+				{Code: CodeOvertime, Description: "Overtime at 2x", Type: models.LineItemEarning, Quantity: 3, Rate: 125000, Amount: money.FromUnits(375000), Taxable: true},
+				{Code: CodeReimbursement, Description: "Travel: Taxi to client", Type: models.LineItemEarning, Quantity: 1, Rate: 150000, Amount: money.FromUnits(150000)},
+				{Code: "HEALTH", Description: "Health insurance (employee share)", Type: models.LineItemDeduction, Quantity: 10000000, Rate: 0.01, Amount: money.FromUnits(100000), Taxable: true},
+				{Code: CodeIncomeTax, Description: "Income tax", Type: models.LineItemDeduction, Amount: money.FromUnits(518750)},
+				{Code: "HEALTH", Description: "Health insurance (employer share)", Type: models.LineItemEmployerContribution, Quantity: 10000000, Rate: 0.04, Amount: money.FromUnits(400000)},
+			},
+		},
+		YearToDate: YearToDateTotals{
+			Year:            2025,
+			Payslips:        6,
+			GrossEarnings:   money.MustParse("63150000.00"),
+			TotalDeductions: money.FromUnits(3712500),
+			TaxWithheld:     money.FromUnits(3112500),
+			TakeHomePay:     money.MustParse("59437500.00"),
+		},
+	}
+}
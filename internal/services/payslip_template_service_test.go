@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"payslip-generator/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestValidatePayslipTemplate(t *testing.T) {
+	if err := ValidatePayslipTemplate(DefaultPayslipTemplate); err != nil {
+		t.Errorf("Expected the default template to render the sample payslip, but got %v", err)
+	}
+	for _, body := range []string{
+		"{{.Employee.Username",             // Does not parse
+		"{{.Employee.Salary.Nonexistent}}", // Fails on the sample data
+		"{{unknownFunc .}}",                // Unknown function
+		strings.Repeat("x", MaxPayslipTemplateSize+1),
+	} {
+		if err := ValidatePayslipTemplate(body); !errors.Is(err, ErrInvalidPayslipTemplate) {
+			t.Errorf("Expected %.40q to be refused, but got %v", body, err)
+		}
+	}
+}
+
+func TestRenderPayslipHTMLUsesTheGroupTemplate(t *testing.T) {
+	cleanDB()
+	addVersion := func(tmpl *models.PayslipTemplate, body string) {
+		t.Helper()
+		if _, err := AddPayslipTemplateVersion(testDB, tmpl.ID, body, 1, "127.0.0.1"); err != nil {
+			t.Fatalf("Expected the version to be added, but got %v", err)
+		}
+	}
+	fallback := models.PayslipTemplate{Name: "Default", Group: ""}
+	testDB.Create(&fallback)
+	addVersion(&fallback, `<p>Payslip for {{.Employee.Username}}</p>`)
+	indonesia := models.PayslipTemplate{Name: "PT Example Indonesia", Group: "id"}
+	testDB.Create(&indonesia)
+	addVersion(&indonesia, `<p>Slip gaji {{.Employee.Username}}</p>`)
+	addVersion(&indonesia, `<p>Slip gaji {{.Employee.Username}}: {{money .Payslip.TakeHomePay}}</p>`)
+
+	render := func(group string) string {
+		t.Helper()
+		doc := SamplePayslipDocument()
+		doc.Employee.Username = "<b>jdoe</b>"
+		doc.Employee.Group = group
+		var out bytes.Buffer
+		if err := RenderPayslipHTML(doc, &out); err != nil {
+			t.Fatalf("Expected the payslip to render, but got %v", err)
+		}
+		return out.String()
+	}
+
+	if got := render("id"); got != "<p>Slip gaji &lt;b&gt;jdoe&lt;/b&gt;: 9,906,250.00</p>" {
+		t.Errorf("Expected the latest version of the group's template with the data escaped, but got %q", got)
+	}
+	if got := render("sg"); got != "<p>Payslip for &lt;b&gt;jdoe&lt;/b&gt;</p>" {
+		t.Errorf("Expected the template without a group for a group without one, but got %q", got)
+	}
+
+	var versions []models.PayslipTemplateVersion
+	testDB.Where("payslip_template_id = ?", indonesia.ID).Order("version").Find(&versions)
+	if len(versions) != 2 || versions[0].Version != 1 || versions[1].Version != 2 {
+		t.Errorf("Expected versions 1 and 2, but got %+v", versions)
+	}
+	if _, err := AddPayslipTemplateVersion(testDB, indonesia.ID, "{{if}}", 1, "127.0.0.1"); !errors.Is(err, ErrInvalidPayslipTemplate) {
+		t.Errorf("Expected an invalid version to be refused, but got %v", err)
+	}
+
+	// Without any template, the built-in one is used.
+	cleanDB()
+	if got := render("id"); !strings.Contains(got, "Take-home pay") || !strings.Contains(got, "9,906,250.00") {
+		t.Errorf("Expected the built-in template, but got %q", got)
+	}
+}
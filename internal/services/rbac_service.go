@@ -0,0 +1,236 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Permission codes checked by the admin routes.
+const (
+	PermManagePeriods                 = "payroll_periods:manage"
+	PermRunPayroll                    = "payroll:run"
+	PermReversePayroll                = "payroll:reverse"
+	PermReadPayslips                  = "payslips:read"
+	PermReadAuditLogs                 = "audit_logs:read"
+	PermApproveClaims                 = "claims:approve"
+	PermManageRoles                   = "roles:manage"
+	PermManageTaxes                   = "taxes:manage"
+	PermManageEmployees               = "employees:manage"
+	PermManageExchangeRates           = "exchange_rates:manage"
+	PermManageHolidays                = "holidays:manage"
+	PermManageWorkSchedules           = "work_schedules:manage"
+	PermManageLeave                   = "leave:manage"
+	PermApproveLeave                  = "leave:approve"
+	PermManageApprovalRules           = "approval_rules:manage"
+	PermManageReimbursementCategories = "reimbursement_categories:manage"
+	PermManageOvertimePolicies        = "overtime_policies:manage"
+	PermManagePayslipTemplates        = "payslip_templates:manage"
+	PermManagePayslipProtection       = "payslip_protection:manage"
+	PermSendPayslips                  = "payslips:send"
+)
+
+// Names of the built-in roles.
+const (
+	RoleAdministrator   = "administrator"
+	RolePayrollOperator = "payroll_operator"
+	RoleApprover        = "approver"
+	RoleAuditor         = "auditor"
+	RoleHRViewer        = "hr_viewer"
+)
+
+// AllPermissions lists every permission code known to the system.
+var AllPermissions = []string{
+	PermManagePeriods,
+	PermRunPayroll,
+	PermReversePayroll,
+	PermReadPayslips,
+	PermReadAuditLogs,
+	PermApproveClaims,
+	PermManageRoles,
+	PermManageTaxes,
+	PermManageEmployees,
+	PermManageExchangeRates,
+	PermManageHolidays,
+	PermManageWorkSchedules,
+	PermManageLeave,
+	PermApproveLeave,
+	PermManageApprovalRules,
+	PermManageReimbursementCategories,
+	PermManageOvertimePolicies,
+	PermManagePayslipTemplates,
+	PermManagePayslipProtection,
+	PermSendPayslips,
+}
+
+// defaultRoles are created by EnsureDefaultRoles if they don't exist yet.
+var defaultRoles = []struct {
+	Name        string
+	Description string
+	Permissions []string
+}{
+	{RoleAdministrator, "Full access to every admin action.", AllPermissions},
+	{RolePayrollOperator, "Creates payroll periods and runs payroll.", []string{PermManagePeriods, PermRunPayroll, PermReadPayslips}},
+	{RoleApprover, "Approves employee claims and leave and reviews payslips.", []string{PermApproveClaims, PermApproveLeave, PermReadPayslips}},
+	{RoleAuditor, "Reads audit logs and payslips.", []string{PermReadAuditLogs, PermReadPayslips}},
+	{RoleHRViewer, "Read-only access to payslips.", []string{PermReadPayslips}},
+}
+
+// ErrUnknownPermission is returned when a role references a permission code that doesn't exist.
+var ErrUnknownPermission = errors.New("unknown permission")
+
+// ValidatePermissions checks that every code is a known permission.
+func ValidatePermissions(codes []string) error {
+	for _, code := range codes {
+		known := false
+		for _, p := range AllPermissions {
+			if p == code {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w: %s", ErrUnknownPermission, code)
+		}
+	}
+	return nil
+}
+
+// EnsureDefaultRoles creates the built-in roles with their default permissions.
+// Existing roles are left untouched so that edits made by admins are kept, except
+// that the administrator role is granted permissions introduced since the last
+// startup. Codes it was granted before are recorded and never granted again, so
+// one an admin removes from it stays removed.
+func EnsureDefaultRoles() error {
+	var seeded []string
+	if err := database.DB.Model(&models.SeededPermission{}).Pluck("code", &seeded).Error; err != nil {
+		return err
+	}
+	done := map[string]bool{}
+	for _, code := range seeded {
+		done[code] = true
+	}
+	var introduced []string
+	for _, code := range AllPermissions {
+		if !done[code] {
+			introduced = append(introduced, code)
+		}
+	}
+
+	for _, def := range defaultRoles {
+		var role models.Role
+		err := database.DB.Preload("Permissions").Where("name = ?", def.Name).First(&role).Error
+		if err == nil {
+			if def.Name == RoleAdministrator {
+				if err := grantMissingPermissions(role, introduced); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		role = models.Role{Name: def.Name, Description: def.Description}
+		for _, code := range def.Permissions {
+			role.Permissions = append(role.Permissions, models.RolePermission{Permission: code})
+		}
+		if err := database.DB.Create(&role).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, code := range introduced {
+		if err := database.DB.Create(&models.SeededPermission{Code: code}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupGrantAdministrator names the SetupStep of GrantAdministratorToExistingAdmins.
+const setupGrantAdministrator = "grant_administrator_to_existing_admins"
+
+// GrantAdministratorToExistingAdmins gives the administrator role to every
+// admin if no admin holds any role yet, as after upgrading from a version
+// without roles; those admins would otherwise be locked out of every admin
+// route, including the ones that assign roles. It runs once: later startups
+// leave role assignments to the admins, even if nobody can manage roles.
+func GrantAdministratorToExistingAdmins() error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var ran int64
+		if err := tx.Model(&models.SetupStep{}).Where("name = ?", setupGrantAdministrator).Count(&ran).Error; err != nil || ran > 0 {
+			return err
+		}
+		if err := tx.Create(&models.SetupStep{Name: setupGrantAdministrator}).Error; err != nil {
+			return err
+		}
+
+		var assigned int64
+		if err := tx.Table("admin_roles").Count(&assigned).Error; err != nil || assigned > 0 {
+			return err
+		}
+		var administrator models.Role
+		if err := tx.Where("name = ?", RoleAdministrator).First(&administrator).Error; err != nil {
+			return err
+		}
+		var admins []models.Admin
+		if err := tx.Find(&admins).Error; err != nil {
+			return err
+		}
+		for i := range admins {
+			if err := tx.Model(&admins[i]).Association("Roles").Append(&administrator); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// grantMissingPermissions adds the codes the role doesn't hold yet.
+func grantMissingPermissions(role models.Role, codes []string) error {
+	held := map[string]bool{}
+	for _, p := range role.Permissions {
+		held[p.Permission] = true
+	}
+	for _, code := range codes {
+		if held[code] {
+			continue
+		}
+		if err := database.DB.Create(&models.RolePermission{RoleID: role.ID, Permission: code}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRolePermissions replaces the permissions granted to a role.
+func SetRolePermissions(tx *gorm.DB, roleID uint, codes []string) error {
+	if err := tx.Where("role_id = ?", roleID).Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+	for _, code := range codes {
+		if err := tx.Create(&models.RolePermission{RoleID: roleID, Permission: code}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AdminHasPermission reports whether any role assigned to the admin grants the permission.
+func AdminHasPermission(adminID uint, permission string) (bool, error) {
+	var count int64
+	err := database.DB.Table("role_permissions").
+		Joins("JOIN admin_roles ON admin_roles.role_id = role_permissions.role_id").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id AND roles.deleted_at IS NULL").
+		Where("admin_roles.admin_id = ? AND role_permissions.permission = ?", adminID, permission).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"payslip-generator/internal/models"
+	"testing"
+)
+
+// cleanRoles resets the roles, their grants and what has been seeded.
+func cleanRoles() {
+	testDB.Exec("DELETE FROM admin_roles")
+	testDB.Exec("DELETE FROM role_permissions")
+	testDB.Exec("DELETE FROM roles")
+	testDB.Exec("DELETE FROM seeded_permissions")
+	testDB.Exec("DELETE FROM setup_steps")
+	testDB.Exec("DELETE FROM admins")
+}
+
+func TestEnsureDefaultRolesKeepsRemovedPermissionsRemoved(t *testing.T) {
+	cleanRoles()
+	if err := EnsureDefaultRoles(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	var administrator models.Role
+	testDB.Where("name = ?", RoleAdministrator).First(&administrator)
+	testDB.Where("role_id = ? AND permission = ?", administrator.ID, PermReversePayroll).Delete(&models.RolePermission{})
+
+	// A permission introduced since the last startup is still granted.
+	testDB.Where("code = ?", PermSendPayslips).Delete(&models.SeededPermission{})
+	testDB.Where("role_id = ? AND permission = ?", administrator.ID, PermSendPayslips).Delete(&models.RolePermission{})
+
+	if err := EnsureDefaultRoles(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	var held []string
+	testDB.Model(&models.RolePermission{}).Where("role_id = ?", administrator.ID).Pluck("permission", &held)
+	has := map[string]bool{}
+	for _, code := range held {
+		has[code] = true
+	}
+	if has[PermReversePayroll] {
+		t.Error("Expected the permission removed by an admin to stay removed")
+	}
+	if !has[PermSendPayslips] {
+		t.Error("Expected the newly introduced permission to be granted")
+	}
+}
+
+func TestGrantAdministratorToExistingAdmins(t *testing.T) {
+	cleanRoles()
+	if err := EnsureDefaultRoles(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	// Admins from before roles existed hold no role and would be locked out.
+	first := models.Admin{Username: "legacy1"}
+	second := models.Admin{Username: "legacy2"}
+	testDB.Create(&first)
+	testDB.Create(&second)
+	if err := GrantAdministratorToExistingAdmins(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	for _, admin := range []models.Admin{first, second} {
+		if ok, _ := AdminHasPermission(admin.ID, PermManageRoles); !ok {
+			t.Errorf("Expected admin %q to be granted the administrator role", admin.Username)
+		}
+	}
+
+	// It runs once: even with nobody left to manage roles, a later startup grants nothing.
+	testDB.Exec("DELETE FROM admin_roles")
+	auditor := models.Admin{Username: "auditor"}
+	testDB.Create(&auditor)
+	if err := GrantAdministratorToExistingAdmins(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	if ok, _ := AdminHasPermission(auditor.ID, PermRunPayroll); ok {
+		t.Error("Expected no role to be granted after the first startup")
+	}
+}
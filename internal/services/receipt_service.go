@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/storage"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxReceiptSize is the largest receipt file accepted, in bytes.
+	MaxReceiptSize = 5 << 20
+	// MaxReceiptsPerClaim is the number of receipts a reimbursement can carry.
+	MaxReceiptsPerClaim = 5
+)
+
+// ErrInvalidReceipt is returned for an upload that is empty, too large or not an accepted type.
+var ErrInvalidReceipt = errors.New("invalid receipt")
+
+// receiptTypes maps the accepted content types to the extension used for stored files.
+var receiptTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// ReceiptUpload is a receipt file received from a client.
+type ReceiptUpload struct {
+	FileName string
+	Size     int64
+	File     io.ReadSeeker
+}
+
+// CheckReceipt validates an upload's size and content and returns its content
+// type, detected from the file itself, and SHA-256 checksum. The file is
+// rewound afterwards.
+func CheckReceipt(u ReceiptUpload) (contentType, checksum string, err error) {
+	if u.Size <= 0 {
+		return "", "", fmt.Errorf("%w: %s is empty", ErrInvalidReceipt, u.FileName)
+	}
+	if u.Size > MaxReceiptSize {
+		return "", "", fmt.Errorf("%w: %s is larger than %d MB", ErrInvalidReceipt, u.FileName, MaxReceiptSize>>20)
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(u.File, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", "", err
+	}
+	contentType = http.DetectContentType(head[:n])
+	if _, ok := receiptTypes[contentType]; !ok {
+		return "", "", fmt.Errorf("%w: %s is %s; receipts must be JPEG, PNG or WebP images or PDFs", ErrInvalidReceipt, u.FileName, contentType)
+	}
+
+	if _, err := u.File.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	hash := sha256.New()
+	read, err := io.Copy(hash, io.LimitReader(u.File, MaxReceiptSize+1))
+	if err != nil {
+		return "", "", err
+	}
+	if read != u.Size {
+		return "", "", fmt.Errorf("%w: %s is %d bytes, not the %d declared", ErrInvalidReceipt, u.FileName, read, u.Size)
+	}
+	if _, err := u.File.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+	return contentType, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// AttachReceipts validates the uploads, stores them and records them against
+// the reimbursement. Either every upload is attached or none is.
+func AttachReceipts(ctx context.Context, reimbursementID, uploaderID uint, uploads []ReceiptUpload, requestIP string) ([]models.ReimbursementReceipt, error) {
+	var receipts []models.ReimbursementReceipt
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		receipts, err = attachReceipts(ctx, tx, reimbursementID, uploaderID, uploads, requestIP)
+		return err
+	})
+	if err != nil {
+		deleteReceiptFiles(receipts)
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// attachReceipts stores the uploads and records them in tx. The receipts it
+// returns, on error too, are those whose files may have been stored; if tx
+// doesn't commit, the caller removes them with deleteReceiptFiles.
+func attachReceipts(ctx context.Context, tx *gorm.DB, reimbursementID, uploaderID uint, uploads []ReceiptUpload, requestIP string) ([]models.ReimbursementReceipt, error) {
+	var existing int64
+	if err := tx.Model(&models.ReimbursementReceipt{}).Where("reimbursement_id = ?", reimbursementID).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if int(existing)+len(uploads) > MaxReceiptsPerClaim {
+		return nil, fmt.Errorf("%w: a reimbursement can have at most %d receipts", ErrInvalidReceipt, MaxReceiptsPerClaim)
+	}
+
+	receipts := make([]models.ReimbursementReceipt, 0, len(uploads))
+	for _, u := range uploads {
+		contentType, checksum, err := CheckReceipt(u)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, models.ReimbursementReceipt{
+			ReimbursementID: reimbursementID,
+			FileName:        filepath.Base(u.FileName),
+			ContentType:     contentType,
+			Size:            u.Size,
+			SHA256:          checksum,
+			StorageKey:      fmt.Sprintf("receipts/%d/%s%s", reimbursementID, uuid.NewString(), receiptTypes[contentType]),
+			BaseModel: models.BaseModel{
+				CreatedByID: uploaderID,
+				UpdatedByID: uploaderID,
+				RequestIP:   requestIP,
+			},
+		})
+	}
+
+	for i, r := range receipts {
+		// A failed upload may still have reached the store, so it counts as stored.
+		if err := storage.Files.Put(ctx, r.StorageKey, uploads[i].File, r.Size, r.ContentType); err != nil {
+			return receipts[:i+1], fmt.Errorf("storing %s: %w", r.FileName, err)
+		}
+	}
+	return receipts, tx.Create(&receipts).Error
+}
+
+// deleteReceiptFiles removes the stored files of receipts that were not
+// recorded. The upload's context may be cancelled, so it cleans up regardless.
+func deleteReceiptFiles(receipts []models.ReimbursementReceipt) {
+	for _, r := range receipts {
+		if err := storage.Files.Delete(context.Background(), r.StorageKey); err != nil {
+			log.Printf("[Receipts] Error deleting orphaned file %s: %v", r.StorageKey, err)
+		}
+	}
+}
+
+// OpenReceipt returns a reimbursement's receipt and a reader for its file.
+// The caller must close the reader.
+func OpenReceipt(ctx context.Context, reimbursementID, receiptID uint) (models.ReimbursementReceipt, io.ReadCloser, error) {
+	var receipt models.ReimbursementReceipt
+	if err := database.DB.Where("id = ? AND reimbursement_id = ?", receiptID, reimbursementID).First(&receipt).Error; err != nil {
+		return receipt, nil, err
+	}
+	file, err := storage.Files.Get(ctx, receipt.StorageKey)
+	if err != nil {
+		return receipt, nil, err
+	}
+	return receipt, file, nil
+}
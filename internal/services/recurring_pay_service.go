@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"regexp"
+)
+
+// ErrInvalidPayComponent is returned when a recurring pay component is inconsistent.
+var ErrInvalidPayComponent = errors.New("invalid pay component")
+
+var payComponentCode = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+func init() {
+	registerPayComponent(payComponent{Code: "RECURRING_EARNINGS", Stage: stageEarnings, Compute: recurringEarningsComponent})
+	registerPayComponent(payComponent{Code: "RECURRING_PRETAX_DEDUCTIONS", Stage: stagePreTax, Compute: recurringPreTaxDeductionsComponent})
+	registerPayComponent(payComponent{Code: "RECURRING_DEDUCTIONS", Stage: stagePostTax, Compute: recurringPostTaxDeductionsComponent})
+}
+
+// ValidateRecurringPayComponent checks the type, calculation and dates of a component.
+func ValidateRecurringPayComponent(c models.RecurringPayComponent) error {
+	if !payComponentCode.MatchString(c.Code) {
+		return fmt.Errorf("%w: code must be upper case letters, digits and underscores", ErrInvalidPayComponent)
+	}
+	for _, reserved := range builtInCodes {
+		if reserved == c.Code {
+			return fmt.Errorf("%w: code %s is reserved", ErrInvalidPayComponent, c.Code)
+		}
+	}
+	if c.Type != models.LineItemEarning && c.Type != models.LineItemDeduction {
+		return fmt.Errorf("%w: type must be %q or %q", ErrInvalidPayComponent, models.LineItemEarning, models.LineItemDeduction)
+	}
+	switch c.Calculation {
+	case models.CalculationFixed:
+		if c.Amount <= 0 {
+			return fmt.Errorf("%w: a fixed component needs a positive amount", ErrInvalidPayComponent)
+		}
+	case models.CalculationPercentage:
+		if c.Percentage <= 0 || c.Percentage > 100 {
+			return fmt.Errorf("%w: percentage must be above 0 and at most 100", ErrInvalidPayComponent)
+		}
+	default:
+		return fmt.Errorf("%w: calculation must be %q or %q", ErrInvalidPayComponent, models.CalculationFixed, models.CalculationPercentage)
+	}
+	if c.EndDate != nil && c.EndDate.Before(c.StartDate) {
+		return fmt.Errorf("%w: end date is before start date", ErrInvalidPayComponent)
+	}
+	return nil
+}
+
+// activeRecurringPayComponents returns the employee's components that are active
+// at any point during the period.
+func activeRecurringPayComponents(employeeID uint, period models.PayrollPeriod) ([]models.RecurringPayComponent, error) {
+	var components []models.RecurringPayComponent
+	err := database.DB.
+		Where("employee_id = ? AND start_date <= ? AND (end_date IS NULL OR end_date >= ?)", employeeID, period.EndDate, period.StartDate).
+		Order("id").
+		Find(&components).Error
+	return components, err
+}
+
+// recurringEarningsComponent pays the employee's recurring allowances.
+func recurringEarningsComponent(ctx *payslipContext) []models.PayslipLineItem {
+	return recurringItems(ctx, func(c models.RecurringPayComponent) bool {
+		return c.Type == models.LineItemEarning
+	})
+}
+
+// recurringPreTaxDeductionsComponent takes the recurring deductions that reduce taxable income.
+func recurringPreTaxDeductionsComponent(ctx *payslipContext) []models.PayslipLineItem {
+	return recurringItems(ctx, func(c models.RecurringPayComponent) bool {
+		return c.Type == models.LineItemDeduction && c.Taxable
+	})
+}
+
+// recurringPostTaxDeductionsComponent takes the remaining recurring deductions after income tax.
+func recurringPostTaxDeductionsComponent(ctx *payslipContext) []models.PayslipLineItem {
+	return recurringItems(ctx, func(c models.RecurringPayComponent) bool {
+		return c.Type == models.LineItemDeduction && !c.Taxable
+	})
+}
+
+// recurringItems turns the matching recurring components into line items.
+func recurringItems(ctx *payslipContext, match func(models.RecurringPayComponent) bool) []models.PayslipLineItem {
+	var items []models.PayslipLineItem
+	for _, c := range ctx.RecurringComponents {
+		if !match(c) {
+			continue
+		}
+		item := models.PayslipLineItem{
+			Code:        c.Code,
+			Description: c.Description,
+			Type:        c.Type,
+			Quantity:    1,
+			Rate:        c.Amount.Float(),
+			Amount:      c.Amount,
+			Taxable:     c.Taxable,
+		}
+		if c.Calculation == models.CalculationPercentage {
+			item.Quantity = ctx.Employee.Salary.Float()
+			item.Rate = c.Percentage / 100
+			amount := new(big.Rat).Mul(ctx.Employee.Salary.Rat(), money.Decimal(c.Percentage))
+			item.Amount = roundingRules.LineItems.Round(amount.Quo(amount, big.NewRat(100, 1)))
+		}
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidReimbursementCategory is returned when a category's settings are inconsistent.
+	ErrInvalidReimbursementCategory = errors.New("invalid reimbursement category")
+	// ErrReimbursementPolicy is returned for a claim that its category does not allow.
+	ErrReimbursementPolicy = errors.New("reimbursement does not meet the category policy")
+)
+
+// The claim details a category can require.
+const (
+	FieldExpenseDate = "expenseDate"
+	FieldMerchant    = "merchant"
+	FieldReceipt     = "receipt"
+)
+
+var categoryFields = map[string]bool{FieldExpenseDate: true, FieldMerchant: true, FieldReceipt: true}
+
+// requiredFields splits a category's comma-separated list of required fields.
+func requiredFields(list string) []string {
+	var fields []string
+	for _, f := range strings.Split(list, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// ValidateReimbursementCategory checks a category's limits, fields and over-limit action.
+func ValidateReimbursementCategory(c models.ReimbursementCategory) error {
+	if c.Code == "" || c.Name == "" {
+		return fmt.Errorf("%w: code and name are required", ErrInvalidReimbursementCategory)
+	}
+	if err := ValidateCurrency(c.Currency); err != nil {
+		return err
+	}
+	if c.ClaimLimit < 0 || c.PeriodLimit < 0 {
+		return fmt.Errorf("%w: limits cannot be negative", ErrInvalidReimbursementCategory)
+	}
+	for _, f := range requiredFields(c.RequiredFields) {
+		if !categoryFields[f] {
+			return fmt.Errorf("%w: unknown required field %q, expected %s, %s or %s", ErrInvalidReimbursementCategory, f, FieldExpenseDate, FieldMerchant, FieldReceipt)
+		}
+	}
+	if c.OverLimitAction != models.OverLimitReject && c.OverLimitAction != models.OverLimitFlag {
+		return fmt.Errorf("%w: overLimitAction must be %q or %q", ErrInvalidReimbursementCategory, models.OverLimitReject, models.OverLimitFlag)
+	}
+	return nil
+}
+
+// ReimbursementClaim is a claim being submitted, as checked against its category.
+type ReimbursementClaim struct {
+	EmployeeID  uint
+	Amount      money.Amount
+	Currency    string
+	ExpenseDate *time.Time
+	Merchant    string
+	Receipts    int
+	SubmittedAt time.Time
+}
+
+// CheckReimbursementPolicy checks a claim against its category. A claim missing
+// a required field is refused with ErrReimbursementPolicy. A claim over the
+// per-claim or per-period limit is refused too, unless the category flags
+// such claims instead, in which case the limits it exceeds are returned.
+//
+// The period is the payroll period containing the expense date, or the
+// submission if there is none; before that period has been created, the
+// calendar month stands in for it. Pending and approved claims dated within
+// it count toward the per-period limit. Claims in other currencies are converted into the
+// category's currency at the rate on the day they were submitted. The earlier
+// claims are read through tx; SubmitReimbursement holds the employee's lock.
+func CheckReimbursementPolicy(tx *gorm.DB, category models.ReimbursementCategory, claim ReimbursementClaim) ([]string, error) {
+	for _, f := range requiredFields(category.RequiredFields) {
+		missing := f == FieldExpenseDate && claim.ExpenseDate == nil ||
+			f == FieldMerchant && claim.Merchant == "" ||
+			f == FieldReceipt && claim.Receipts == 0
+		if missing {
+			return nil, fmt.Errorf("%w: %s claims require %s", ErrReimbursementPolicy, category.Name, f)
+		}
+	}
+
+	amount, err := amountInCurrency(claim.Amount, claim.Currency, category.Currency, claim.SubmittedAt)
+	if err != nil {
+		return nil, err
+	}
+	var exceeded []string
+	if category.ClaimLimit > 0 && amount > category.ClaimLimit {
+		exceeded = append(exceeded, fmt.Sprintf("over the %s per-claim limit of %s %s", category.Name, category.Currency, category.ClaimLimit))
+	}
+
+	if category.PeriodLimit > 0 {
+		day := claim.SubmittedAt
+		if claim.ExpenseDate != nil {
+			day = *claim.ExpenseDate
+		}
+		start, end, name, err := claimPeriod(tx, time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			return nil, err
+		}
+
+		var earlier []models.Reimbursement
+		err = tx.Where("employee_id = ? AND category_id = ? AND status IN ?", claim.EmployeeID, category.ID, []string{models.ClaimPending, models.ClaimApproved}).
+			Where("(expense_date >= ? AND expense_date < ?) OR (expense_date IS NULL AND created_at >= ? AND created_at < ?)", start, end, start, end).
+			Find(&earlier).Error
+		if err != nil {
+			return nil, err
+		}
+		total := amount
+		for _, r := range earlier {
+			converted, err := amountInCurrency(r.Amount, r.Currency, category.Currency, r.CreatedAt)
+			if err != nil {
+				return nil, err
+			}
+			total += converted
+		}
+		if total > category.PeriodLimit {
+			exceeded = append(exceeded, fmt.Sprintf("%s %s claimed for %s in %s, over the per-period limit of %s",
+				category.Currency, total, category.Name, name, category.PeriodLimit))
+		}
+	}
+
+	if len(exceeded) > 0 && category.OverLimitAction != models.OverLimitFlag {
+		return nil, fmt.Errorf("%w: %s", ErrReimbursementPolicy, strings.Join(exceeded, "; "))
+	}
+	return exceeded, nil
+}
+
+// claimPeriod returns the bounds, end exclusive, and a description of the
+// payroll period containing day, or of day's calendar month if no period does yet.
+func claimPeriod(tx *gorm.DB, day time.Time) (start, end time.Time, name string, err error) {
+	var period models.PayrollPeriod
+	err = tx.Where("start_date <= ? AND end_date >= ?", day, day).Order("start_date desc").First(&period).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		start = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), start.Format("January 2006"), nil
+	}
+	if err != nil {
+		return start, end, "", err
+	}
+	start = time.Date(period.StartDate.Year(), period.StartDate.Month(), period.StartDate.Day(), 0, 0, 0, 0, time.UTC)
+	end = time.Date(period.EndDate.Year(), period.EndDate.Month(), period.EndDate.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	return start, end, fmt.Sprintf("the payroll period from %s to %s", start.Format("2006-01-02"), period.EndDate.Format("2006-01-02")), nil
+}
+
+// SubmitReimbursement records a pending reimbursement. A claim in a category
+// is first checked with CheckReimbursementPolicy, and the limits it exceeds are
+// kept in its PolicyFlags. The employee is locked meanwhile so that concurrent
+// claims are counted toward the per-period limit one after the other.
+// The receipts are stored and recorded in the same transaction, so the claim
+// is only created with all of them, and no file is left behind without it.
+func SubmitReimbursement(ctx context.Context, reimbursement *models.Reimbursement, category *models.ReimbursementCategory, receipts []ReceiptUpload) error {
+	var attached []models.ReimbursementReceipt
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Employee{}, reimbursement.EmployeeID).Error; err != nil {
+			return err
+		}
+		if category != nil {
+			flags, err := CheckReimbursementPolicy(tx, *category, ReimbursementClaim{
+				EmployeeID:  reimbursement.EmployeeID,
+				Amount:      reimbursement.Amount,
+				Currency:    reimbursement.Currency,
+				ExpenseDate: reimbursement.ExpenseDate,
+				Merchant:    reimbursement.Merchant,
+				Receipts:    len(receipts),
+				SubmittedAt: time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			reimbursement.PolicyFlags = strings.Join(flags, "; ")
+		}
+		if err := tx.Create(reimbursement).Error; err != nil {
+			return err
+		}
+		if len(receipts) == 0 {
+			return nil
+		}
+		var err error
+		attached, err = attachReceipts(ctx, tx, reimbursement.ID, reimbursement.EmployeeID, receipts, reimbursement.RequestIP)
+		return err
+	})
+	if err != nil {
+		deleteReceiptFiles(attached)
+		reimbursement.ID = 0
+		return err
+	}
+	reimbursement.Receipts = attached
+	return nil
+}
+
+// amountInCurrency converts an amount at the rate in effect on the given day.
+func amountInCurrency(amount money.Amount, from, to string, day time.Time) (money.Amount, error) {
+	if from == to {
+		return amount, nil
+	}
+	rate, err := exchangeRateForDate(from, to, time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		return 0, err
+	}
+	return amount.MulRat(rate, roundingRules.LineItems), nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"testing"
+	"time"
+)
+
+func TestReimbursementPolicy(t *testing.T) {
+	cleanDB()
+	meals := models.ReimbursementCategory{Code: "MEALS", Name: "Meals", Currency: "IDR", ClaimLimit: money.FromUnits(200000),
+		PeriodLimit: money.FromUnits(500000), RequiredFields: "expenseDate,merchant", OverLimitAction: models.OverLimitReject}
+	if err := ValidateReimbursementCategory(meals); err != nil {
+		t.Fatalf("Expected a valid category, but got %v", err)
+	}
+	testDB.Create(&meals)
+	if err := ValidateReimbursementCategory(models.ReimbursementCategory{Code: "X", Name: "X", Currency: "IDR", RequiredFields: "mood", OverLimitAction: models.OverLimitFlag}); !errors.Is(err, ErrInvalidReimbursementCategory) {
+		t.Errorf("Expected an unknown required field to be refused, but got %v", err)
+	}
+
+	employee := models.Employee{Username: "diner", Salary: money.FromUnits(10000000)}
+	testDB.Create(&employee)
+	june := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	claim := ReimbursementClaim{EmployeeID: employee.ID, Amount: money.FromUnits(150000), Currency: "IDR", ExpenseDate: &june, Merchant: "Warung", SubmittedAt: june}
+
+	if _, err := CheckReimbursementPolicy(testDB, meals, ReimbursementClaim{EmployeeID: employee.ID, Amount: money.FromUnits(1000), Currency: "IDR", ExpenseDate: &june, SubmittedAt: june}); !errors.Is(err, ErrReimbursementPolicy) {
+		t.Errorf("Expected a claim without a merchant to be refused, but got %v", err)
+	}
+	over := claim
+	over.Amount = money.FromUnits(250000)
+	if _, err := CheckReimbursementPolicy(testDB, meals, over); !errors.Is(err, ErrReimbursementPolicy) {
+		t.Errorf("Expected a claim over the per-claim limit to be refused, but got %v", err)
+	}
+
+	// The payroll period runs from May 26 to June 25. 400k pending or approved in
+	// it leaves room for 100k; rejected claims and later June claims don't count.
+	testDB.Create(&models.PayrollPeriod{StartDate: time.Date(2025, 5, 26, 0, 0, 0, 0, time.UTC), EndDate: time.Date(2025, 6, 25, 0, 0, 0, 0, time.UTC)})
+	may := time.Date(2025, 5, 28, 0, 0, 0, 0, time.UTC)
+	lateJune := time.Date(2025, 6, 28, 0, 0, 0, 0, time.UTC)
+	testDB.Create(&models.Reimbursement{EmployeeID: employee.ID, CategoryID: &meals.ID, Amount: money.FromUnits(200000), Currency: "IDR", ExpenseDate: &june, Description: "Lunch", Status: models.ClaimApproved})
+	testDB.Create(&models.Reimbursement{EmployeeID: employee.ID, CategoryID: &meals.ID, Amount: money.FromUnits(200000), Currency: "IDR", ExpenseDate: &may, Description: "Dinner", Status: models.ClaimPending})
+	testDB.Create(&models.Reimbursement{EmployeeID: employee.ID, CategoryID: &meals.ID, Amount: money.FromUnits(200000), Currency: "IDR", ExpenseDate: &june, Description: "Party", Status: models.ClaimRejected})
+	testDB.Create(&models.Reimbursement{EmployeeID: employee.ID, CategoryID: &meals.ID, Amount: money.FromUnits(200000), Currency: "IDR", ExpenseDate: &lateJune, Description: "Lunch", Status: models.ClaimApproved})
+
+	within := claim
+	within.Amount = money.FromUnits(100000)
+	if flags, err := CheckReimbursementPolicy(testDB, meals, within); err != nil || len(flags) != 0 {
+		t.Errorf("Expected a claim within the per-period limit to pass, but got %v, %v", flags, err)
+	}
+	if _, err := CheckReimbursementPolicy(testDB, meals, claim); !errors.Is(err, ErrReimbursementPolicy) {
+		t.Errorf("Expected a claim over the per-period limit to be refused, but got %v", err)
+	}
+
+	// Without a payroll period yet, the calendar month stands in for it.
+	july := time.Date(2025, 7, 3, 0, 0, 0, 0, time.UTC)
+	later := claim
+	later.ExpenseDate, later.SubmittedAt = &july, july
+	if flags, err := CheckReimbursementPolicy(testDB, meals, later); err != nil || len(flags) != 0 {
+		t.Errorf("Expected a July claim to be checked against July's claims only, but got %v, %v", flags, err)
+	}
+
+	meals.OverLimitAction = models.OverLimitFlag
+	flags, err := CheckReimbursementPolicy(testDB, meals, claim)
+	if err != nil || len(flags) != 1 {
+		t.Errorf("Expected the claim to be accepted with one flag, but got %v, %v", flags, err)
+	}
+
+	// Submitting runs the same check and keeps the flags on the claim.
+	submitted := models.Reimbursement{EmployeeID: employee.ID, CategoryID: &meals.ID, Amount: claim.Amount, Currency: "IDR", ExpenseDate: &june, Merchant: "Warung", Description: "Lunch", Status: models.ClaimPending}
+	if err := SubmitReimbursement(context.Background(), &submitted, &meals, nil); err != nil || submitted.ID == 0 || submitted.PolicyFlags == "" {
+		t.Errorf("Expected the claim to be saved with its policy flags, but got %v (%+v)", err, submitted)
+	}
+	meals.OverLimitAction = models.OverLimitReject
+	refused := models.Reimbursement{EmployeeID: employee.ID, CategoryID: &meals.ID, Amount: claim.Amount, Currency: "IDR", ExpenseDate: &june, Merchant: "Warung", Description: "Lunch", Status: models.ClaimPending}
+	if err := SubmitReimbursement(context.Background(), &refused, &meals, nil); !errors.Is(err, ErrReimbursementPolicy) || refused.ID != 0 {
+		t.Errorf("Expected the claim over the limit not to be saved, but got %v", err)
+	}
+}
+
+func TestPayslipDetailsBreakDownReimbursementsByCategory(t *testing.T) {
+	travel := &models.ReimbursementCategory{Code: "TRAVEL", Name: "Travel"}
+	meals := &models.ReimbursementCategory{Code: "MEALS", Name: "Meals"}
+	in := payslipInputs{
+		Schedule: DefaultWorkSchedule,
+		Employee: models.Employee{Salary: money.FromUnits(10000000)},
+		Period: models.PayrollPeriod{
+			StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+		},
+		DaysAttended: 21,
+		Reimbursements: []models.Reimbursement{
+			{Amount: money.FromUnits(50000), Description: "Taxi", Category: travel},
+			{Amount: money.FromUnits(20000), Description: "Lunch", Category: meals},
+			{Amount: money.FromUnits(75000), Description: "Train", Category: travel},
+			{Amount: money.FromUnits(10000), Description: "Stamps"},
+		},
+	}
+
+	payslip := computePayslip(in)
+
+	var details struct {
+		Reimbursements struct {
+			Total      money.Amount                 `json:"total"`
+			ByCategory []reimbursementCategoryTotal `json:"byCategory"`
+		} `json:"reimbursements"`
+	}
+	if err := json.Unmarshal([]byte(payslip.PayslipDetails), &details); err != nil {
+		t.Fatalf("Expected valid details, but got %v", err)
+	}
+	want := []reimbursementCategoryTotal{
+		{Category: "MEALS", Name: "Meals", Claims: 1, Total: money.FromUnits(20000)},
+		{Category: "TRAVEL", Name: "Travel", Claims: 2, Total: money.FromUnits(125000)},
+		{Category: "", Name: "Uncategorized", Claims: 1, Total: money.FromUnits(10000)},
+	}
+	if len(details.Reimbursements.ByCategory) != len(want) {
+		t.Fatalf("Expected %d categories, but got %+v", len(want), details.Reimbursements.ByCategory)
+	}
+	for i, line := range details.Reimbursements.ByCategory {
+		if line != want[i] {
+			t.Errorf("Expected %+v, but got %+v", want[i], line)
+		}
+	}
+	if details.Reimbursements.Total != money.FromUnits(155000) {
+		t.Errorf("Expected a total of 155000, but got %s", details.Reimbursements.Total)
+	}
+	if payslip.LineItems[1].Description != "Travel: Taxi" {
+		t.Errorf("Expected the category in the line item description, but got %q", payslip.LineItems[1].Description)
+	}
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"payslip-generator/internal/money"
+)
+
+// RoundingRules are the rounding rules applied at each step of the payroll
+// calculation. Everything between those steps is computed exactly.
+type RoundingRules struct {
+	LineItems money.Rounding // Every computed line item amount (salary, overtime, percentages, contributions)
+	Tax       money.Rounding // Income tax withholding
+}
+
+// roundingRules are the rules in use; they default to half up to the cent.
+var roundingRules = RoundingRules{LineItems: money.Cent, Tax: money.Cent}
+
+// LoadRoundingRules reads the rounding rules from ROUNDING_LINE_ITEMS and
+// ROUNDING_TAX, written as "mode" or "mode:increment" (e.g. "down:100").
+// Unset variables keep the default of half up to the cent.
+func LoadRoundingRules() error {
+	rules := RoundingRules{LineItems: money.Cent, Tax: money.Cent}
+	for env, rule := range map[string]*money.Rounding{
+		"ROUNDING_LINE_ITEMS": &rules.LineItems,
+		"ROUNDING_TAX":        &rules.Tax,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		parsed, err := money.ParseRounding(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+		*rule = parsed
+	}
+	roundingRules = rules
+	return nil
+}
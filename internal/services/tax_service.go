@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// periodsPerYear is used to annualize pay when a tax table uses an annual basis.
+const periodsPerYear = 12
+
+// ErrInvalidTaxTable is returned when a tax table's brackets or settings are inconsistent.
+var ErrInvalidTaxTable = errors.New("invalid tax table")
+
+// TaxTableForDate returns the tax table in effect on the given date, or nil if
+// no table had taken effect yet (in which case no tax is withheld).
+func TaxTableForDate(date time.Time) (*models.TaxTable, error) {
+	var table models.TaxTable
+	err := database.DB.
+		Preload("Brackets", func(db *gorm.DB) *gorm.DB { return db.Order("lower_bound") }).
+		Where("effective_from <= ?", date).
+		Order("effective_from desc").
+		First(&table).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
+
+// ValidateTaxTable checks that the brackets start at zero, are contiguous,
+// and that only the last one is open-ended.
+func ValidateTaxTable(table models.TaxTable) error {
+	if table.Basis != models.TaxBasisAnnual && table.Basis != models.TaxBasisMonthly {
+		return fmt.Errorf("%w: basis must be %q or %q", ErrInvalidTaxTable, models.TaxBasisAnnual, models.TaxBasisMonthly)
+	}
+	if err := ValidateCurrency(table.Currency); err != nil {
+		return err
+	}
+	if len(table.Brackets) == 0 {
+		return fmt.Errorf("%w: at least one bracket is required", ErrInvalidTaxTable)
+	}
+
+	brackets := append([]models.TaxBracket(nil), table.Brackets...)
+	sort.Slice(brackets, func(i, j int) bool { return brackets[i].LowerBound < brackets[j].LowerBound })
+	if brackets[0].LowerBound != 0 {
+		return fmt.Errorf("%w: the first bracket must start at 0", ErrInvalidTaxTable)
+	}
+	for i, b := range brackets {
+		if b.Rate < 0 || b.Rate > 1 {
+			return fmt.Errorf("%w: rate %.4f must be between 0 and 1", ErrInvalidTaxTable, b.Rate)
+		}
+		last := i == len(brackets)-1
+		if b.UpperBound == 0 && !last {
+			return fmt.Errorf("%w: only the last bracket may be open-ended", ErrInvalidTaxTable)
+		}
+		if b.UpperBound != 0 && b.UpperBound <= b.LowerBound {
+			return fmt.Errorf("%w: bracket upper bound must be above its lower bound", ErrInvalidTaxTable)
+		}
+		if !last && brackets[i+1].LowerBound != b.UpperBound {
+			return fmt.Errorf("%w: brackets must be contiguous", ErrInvalidTaxTable)
+		}
+	}
+	if table.PersonalAllowance < 0 || table.SpouseAllowance < 0 || table.DependentAllowance < 0 || table.MaxDependents < 0 {
+		return fmt.Errorf("%w: allowances cannot be negative", ErrInvalidTaxTable)
+	}
+	return nil
+}
+
+// convertTaxTable returns a copy of the table with its bounds and allowances
+// converted at rate, for an employee paid in another currency than the table's.
+func convertTaxTable(table *models.TaxTable, currency string, rate *big.Rat) *models.TaxTable {
+	converted := *table
+	converted.Currency = currency
+	converted.PersonalAllowance = table.PersonalAllowance.MulRat(rate, money.Cent)
+	converted.SpouseAllowance = table.SpouseAllowance.MulRat(rate, money.Cent)
+	converted.DependentAllowance = table.DependentAllowance.MulRat(rate, money.Cent)
+	converted.Brackets = make([]models.TaxBracket, len(table.Brackets))
+	for i, b := range table.Brackets {
+		b.LowerBound = b.LowerBound.MulRat(rate, money.Cent)
+		b.UpperBound = b.UpperBound.MulRat(rate, money.Cent)
+		converted.Brackets[i] = b
+	}
+	return &converted
+}
+
+// taxFreeAllowance is the amount of income the employee may earn tax-free,
+// expressed in the table's basis.
+func taxFreeAllowance(table *models.TaxTable, emp models.Employee) money.Amount {
+	allowance := table.PersonalAllowance
+	if emp.TaxMaritalStatus == models.TaxStatusMarried {
+		allowance += table.SpouseAllowance
+	}
+	dependents := emp.TaxDependents
+	if dependents > table.MaxDependents {
+		dependents = table.MaxDependents
+	}
+	return allowance + money.Amount(dependents)*table.DependentAllowance
+}
+
+// progressiveTax applies each bracket's rate to the slice of income that falls
+// inside it. The result is exact, in minor units, and is rounded by the caller.
+func progressiveTax(brackets []models.TaxBracket, income money.Amount) *big.Rat {
+	tax := new(big.Rat)
+	for _, b := range brackets {
+		if income <= b.LowerBound {
+			break
+		}
+		top := income
+		if b.UpperBound != 0 && b.UpperBound < income {
+			top = b.UpperBound
+		}
+		tax.Add(tax, new(big.Rat).Mul((top-b.LowerBound).Rat(), money.Decimal(b.Rate)))
+	}
+	return tax
+}
+
+// computeTaxWithholding returns the tax to withhold from one period's taxable income.
+// For annual tables the income is annualized, taxed, and the result spread back over the year.
+// The result is rounded once, with the tax rounding rule.
+func computeTaxWithholding(table *models.TaxTable, emp models.Employee, taxableIncome money.Amount) money.Amount {
+	if table == nil || taxableIncome <= 0 {
+		return 0
+	}
+
+	factor := int64(1)
+	if table.Basis == models.TaxBasisAnnual {
+		factor = periodsPerYear
+	}
+
+	taxBase := taxableIncome*money.Amount(factor) - taxFreeAllowance(table, emp)
+	if taxBase <= 0 {
+		return 0
+	}
+	tax := progressiveTax(table.Brackets, taxBase)
+	return roundingRules.Tax.Round(tax.Quo(tax, new(big.Rat).SetInt64(factor)))
+}
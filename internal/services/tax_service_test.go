@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"testing"
+	"time"
+)
+
+// sampleTaxTable has annual brackets of 5% up to 60M, 15% up to 250M and 25% above,
+// with a 54M personal allowance, 4.5M for a spouse and 4.5M per dependent (max 3).
+func sampleTaxTable(effectiveFrom time.Time) models.TaxTable {
+	return models.TaxTable{
+		Name:               "Sample",
+		EffectiveFrom:      effectiveFrom,
+		Basis:              models.TaxBasisAnnual,
+		Currency:           "IDR",
+		PersonalAllowance:  money.FromUnits(54000000),
+		SpouseAllowance:    money.FromUnits(4500000),
+		DependentAllowance: money.FromUnits(4500000),
+		MaxDependents:      3,
+		Brackets: []models.TaxBracket{
+			{LowerBound: 0, UpperBound: money.FromUnits(60000000), Rate: 0.05},
+			{LowerBound: money.FromUnits(60000000), UpperBound: money.FromUnits(250000000), Rate: 0.15},
+			{LowerBound: money.FromUnits(250000000), UpperBound: 0, Rate: 0.25},
+		},
+	}
+}
+
+func TestComputeTaxWithholding(t *testing.T) {
+	table := sampleTaxTable(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name     string
+		employee models.Employee
+		monthly  money.Amount
+		expected money.Amount
+	}{
+		// 120M/year - 54M = 66M: 60M*5% + 6M*15% = 3.9M/year
+		{"single, crosses into second bracket", models.Employee{TaxMaritalStatus: models.TaxStatusSingle}, money.FromUnits(10000000), money.FromUnits(325000)},
+		// 120M/year - 54M - 4.5M - 3*4.5M = 48M: 48M*5% = 2.4M/year
+		{"married, dependents capped at three", models.Employee{TaxMaritalStatus: models.TaxStatusMarried, TaxDependents: 5}, money.FromUnits(10000000), money.FromUnits(200000)},
+		{"income below allowance", models.Employee{TaxMaritalStatus: models.TaxStatusSingle}, money.FromUnits(4000000), money.FromUnits(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := computeTaxWithholding(&table, tt.employee, tt.monthly)
+			if got != tt.expected {
+				t.Errorf("Expected withholding of %s, but got %s", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("no table withholds nothing", func(t *testing.T) {
+		if got := computeTaxWithholding(nil, models.Employee{}, money.FromUnits(10000000)); got != 0 {
+			t.Errorf("Expected no withholding without a table, but got %s", got)
+		}
+	})
+}
+
+func TestValidateTaxTable(t *testing.T) {
+	table := sampleTaxTable(time.Now())
+	if err := ValidateTaxTable(table); err != nil {
+		t.Fatalf("Expected sample table to be valid, but got %v", err)
+	}
+
+	table.Brackets[1].LowerBound = money.FromUnits(70000000)
+	if err := ValidateTaxTable(table); !errors.Is(err, ErrInvalidTaxTable) {
+		t.Errorf("Expected a gap between brackets to be rejected, but got %v", err)
+	}
+}
+
+func TestTaxTableForDateUsesVersionInEffect(t *testing.T) {
+	testDB.Exec("DELETE FROM tax_brackets")
+	testDB.Exec("DELETE FROM tax_tables")
+	defer testDB.Exec("DELETE FROM tax_brackets")
+	defer testDB.Exec("DELETE FROM tax_tables")
+
+	older := sampleTaxTable(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	newer := sampleTaxTable(time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC))
+	newer.Name = "Newer"
+	testDB.Create(&older)
+	testDB.Create(&newer)
+
+	table, err := TaxTableForDate(time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC))
+	if err != nil || table == nil || table.ID != older.ID {
+		t.Fatalf("Expected the 2024 table for June 2025, got %+v (err %v)", table, err)
+	}
+	if len(table.Brackets) != 3 {
+		t.Errorf("Expected brackets to be loaded, got %d", len(table.Brackets))
+	}
+
+	table, _ = TaxTableForDate(time.Date(2025, 7, 31, 0, 0, 0, 0, time.UTC))
+	if table == nil || table.ID != newer.ID {
+		t.Errorf("Expected the July 2025 table for July 2025, got %+v", table)
+	}
+
+	table, _ = TaxTableForDate(time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC))
+	if table != nil {
+		t.Errorf("Expected no table before the first version, got %+v", table)
+	}
+}
+
+func TestTaxWithholdingUsesTaxRoundingRule(t *testing.T) {
+	t.Setenv("ROUNDING_TAX", "down:1000")
+	if err := LoadRoundingRules(); err != nil {
+		t.Fatalf("Expected rounding rules to load, but got %v", err)
+	}
+	defer func() { roundingRules = RoundingRules{LineItems: money.Cent, Tax: money.Cent} }()
+
+	table := sampleTaxTable(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	// 10.01M/month: (120.12M - 54M) gives 3M + 6.12M*15% = 3.918M/year, 326,500/month
+	got := computeTaxWithholding(&table, models.Employee{TaxMaritalStatus: models.TaxStatusSingle}, money.FromUnits(10010000))
+	if got != money.FromUnits(326000) {
+		t.Errorf("Expected withholding rounded down to 326000, but got %s", got)
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"payslip-generator/internal/database"
+	"payslip-generator/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidWorkSchedule is returned when a work schedule is inconsistent.
+var ErrInvalidWorkSchedule = errors.New("invalid work schedule")
+
+// DefaultWorkSchedule applies to employees without a schedule of their own.
+var DefaultWorkSchedule = models.WorkSchedule{Name: "Standard", Weekdays: "mon,tue,wed,thu,fri", HoursPerDay: 8, StartTime: "09:00", EndTime: "17:00"}
+
+// Rotation pattern days.
+const (
+	rotationWorkingDay = 'W'
+	rotationDayOff     = 'O'
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseWeekdays reads a comma-separated list of weekday names such as "mon,tue".
+func parseWeekdays(list string) (map[time.Weekday]bool, error) {
+	days := map[time.Weekday]bool{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		day, ok := weekdayNames[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown weekday %q", ErrInvalidWorkSchedule, name)
+		}
+		days[day] = true
+	}
+	return days, nil
+}
+
+// ValidateWorkSchedule checks the working days and hours of a schedule.
+func ValidateWorkSchedule(s models.WorkSchedule) error {
+	if s.HoursPerDay <= 0 || s.HoursPerDay > 24 {
+		return fmt.Errorf("%w: hoursPerDay must be more than 0 and at most 24", ErrInvalidWorkSchedule)
+	}
+	if (s.StartTime == "") != (s.EndTime == "") {
+		return fmt.Errorf("%w: startTime and endTime must be set together", ErrInvalidWorkSchedule)
+	}
+	for _, hhmm := range []string{s.StartTime, s.EndTime} {
+		if _, err := time.Parse("15:04", hhmm); hhmm != "" && err != nil {
+			return fmt.Errorf("%w: startTime and endTime must be times such as 09:00", ErrInvalidWorkSchedule)
+		}
+	}
+	if s.RotationPattern != "" {
+		if s.RotationStart == nil {
+			return fmt.Errorf("%w: a rotation pattern needs a rotationStart", ErrInvalidWorkSchedule)
+		}
+		if strings.Trim(s.RotationPattern, string([]rune{rotationWorkingDay, rotationDayOff})) != "" {
+			return fmt.Errorf("%w: rotationPattern may only contain %q and %q", ErrInvalidWorkSchedule, rotationWorkingDay, rotationDayOff)
+		}
+		if !strings.ContainsRune(s.RotationPattern, rotationWorkingDay) {
+			return fmt.Errorf("%w: rotationPattern has no working days", ErrInvalidWorkSchedule)
+		}
+		return nil
+	}
+	days, err := parseWeekdays(s.Weekdays)
+	if err != nil {
+		return err
+	}
+	if len(days) == 0 {
+		return fmt.Errorf("%w: weekdays or rotationPattern is required", ErrInvalidWorkSchedule)
+	}
+	return nil
+}
+
+// WorkScheduleForEmployee returns the employee's schedule, or DefaultWorkSchedule if none is assigned.
+func WorkScheduleForEmployee(emp models.Employee) (models.WorkSchedule, error) {
+	if emp.WorkScheduleID == nil {
+		return DefaultWorkSchedule, nil
+	}
+	var schedule models.WorkSchedule
+	err := database.DB.First(&schedule, *emp.WorkScheduleID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultWorkSchedule, nil
+	}
+	return schedule, err
+}
+
+// IsScheduledWorkDay reports whether the schedule expects work on the day of t.
+// Schedules are assumed to have been validated.
+func IsScheduledWorkDay(s models.WorkSchedule, t time.Time) bool {
+	if s.RotationPattern != "" && s.RotationStart != nil {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		start := time.Date(s.RotationStart.Year(), s.RotationStart.Month(), s.RotationStart.Day(), 0, 0, 0, 0, time.UTC)
+		n := len(s.RotationPattern)
+		offset := int(day.Sub(start).Hours()/24) % n
+		if offset < 0 {
+			offset += n
+		}
+		return s.RotationPattern[offset] == rotationWorkingDay
+	}
+	days, err := parseWeekdays(s.Weekdays)
+	return err == nil && days[t.Weekday()]
+}
+
+// ShiftDay returns midnight of the day whose shift t falls in, in t's location:
+// the day before if t is before the end of an overnight shift worked then, such
+// as a check-in at 00:30 for a shift from 22:00 to 06:00, and otherwise the day
+// of t. Schedules are assumed to have been validated.
+func ShiftDay(s models.WorkSchedule, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	previous := day.AddDate(0, 0, -1)
+	if _, end, ok := shiftOn(s, previous); ok && t.Before(end) && IsScheduledWorkDay(s, previous) {
+		return previous
+	}
+	return day
+}
+
+// scheduledShift returns the start and end of the shift t falls in, as found
+// by ShiftDay. ok is false if the schedule has no set hours.
+func scheduledShift(s models.WorkSchedule, t time.Time) (start, end time.Time, ok bool) {
+	return shiftOn(s, ShiftDay(s, t))
+}
+
+// shiftOn returns the start and end of the schedule's working hours starting
+// on the given day, in its location. ok is false if the schedule has no set hours.
+func shiftOn(s models.WorkSchedule, day time.Time) (start, end time.Time, ok bool) {
+	if s.StartTime == "" || s.EndTime == "" {
+		return time.Time{}, time.Time{}, false
+	}
+	from, _ := time.Parse("15:04", s.StartTime)
+	to, _ := time.Parse("15:04", s.EndTime)
+	start = time.Date(day.Year(), day.Month(), day.Day(), from.Hour(), from.Minute(), 0, 0, day.Location())
+	end = time.Date(day.Year(), day.Month(), day.Day(), to.Hour(), to.Minute(), 0, 0, day.Location())
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1) // The shift ends the next day
+	}
+	return start, end, true
+}
+
+// LateMinutes returns how many minutes after the scheduled start of its shift
+// the employee checked in, or 0 if the schedule has no set hours.
+func LateMinutes(s models.WorkSchedule, checkIn time.Time) int {
+	start, _, ok := scheduledShift(s, checkIn)
+	if !ok || !checkIn.After(start) {
+		return 0
+	}
+	return int(checkIn.Sub(start).Minutes())
+}
+
+// EarlyLeaveMinutes returns how many minutes before the scheduled end of the
+// shift checkIn falls in the employee checked out, or 0 if the schedule has no
+// set hours.
+func EarlyLeaveMinutes(s models.WorkSchedule, checkIn, checkOut time.Time) int {
+	_, end, ok := scheduledShift(s, checkIn)
+	if !ok || !checkOut.Before(end) {
+		return 0
+	}
+	return int(end.Sub(checkOut).Minutes())
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"payslip-generator/internal/models"
+	"payslip-generator/internal/money"
+	"testing"
+	"time"
+)
+
+func TestRotatingScheduleRepeatsFromItsStart(t *testing.T) {
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	schedule := models.WorkSchedule{Name: "4 on 4 off", RotationPattern: "WWWWOOOO", RotationStart: &start, HoursPerDay: 12}
+	if err := ValidateWorkSchedule(schedule); err != nil {
+		t.Fatalf("Expected a valid schedule, but got %v", err)
+	}
+
+	cases := map[string]bool{
+		"2025-06-01": true,  // First day of the cycle
+		"2025-06-04": true,  // Last working day
+		"2025-06-05": false, // First day off
+		"2025-06-09": true,  // Next cycle
+		"2025-05-31": false, // Before the start, the pattern runs backwards
+		"2025-05-24": true,  // A full cycle before the start
+	}
+	for date, want := range cases {
+		day, _ := time.Parse("2006-01-02", date)
+		if got := IsScheduledWorkDay(schedule, day); got != want {
+			t.Errorf("Expected %s to be a working day: %v, got %v", date, want, got)
+		}
+	}
+}
+
+func TestValidateWorkSchedule(t *testing.T) {
+	bad := []models.WorkSchedule{
+		{Weekdays: "mon,tue", HoursPerDay: 0},
+		{Weekdays: "mon,funday", HoursPerDay: 8},
+		{Weekdays: "", HoursPerDay: 8},
+		{RotationPattern: "WWOO", HoursPerDay: 8},                             // No start
+		{Weekdays: "mon", HoursPerDay: 8, StartTime: "09:00"},                 // No end time
+		{Weekdays: "mon", HoursPerDay: 8, StartTime: "9am", EndTime: "17:00"}, // Not HH:MM
+	}
+	for _, s := range bad {
+		if err := ValidateWorkSchedule(s); !errors.Is(err, ErrInvalidWorkSchedule) {
+			t.Errorf("Expected %+v to be rejected, but got %v", s, err)
+		}
+	}
+}
+
+func TestPartTimeScheduleProratesAndSetsHourlyRate(t *testing.T) {
+	cleanDB()
+	period := models.PayrollPeriod{
+		StartDate: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+	testDB.Create(&period)
+	schedule := models.WorkSchedule{Name: "Part-time", Weekdays: "mon,tue,wed,thu", HoursPerDay: 6}
+	testDB.Create(&schedule)
+
+	// June 2025 has 17 Mondays to Thursdays.
+	employee := models.Employee{Username: "parttime", Salary: money.FromUnits(10200000), WorkScheduleID: &schedule.ID}
+	testDB.Create(&employee)
+	testDB.Create(&models.Attendance{EmployeeID: employee.ID, CheckIn: time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)})
+	testDB.Create(&models.Overtime{EmployeeID: employee.ID, Hours: 2, Status: models.ClaimApproved, Date: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)})
+
+	calc, err := calculatePayslipForEmployee(employee, period, 1, 1, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	payslip := calc.Payslip
+	if payslip.WorkingDays != 17 {
+		t.Errorf("Expected 17 working days, but got %d", payslip.WorkingDays)
+	}
+	// Daily rate is 600k and hourly rate 100k: 2 hours at 2x.
+	if payslip.ProratedSalary != money.FromUnits(600000) || payslip.OvertimePay != money.FromUnits(400000) {
+		t.Errorf("Expected 600000 salary and 400000 overtime, got %s and %s", payslip.ProratedSalary, payslip.OvertimePay)
+	}
+}
+
+func TestOvertimeDerivedFromAttendance(t *testing.T) {
+	schedule := DefaultWorkSchedule
+	schedule.OvertimeFromAttendance = true
+	in := payslipInputs{
+		Schedule:       schedule,
+		OvertimePolicy: DefaultOvertimePolicy,
+		Employee:       models.Employee{Salary: money.FromUnits(16000000)},
+		Period: models.PayrollPeriod{
+			StartDate: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2025, 6, 13, 0, 0, 0, 0, time.UTC),
+		},
+		DaysAttended: 2,
+		Holidays:     holidaySet{"2025-06-09": "Eid al-Adha"},
+		Overtimes:    []models.Overtime{{Date: time.Date(2025, 6, 3, 0, 0, 0, 0, time.UTC), Hours: 3}},
+		WorkedMinutes: map[string]int{
+			"2025-06-02": 600, // 2 hours beyond the 8 hour day
+			"2025-06-03": 660, // Covered by the 3 hour claim
+			"2025-06-04": 420, // A short day
+			"2025-06-09": 180, // Every hour on a holiday is overtime
+		},
+		HolidayCalendar: &models.HolidayCalendar{OvertimeMultiplier: 3},
+	}
+
+	payslip := computePayslip(in)
+
+	// 9 working days of 8 hours make an hourly rate of 16M / 72 = 222,222.22...
+	// 5 regular hours at 2x and 3 holiday hours at 3x are 19 hourly rates.
+	if payslip.OvertimeHours != 8 {
+		t.Errorf("Expected 8 overtime hours, but got %v", payslip.OvertimeHours)
+	}
+	if payslip.OvertimePay != money.MustParse("4222222.22") {
+		t.Errorf("Expected overtime pay of 4222222.22, but got %s", payslip.OvertimePay)
+	}
+}
+
+func TestOvertimeFromAttendanceKeepsToTheDailyLimit(t *testing.T) {
+	schedule := DefaultWorkSchedule
+	schedule.OvertimeFromAttendance = true
+	in := payslipInputs{
+		Schedule:       schedule,
+		OvertimePolicy: DefaultOvertimePolicy,
+		Employee:       models.Employee{Salary: money.FromUnits(16000000)},
+		Period: models.PayrollPeriod{
+			StartDate: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2025, 6, 13, 0, 0, 0, 0, time.UTC),
+		},
+		DaysAttended:  1,
+		WorkedMinutes: map[string]int{"2025-06-02": 840}, // 6 hours beyond the 8 hour day
+	}
+
+	payslip := computePayslip(in)
+
+	if payslip.OvertimeHours != DefaultOvertimePolicy.DailyLimitHours {
+		t.Errorf("Expected overtime to stop at the daily limit of %v hours, but got %v", DefaultOvertimePolicy.DailyLimitHours, payslip.OvertimeHours)
+	}
+}
+
+func TestLateArrivalsAndEarlyLeaves(t *testing.T) {
+	day := func(hour, minute int) time.Time { return time.Date(2025, 6, 2, hour, minute, 0, 0, time.UTC) }
+
+	if got := LateMinutes(DefaultWorkSchedule, day(9, 25)); got != 25 {
+		t.Errorf("Expected 25 late minutes, but got %d", got)
+	}
+	if got := LateMinutes(DefaultWorkSchedule, day(8, 50)); got != 0 {
+		t.Errorf("Expected no late minutes for an early check-in, but got %d", got)
+	}
+	if got := EarlyLeaveMinutes(DefaultWorkSchedule, day(9, 0), day(16, 15)); got != 45 {
+		t.Errorf("Expected 45 early leave minutes, but got %d", got)
+	}
+	if got := EarlyLeaveMinutes(DefaultWorkSchedule, day(9, 0), day(17, 30)); got != 0 {
+		t.Errorf("Expected no early leave minutes for a late check-out, but got %d", got)
+	}
+
+	// A night shift ends the day after it starts.
+	night := models.WorkSchedule{Name: "Night", Weekdays: "mon", HoursPerDay: 8, StartTime: "22:00", EndTime: "06:00"}
+	if got := EarlyLeaveMinutes(night, day(22, 0), time.Date(2025, 6, 3, 5, 0, 0, 0, time.UTC)); got != 60 {
+		t.Errorf("Expected 60 early leave minutes on a night shift, but got %d", got)
+	}
+
+	// A check-in after midnight is late for the shift that started the evening before.
+	night.Weekdays = "mon,tue"
+	afterMidnight := time.Date(2025, 6, 3, 0, 30, 0, 0, time.UTC)
+	if got := ShiftDay(night, afterMidnight); !got.Equal(day(0, 0)) {
+		t.Errorf("Expected the check-in at 00:30 to belong to Monday's shift, but got %s", got)
+	}
+	if got := LateMinutes(night, afterMidnight); got != 150 {
+		t.Errorf("Expected 150 late minutes after midnight, but got %d", got)
+	}
+	if got := EarlyLeaveMinutes(night, afterMidnight, time.Date(2025, 6, 3, 5, 30, 0, 0, time.UTC)); got != 30 {
+		t.Errorf("Expected 30 early leave minutes against Monday's shift, but got %d", got)
+	}
+	// Monday's shift follows a day off, so Monday at 00:30 is early for it.
+	if got := ShiftDay(night, day(0, 30)); !got.Equal(day(0, 0)) {
+		t.Errorf("Expected a check-in after a day off to belong to the same day's shift, but got %s", got)
+	}
+
+	// Schedules without set hours don't measure either.
+	flexible := models.WorkSchedule{Name: "Flexible", Weekdays: "mon", HoursPerDay: 8}
+	if LateMinutes(flexible, day(11, 0)) != 0 || EarlyLeaveMinutes(flexible, day(11, 0), day(12, 0)) != 0 {
+		t.Error("Expected no late or early leave minutes without set hours")
+	}
+}
@@ -0,0 +1,61 @@
+// Package storage keeps uploaded files, such as reimbursement receipts, outside the database.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Store saves and retrieves objects by key. Keys are slash-separated paths such as "receipts/12/<uuid>.pdf".
+type Store interface {
+	// Put writes size bytes from r under key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object under key. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Files is the store used by the application.
+var Files Store
+
+// SetupStorage sets Files to the backend selected by STORAGE_BACKEND:
+//
+//   - "local" (the default) keeps files under STORAGE_DIR, "./uploads" if unset.
+//   - "s3" uses the S3-compatible service at S3_ENDPOINT (e.g. "http://localhost:9000" for MinIO)
+//     with S3_BUCKET, S3_REGION ("us-east-1" if unset), S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY.
+func SetupStorage() error {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		store, err := NewLocalStore(dir)
+		if err != nil {
+			return err
+		}
+		Files = store
+	case "s3":
+		store, err := NewS3Store(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          os.Getenv("S3_REGION"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		})
+		if err != nil {
+			return err
+		}
+		Files = store
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q, expected \"local\" or \"s3\"", backend)
+	}
+	return nil
+}
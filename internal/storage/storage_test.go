@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected a store, but got %v", err)
+	}
+	testStore(t, store)
+
+	if err := store.Put(context.Background(), "../outside", strings.NewReader("x"), 1, ""); err == nil {
+		t.Error("Expected a key escaping the directory to be refused")
+	}
+	if err := store.Put(context.Background(), "short", strings.NewReader("x"), 2, ""); err == nil {
+		t.Error("Expected a short upload to fail")
+	}
+	if _, err := store.Get(context.Background(), "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a failed upload to leave nothing behind, but got %v", err)
+	}
+}
+
+// fakeS3 is a minimal in-memory stand-in for an S3 bucket.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = string(body)
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{Endpoint: server.URL, Bucket: "receipts", AccessKeyID: "minio", SecretAccessKey: "minio123"})
+	if err != nil {
+		t.Fatalf("Expected a store, but got %v", err)
+	}
+	testStore(t, store)
+	if _, ok := fake.objects["/receipts/dir/a b.txt"]; ok {
+		t.Error("Expected the deleted object to be gone from the bucket")
+	}
+
+	if _, err := NewS3Store(S3Config{Endpoint: server.URL}); err == nil {
+		t.Error("Expected an incomplete configuration to be refused")
+	}
+}
+
+// testStore runs a put, get and delete round trip against a store.
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	if err := store.Put(ctx, "dir/a b.txt", strings.NewReader("receipt"), 7, "text/plain"); err != nil {
+		t.Fatalf("Expected the object to be stored, but got %v", err)
+	}
+	r, err := store.Get(ctx, "dir/a b.txt")
+	if err != nil {
+		t.Fatalf("Expected the object to be found, but got %v", err)
+	}
+	body, _ := io.ReadAll(r)
+	r.Close()
+	if string(body) != "receipt" {
+		t.Errorf("Expected the stored content back, but got %q", body)
+	}
+	if err := store.Delete(ctx, "dir/a b.txt"); err != nil {
+		t.Fatalf("Expected the object to be deleted, but got %v", err)
+	}
+	if _, err := store.Get(ctx, "dir/a b.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a deleted object to be missing, but got %v", err)
+	}
+	if err := store.Delete(ctx, "dir/a b.txt"); err != nil {
+		t.Errorf("Expected deleting a missing object to succeed, but got %v", err)
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	// The example from the AWS Signature Version 4 documentation.
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	if got := hex.EncodeToString(key); got != "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d" {
+		t.Errorf("Unexpected signing key %s", got)
+	}
+	if got := uriEscapePath("/bucket/a b+c.pdf"); got != "/bucket/a%20b%2Bc.pdf" {
+		t.Errorf("Unexpected escaped path %s", got)
+	}
+}